@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const deadLettersUsage = `usage: app dlq <command> [arguments]
+
+commands:
+  list [-source event|webhook] [-name NAME] [-status open|replaying|replayed|discarded] [-limit N] [-offset N]
+  show <id>
+  replay <id>
+  discard <id>`
+
+const deadLetterFields = `id source name reference error attempts status replay_count resolved_at created_at updated_at`
+
+type deadLetter struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Name        string          `json:"name"`
+	Reference   string          `json:"reference"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Error       string          `json:"error"`
+	Attempts    int             `json:"attempts"`
+	Status      string          `json:"status"`
+	ReplayCount int             `json:"replay_count"`
+	ResolvedAt  *time.Time      `json:"resolved_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// DeadLetters runs the "dlq" subcommand, which lists, shows, replays and
+// discards dead letters through the admin actions.
+func DeadLetters(ctx context.Context, client *HasuraClient, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(deadLettersUsage)
+	}
+
+	switch command, args := args[0], args[1:]; command {
+	case "list":
+		return listDeadLetters(ctx, client, args, out)
+	case "show":
+		return showDeadLetter(ctx, client, args, out)
+	case "replay":
+		return resolveDeadLetter(ctx, client, command, "replayDeadLetter", args, out)
+	case "discard":
+		return resolveDeadLetter(ctx, client, command, "discardDeadLetter", args, out)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, deadLettersUsage)
+	}
+}
+
+func listDeadLetters(ctx context.Context, client *HasuraClient, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(out)
+	source := flags.String("source", "", "only dead letters of this source")
+	name := flags.String("name", "", "only dead letters of this trigger or event type")
+	status := flags.String("status", "open", "only dead letters in this status, empty for all")
+	limit := flags.Int("limit", 50, "maximum number of dead letters")
+	offset := flags.Int("offset", 0, "number of dead letters to skip")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var result struct {
+		DeadLetters struct {
+			DeadLetters []deadLetter `json:"dead_letters"`
+			Total       int64        `json:"total"`
+		} `json:"deadLetters"`
+	}
+	query := `query ($arg1: DeadLettersInput) { deadLetters(arg1: $arg1) { dead_letters { ` + deadLetterFields + ` } total } }`
+	variables := map[string]any{"arg1": map[string]any{
+		"source": *source,
+		"name":   *name,
+		"status": *status,
+		"limit":  *limit,
+		"offset": *offset,
+	}}
+	if err := client.Do(ctx, query, variables, &result); err != nil {
+		return fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSOURCE\tNAME\tSTATUS\tATTEMPTS\tREPLAYS\tUPDATED\tERROR")
+	for _, letter := range result.DeadLetters.DeadLetters {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			letter.ID, letter.Source, letter.Name, letter.Status, letter.Attempts, letter.ReplayCount,
+			letter.UpdatedAt.Format(time.RFC3339), truncate(letter.Error, 80))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d of %d dead letters\n", len(result.DeadLetters.DeadLetters), result.DeadLetters.Total)
+	return nil
+}
+
+func showDeadLetter(ctx context.Context, client *HasuraClient, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: app dlq show <id>")
+	}
+
+	var result struct {
+		DeadLetter deadLetter `json:"deadLetter"`
+	}
+	query := `query ($id: uuid!) { deadLetter(arg1: {id: $id}) { ` + deadLetterFields + ` payload } }`
+	if err := client.Do(ctx, query, map[string]any{"id": args[0]}, &result); err != nil {
+		return fmt.Errorf("failed to show dead letter: %w", err)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result.DeadLetter)
+}
+
+func resolveDeadLetter(ctx context.Context, client *HasuraClient, command, mutation string, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: app dlq %s <id>", command)
+	}
+
+	var result map[string]deadLetter
+	query := `mutation ($id: uuid!) { ` + mutation + `(arg1: {id: $id}) { ` + deadLetterFields + ` } }`
+	if err := client.Do(ctx, query, map[string]any{"id": args[0]}, &result); err != nil {
+		return fmt.Errorf("failed to %s dead letter: %w", command, err)
+	}
+
+	letter := result[mutation]
+	fmt.Fprintf(out, "%s %s (%s %s)\n", letter.ID, letter.Status, letter.Source, letter.Name)
+	return nil
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length-3]) + "..."
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HasuraClient runs GraphQL operations against Hasura with the admin secret,
+// so command line tools go through the same actions as the console.
+type HasuraClient struct {
+	endpoint    string
+	adminSecret string
+	client      *http.Client
+}
+
+func NewHasuraClient(endpoint, adminSecret string) *HasuraClient {
+	return &HasuraClient{
+		endpoint:    endpoint,
+		adminSecret: adminSecret,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// Do runs query and decodes its data into result.
+func (c *HasuraClient) Do(ctx context.Context, query string, variables map[string]any, result any) error {
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if c.adminSecret != "" {
+		request.Header.Set("X-Hasura-Admin-Secret", c.adminSecret)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", c.endpoint, err)
+	}
+	defer response.Body.Close()
+
+	var decoded graphQLResponse
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("invalid response from %s (%d): %w", c.endpoint, response.StatusCode, err)
+	}
+	if len(decoded.Errors) > 0 {
+		messages := make([]string, 0, len(decoded.Errors))
+		for _, graphQLErr := range decoded.Errors {
+			if graphQLErr.Extensions.Code != "" {
+				messages = append(messages, graphQLErr.Extensions.Code+": "+graphQLErr.Message)
+			} else {
+				messages = append(messages, graphQLErr.Message)
+			}
+		}
+		return errors.New(strings.Join(messages, "; "))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(decoded.Data, result)
+}
@@ -3,8 +3,12 @@ package config
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -15,17 +19,87 @@ import (
 )
 
 type Config struct {
-	DatabaseURL string
-	onceDB      sync.Once
+	DatabaseURL          string
+	WorkerCount          int
+	JobPollInterval      time.Duration
+	EventLedgerRetention time.Duration
+	RealtimeBroker       string
+	Outbox               Outbox
+	Maintenance          Maintenance
+	Pictures             Pictures
+	Storage              Storage
+	WebhookSecret        string
+	MetricsSecret        string
+	Hasura               Hasura
+	Server               Server
+	onceDB               sync.Once
 }
 
+type Server struct {
+	Addr           string
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+	ActionTimeout  time.Duration
+	ActionTimeouts map[string]time.Duration
+	TrustedProxies []netip.Prefix
+}
+
+type Outbox struct {
+	PollInterval    time.Duration
+	VisibilityDelay time.Duration
+	Sinks           []string
+	WebhookURL      string
+	WebhookSecret   string
+	NATSURL         string
+	NATSSubject     string
+}
+
+type Maintenance struct {
+	SoftDeleteRetention time.Duration
+	OrphanObjectGrace   time.Duration
+	TrendingWindow      time.Duration
+}
+
+// Pictures limits what recipe picture uploads may contain. Uploads are
+// stored re-encoded without metadata; KeepOriginal also keeps the file as
+// uploaded, EXIF and all. UploadExpiry is how long a presigned upload may
+// wait to be confirmed.
+type Pictures struct {
+	MaxBytes     int64
+	MaxDimension int
+	MaxPixels    int
+	KeepOriginal bool
+	UploadExpiry time.Duration
+}
+
+// Storage is where pictures and other objects are kept: "s3" in the MinIO
+// bucket, "local" in files under LocalDir, or "memory" within this process.
+type Storage struct {
+	Backend  string
+	LocalDir string
+}
+
+// Hasura is how command line tools reach the GraphQL API as admin.
+type Hasura struct {
+	Endpoint    string
+	AdminSecret string
+}
+
+// MinIO is the object store. PublicEndpoint is where clients reach it, and
+// so the host presigned URLs are signed for.
 type MinIO struct {
-	Endpoint  string
-	AccessKey string
-	SecretKey string
-	Bucket    string
-	client    *s3.Client
-	onceMinio sync.Once
+	Endpoint       string
+	PublicEndpoint string
+	AccessKey      string
+	SecretKey      string
+	Bucket         string
+	client         *s3.Client
+	presignClient  *s3.PresignClient
+	onceMinio      sync.Once
+	oncePresign    sync.Once
 }
 
 func NewConfig() (*Config, *MinIO, error) {
@@ -38,6 +112,10 @@ func NewConfig() (*Config, *MinIO, error) {
 	if minioEndpoint == "" {
 		minioEndpoint = "http://minio:9000"
 	}
+	minioPublicEndpoint := os.Getenv("MINIO_PUBLIC_ENDPOINT")
+	if minioPublicEndpoint == "" {
+		minioPublicEndpoint = minioEndpoint
+	}
 	minioAccessKey := os.Getenv("MINIO_ACCESS_KEY")
 	if minioAccessKey == "" {
 		minioAccessKey = "minioadmin"
@@ -51,14 +129,319 @@ func NewConfig() (*Config, *MinIO, error) {
 		minioBucket = "recipe-images"
 	}
 
-	return &Config{DatabaseURL: dsn}, &MinIO{
-		Endpoint:  minioEndpoint,
-		AccessKey: minioAccessKey,
-		SecretKey: minioSecretKey,
-		Bucket:    minioBucket,
+	workerCount, err := envInt("WORKER_COUNT", 4)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobPollInterval, err := envDuration("JOB_POLL_INTERVAL", 2*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventLedgerRetention, err := envDuration("EVENT_LEDGER_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// "postgres" fans realtime messages out across replicas, "memory" keeps
+	// them within this process.
+	realtimeBroker := os.Getenv("REALTIME_BROKER")
+	if realtimeBroker == "" {
+		realtimeBroker = "postgres"
+	}
+	if realtimeBroker != "postgres" && realtimeBroker != "memory" {
+		return nil, nil, fmt.Errorf("invalid REALTIME_BROKER %q: must be postgres or memory", realtimeBroker)
+	}
+
+	server, err := newServer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outbox, err := newOutbox()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maintenance, err := newMaintenance()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pictures, err := newPictures()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storage, err := newStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Config{
+		DatabaseURL:          dsn,
+		WorkerCount:          workerCount,
+		JobPollInterval:      jobPollInterval,
+		EventLedgerRetention: eventLedgerRetention,
+		RealtimeBroker:       realtimeBroker,
+		Outbox:               outbox,
+		Maintenance:          maintenance,
+		Pictures:             pictures,
+		Storage:              storage,
+		WebhookSecret:        os.Getenv("WEBHOOK_SECRET"),
+		MetricsSecret:        os.Getenv("METRICS_SECRET"),
+		Hasura:               newHasura(),
+		Server:               server,
+	}, &MinIO{
+		Endpoint:       minioEndpoint,
+		PublicEndpoint: minioPublicEndpoint,
+		AccessKey:      minioAccessKey,
+		SecretKey:      minioSecretKey,
+		Bucket:         minioBucket,
 	}, nil
 }
 
+func newHasura() Hasura {
+	hasura := Hasura{
+		Endpoint:    os.Getenv("HASURA_GRAPHQL_ENDPOINT"),
+		AdminSecret: os.Getenv("HASURA_GRAPHQL_ADMIN_SECRET"),
+	}
+	if hasura.Endpoint == "" {
+		hasura.Endpoint = "http://hasura:8080/v1/graphql"
+	}
+	return hasura
+}
+
+func newServer() (Server, error) {
+	server := Server{Addr: os.Getenv("SERVER_ADDR")}
+	if server.Addr == "" {
+		server.Addr = ":8080"
+	}
+
+	var err error
+	if server.ReadTimeout, err = envDuration("SERVER_READ_TIMEOUT", 15*time.Second); err != nil {
+		return server, err
+	}
+	if server.WriteTimeout, err = envDuration("SERVER_WRITE_TIMEOUT", 60*time.Second); err != nil {
+		return server, err
+	}
+	if server.IdleTimeout, err = envDuration("SERVER_IDLE_TIMEOUT", 120*time.Second); err != nil {
+		return server, err
+	}
+	if server.RequestTimeout, err = envDuration("REQUEST_TIMEOUT", 30*time.Second); err != nil {
+		return server, err
+	}
+	if server.RouteTimeouts, err = envDurationMap("ROUTE_TIMEOUTS"); err != nil {
+		return server, err
+	}
+	// Maintenance jobs scan whole tables and buckets.
+	if _, ok := server.RouteTimeouts["/cron"]; !ok {
+		server.RouteTimeouts["/cron"] = 5 * time.Minute
+	}
+	if server.ActionTimeout, err = envDuration("ACTION_TIMEOUT", 10*time.Second); err != nil {
+		return server, err
+	}
+	if server.ActionTimeouts, err = envDurationMap("ACTION_TIMEOUTS"); err != nil {
+		return server, err
+	}
+	// Asynchronous actions wait for their job; Hasura gives them 10 minutes.
+	if _, ok := server.ActionTimeouts["regenerateRecipePictures"]; !ok {
+		server.ActionTimeouts["regenerateRecipePictures"] = 10 * time.Minute
+	}
+	if server.TrustedProxies, err = envPrefixes("TRUSTED_PROXIES"); err != nil {
+		return server, err
+	}
+	return server, nil
+}
+
+// newOutbox reads the relay settings. OUTBOX_SINKS is a comma separated list
+// of "log", "webhook" and "nats"; the webhook sink needs OUTBOX_WEBHOOK_URL
+// and the NATS sink OUTBOX_NATS_URL. OUTBOX_VISIBILITY_DELAY is how long an
+// event waits before it is relayed, so that events written by transactions
+// still committing are not overtaken.
+func newOutbox() (Outbox, error) {
+	outbox := Outbox{
+		WebhookURL:    os.Getenv("OUTBOX_WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("OUTBOX_WEBHOOK_SECRET"),
+		NATSURL:       os.Getenv("OUTBOX_NATS_URL"),
+		NATSSubject:   os.Getenv("OUTBOX_NATS_SUBJECT"),
+	}
+	if outbox.NATSSubject == "" {
+		outbox.NATSSubject = "outbox"
+	}
+
+	var err error
+	if outbox.PollInterval, err = envDuration("OUTBOX_POLL_INTERVAL", time.Second); err != nil {
+		return outbox, err
+	}
+	if outbox.VisibilityDelay, err = envDuration("OUTBOX_VISIBILITY_DELAY", 5*time.Second); err != nil {
+		return outbox, err
+	}
+
+	sinks := os.Getenv("OUTBOX_SINKS")
+	if sinks == "" {
+		sinks = "log"
+	}
+	for _, sink := range strings.Split(sinks, ",") {
+		sink = strings.TrimSpace(sink)
+		switch sink {
+		case "":
+			continue
+		case "log":
+		case "webhook":
+			if outbox.WebhookURL == "" {
+				return outbox, fmt.Errorf("OUTBOX_WEBHOOK_URL is required by the webhook outbox sink")
+			}
+		case "nats":
+			if outbox.NATSURL == "" {
+				return outbox, fmt.Errorf("OUTBOX_NATS_URL is required by the nats outbox sink")
+			}
+		default:
+			return outbox, fmt.Errorf("invalid OUTBOX_SINKS entry %q: must be log, webhook or nats", sink)
+		}
+		outbox.Sinks = append(outbox.Sinks, sink)
+	}
+	return outbox, nil
+}
+
+func newMaintenance() (Maintenance, error) {
+	var maintenance Maintenance
+	var err error
+	if maintenance.SoftDeleteRetention, err = envDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour); err != nil {
+		return maintenance, err
+	}
+	if maintenance.OrphanObjectGrace, err = envDuration("ORPHAN_OBJECT_GRACE", 24*time.Hour); err != nil {
+		return maintenance, err
+	}
+	if maintenance.TrendingWindow, err = envDuration("TRENDING_WINDOW", 7*24*time.Hour); err != nil {
+		return maintenance, err
+	}
+	return maintenance, nil
+}
+
+func newPictures() (Pictures, error) {
+	var pictures Pictures
+	maxBytes, err := envInt("PICTURE_MAX_BYTES", 5<<20)
+	if err != nil {
+		return pictures, err
+	}
+	pictures.MaxBytes = int64(maxBytes)
+	if pictures.MaxDimension, err = envInt("PICTURE_MAX_DIMENSION", 8000); err != nil {
+		return pictures, err
+	}
+	// 40 megapixels decode to 160MB of RGBA.
+	if pictures.MaxPixels, err = envInt("PICTURE_MAX_PIXELS", 40_000_000); err != nil {
+		return pictures, err
+	}
+	if pictures.KeepOriginal, err = envBool("PICTURE_KEEP_ORIGINAL", false); err != nil {
+		return pictures, err
+	}
+	if pictures.UploadExpiry, err = envDuration("PICTURE_UPLOAD_EXPIRY", 15*time.Minute); err != nil {
+		return pictures, err
+	}
+	return pictures, nil
+}
+
+func newStorage() (Storage, error) {
+	storage := Storage{
+		Backend:  os.Getenv("STORAGE_BACKEND"),
+		LocalDir: os.Getenv("STORAGE_LOCAL_DIR"),
+	}
+	if storage.Backend == "" {
+		storage.Backend = "s3"
+	}
+	if storage.Backend != "s3" && storage.Backend != "local" && storage.Backend != "memory" {
+		return storage, fmt.Errorf("invalid STORAGE_BACKEND %q: must be s3, local or memory", storage.Backend)
+	}
+	if storage.LocalDir == "" {
+		storage.LocalDir = "data/objects"
+	}
+	return storage, nil
+}
+
+func envInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func envBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// envPrefixes parses a comma separated list of CIDRs and addresses, such as
+// "10.0.0.0/8,172.18.0.5".
+func envPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s entry %q: %w", key, entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", key, entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// envDurationMap parses values such as "signin=5s,signup=10s".
+func envDurationMap(key string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	value := os.Getenv(key)
+	if value == "" {
+		return durations, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s entry %q", key, entry)
+		}
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", key, entry, err)
+		}
+		durations[name] = parsed
+	}
+	return durations, nil
+}
+
 func NewDB(cfg *Config) (*gorm.DB, error) {
 	var db *gorm.DB
 	var err error
@@ -76,27 +459,7 @@ func NewDB(cfg *Config) (*gorm.DB, error) {
 func (m *MinIO) GetClient() (*s3.Client, error) {
 	var err error
 	m.onceMinio.Do(func() {
-		cfg, errCfg := config.LoadDefaultConfig(context.Background(),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(m.AccessKey, m.SecretKey, "")),
-			config.WithEndpointResolverWithOptions(
-				aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-					return aws.Endpoint{
-						URL:           m.Endpoint,
-						SigningRegion: "us-east-1",
-						Source:        aws.EndpointSourceCustom,
-					}, nil
-				}),
-			),
-			config.WithRegion("us-east-1"),
-		)
-		if errCfg != nil {
-			err = errCfg
-			return
-		}
-
-		m.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
-			o.UsePathStyle = true // Required for MinIO
-		})
+		m.client, err = m.newClient(m.Endpoint)
 	})
 
 	if err != nil {
@@ -107,3 +470,45 @@ func (m *MinIO) GetClient() (*s3.Client, error) {
 	}
 	return m.client, nil
 }
+
+// GetPresignClient signs requests clients send to MinIO themselves.
+func (m *MinIO) GetPresignClient() (*s3.PresignClient, error) {
+	var err error
+	m.oncePresign.Do(func() {
+		var client *s3.Client
+		if client, err = m.newClient(m.PublicEndpoint); err == nil {
+			m.presignClient = s3.NewPresignClient(client)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if m.presignClient == nil {
+		return nil, fmt.Errorf("failed to initialize MinIO presign client")
+	}
+	return m.presignClient, nil
+}
+
+func (m *MinIO) newClient(endpoint string) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(m.AccessKey, m.SecretKey, "")),
+		config.WithEndpointResolverWithOptions(
+			aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{
+					URL:           endpoint,
+					SigningRegion: "us-east-1",
+					Source:        aws.EndpointSourceCustom,
+				}, nil
+			}),
+		),
+		config.WithRegion("us-east-1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = true // Required for MinIO
+	}), nil
+}
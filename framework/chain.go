@@ -2,19 +2,35 @@ package framework
 
 import (
 	"app/utils"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Handler interface {
 	Handle(w http.ResponseWriter, r *http.Request, action HasuraAction)
 }
 
+// ErrSessionRevoked is returned by a SessionVerifier for a session whose
+// token has been revoked.
+var ErrSessionRevoked = errors.New("session revoked")
+
+// SessionVerifier rejects action sessions whose token is no longer valid.
+type SessionVerifier func(ctx context.Context, action HasuraAction) error
+
 type ActionDispatcher struct {
 	handlers       map[string]Handler
 	defaultHandler Handler
+	defaultTimeout time.Duration
+	timeouts       map[string]time.Duration
+	verifySession  SessionVerifier
 }
 
 var (
@@ -37,6 +53,30 @@ func (ad *ActionDispatcher) RegisterHandler(actionName string, handler Handler)
 	ad.handlers[strings.ToLower(actionName)] = handler
 }
 
+// SetTimeouts configures per-action deadlines, keyed by action name. Actions
+// without an entry use defaultTimeout; zero disables the deadline.
+func (ad *ActionDispatcher) SetTimeouts(defaultTimeout time.Duration, timeouts map[string]time.Duration) {
+	ad.defaultTimeout = defaultTimeout
+	ad.timeouts = make(map[string]time.Duration, len(timeouts))
+	for name, timeout := range timeouts {
+		ad.timeouts[strings.ToLower(name)] = timeout
+	}
+}
+
+// SetSessionVerifier checks the session of every action before its handler
+// runs. Hasura only checks a token's signature and expiry.
+func (ad *ActionDispatcher) SetSessionVerifier(verifier SessionVerifier) {
+	ad.verifySession = verifier
+}
+
+// RegisterAsyncHandler serves an asynchronous action by running processor on
+// the worker pool, see AsyncActionHandler. The job kind is the action name.
+func (ad *ActionDispatcher) RegisterAsyncHandler(actionName string, pool *WorkerPool, processor JobProcessor) {
+	kind := strings.ToLower(actionName)
+	pool.Register(kind, processor)
+	ad.RegisterHandler(actionName, NewAsyncActionHandler(pool.store, kind))
+}
+
 func (ad *ActionDispatcher) Handle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -46,13 +86,38 @@ func (ad *ActionDispatcher) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if ad.verifySession != nil {
+		err := ad.verifySession(r.Context(), action)
+		if errors.Is(err, ErrSessionRevoked) {
+			utils.WriteError(w, http.StatusUnauthorized, "SESSION_REVOKED", "Session is no longer valid, sign in again")
+			return
+		}
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "SESSION_CHECK_FAILED", "Failed to verify session: "+err.Error())
+			return
+		}
+	}
+
 	actionName := strings.ToLower(action.Action.Name)
 	handler, exists := ad.handlers[actionName]
 	if !exists {
 		handler = ad.defaultHandler
 	}
 
-	handler.Handle(w, r, action)
+	timeout, ok := ad.timeouts[actionName]
+	if !ok {
+		timeout = ad.defaultTimeout
+	}
+	if timeout <= 0 {
+		handler.Handle(w, r, action)
+		return
+	}
+
+	serveWithTimeout(w, r, timeout, func(w http.ResponseWriter) {
+		utils.WriteError(w, http.StatusGatewayTimeout, "TIMEOUT", "Action "+action.Action.Name+" timed out")
+	}, func(w http.ResponseWriter, r *http.Request) {
+		handler.Handle(w, r, action)
+	})
 }
 
 type HasuraAction struct {
@@ -62,3 +127,18 @@ type HasuraAction struct {
 	Input            json.RawMessage   `json:"input"`
 	SessionVariables map[string]string `json:"session_variables"`
 }
+
+func (a HasuraAction) UserID() (uuid.UUID, error) {
+	return uuid.Parse(a.SessionVariables["x-hasura-user-id"])
+}
+
+func (a HasuraAction) Role() string {
+	return a.SessionVariables["x-hasura-role"]
+}
+
+// TokenVersion is the version of the token the session was taken from.
+// Tokens issued before versions were added carry none, which reads as 0.
+func (a HasuraAction) TokenVersion() int {
+	version, _ := strconv.Atoi(a.SessionVariables["x-hasura-token-version"])
+	return version
+}
@@ -0,0 +1,137 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"app/models"
+	"app/utils"
+)
+
+var ErrUnknownCronJob = errors.New("unknown cron job")
+
+// CronEvent is the body Hasura posts when a cron trigger fires.
+type CronEvent struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	ScheduledTime time.Time       `json:"scheduled_time"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// CronJobFunc runs one maintenance job and returns a summary of what it did,
+// which is stored with the run.
+type CronJobFunc func(ctx context.Context) (any, error)
+
+type CronRunStore interface {
+	Start(ctx context.Context, run *models.CronRun) error
+	Finish(ctx context.Context, run *models.CronRun) error
+}
+
+// CronRegistry maps cron trigger names to jobs.
+type CronRegistry struct {
+	mu    sync.RWMutex
+	jobs  map[string]CronJobFunc
+	store CronRunStore
+}
+
+var (
+	cronRegistrySingleton *CronRegistry
+	cronRegistryOnce      sync.Once
+)
+
+func GetCronRegistry() *CronRegistry {
+	cronRegistryOnce.Do(func() {
+		cronRegistrySingleton = &CronRegistry{jobs: make(map[string]CronJobFunc)}
+	})
+
+	return cronRegistrySingleton
+}
+
+func (c *CronRegistry) Register(name string, job CronJobFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jobs[name] = job
+}
+
+func (c *CronRegistry) SetStore(store CronRunStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// Run executes the named job and records the run with its duration and
+// outcome. A failure to record the run is logged, not returned.
+func (c *CronRegistry) Run(ctx context.Context, event CronEvent) (*models.CronRun, error) {
+	c.mu.RLock()
+	job, ok := c.jobs[event.Name]
+	store := c.store
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCronJob, event.Name)
+	}
+
+	run := &models.CronRun{Name: event.Name, CronEventID: event.ID, StartedAt: time.Now()}
+	if !event.ScheduledTime.IsZero() {
+		run.ScheduledTime = &event.ScheduledTime
+	}
+	if store != nil {
+		if err := store.Start(ctx, run); err != nil {
+			log.Printf("Failed to record start of cron job %s: %v", event.Name, err)
+		}
+	}
+
+	result, err := job(ctx)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status = models.CronRunStatusSucceeded
+	if err != nil {
+		run.Status = models.CronRunStatusFailed
+		run.Error = err.Error()
+	}
+	if encoded, encodeErr := json.Marshal(result); encodeErr == nil {
+		run.Result = encoded
+	}
+	log.Printf("Cron job %s %s in %dms", event.Name, run.Status, run.DurationMs)
+
+	if store != nil {
+		// The run is recorded even if the request was cancelled meanwhile.
+		if err := store.Finish(context.WithoutCancel(ctx), run); err != nil {
+			log.Printf("Failed to record end of cron job %s: %v", event.Name, err)
+		}
+	}
+	return run, err
+}
+
+func (c *CronRegistry) Handle(w http.ResponseWriter, r *http.Request) {
+	var event CronEvent
+	if err := utils.DecodeJSON(r, &event); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_CRON_PAYLOAD", "Invalid cron payload: "+err.Error())
+		return
+	}
+
+	run, err := c.Run(r.Context(), event)
+	if errors.Is(err, ErrUnknownCronJob) {
+		utils.WriteError(w, http.StatusNotFound, "UNKNOWN_CRON_JOB", "No job registered for cron trigger "+event.Name)
+		return
+	}
+	// Hasura retries the trigger on a non-2xx response.
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "CRON_JOB_FAILED", "Cron job "+event.Name+" failed: "+err.Error())
+		return
+	}
+
+	utils.EncodeJSON(w, map[string]any{
+		"name":        run.Name,
+		"status":      run.Status,
+		"duration_ms": run.DurationMs,
+		"result":      run.Result,
+	})
+}
@@ -0,0 +1,76 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"app/models"
+)
+
+type fakeCronRunStore struct {
+	started, finished []models.CronRun
+}
+
+func (s *fakeCronRunStore) Start(_ context.Context, run *models.CronRun) error {
+	s.started = append(s.started, *run)
+	return nil
+}
+
+func (s *fakeCronRunStore) Finish(_ context.Context, run *models.CronRun) error {
+	s.finished = append(s.finished, *run)
+	return nil
+}
+
+func TestCronRegistryHandle(t *testing.T) {
+	store := &fakeCronRunStore{}
+	registry := &CronRegistry{jobs: make(map[string]CronJobFunc)}
+	registry.SetStore(store)
+	registry.Register("reconcile_counts", func(context.Context) (any, error) {
+		return map[string]int{"count": 3}, nil
+	})
+	registry.Register("purge_soft_deleted", func(context.Context) (any, error) {
+		return nil, errors.New("database unavailable")
+	})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		registry.Handle(recorder, httptest.NewRequest(http.MethodPost, "/cron", strings.NewReader(body)))
+		return recorder
+	}
+
+	recorder := post(`{"id":"evt-1","name":"reconcile_counts","scheduled_time":"2026-01-01T03:00:00Z"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("succeeded job = %d: %s", recorder.Code, recorder.Body)
+	}
+	var response struct {
+		Status string          `json:"status"`
+		Result json.RawMessage `json:"result"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	if response.Status != models.CronRunStatusSucceeded || string(response.Result) != `{"count":3}` {
+		t.Errorf("response = %s", recorder.Body)
+	}
+
+	if recorder := post(`{"id":"evt-2","name":"purge_soft_deleted"}`); recorder.Code != http.StatusInternalServerError {
+		t.Errorf("failed job = %d, want 500 so Hasura retries", recorder.Code)
+	}
+	if recorder := post(`{"id":"evt-3","name":"unknown"}`); recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown job = %d, want 404", recorder.Code)
+	}
+
+	if len(store.started) != 2 || len(store.finished) != 2 {
+		t.Fatalf("recorded %d starts and %d finishes, want 2 each", len(store.started), len(store.finished))
+	}
+	succeeded, failed := store.finished[0], store.finished[1]
+	if succeeded.CronEventID != "evt-1" || succeeded.ScheduledTime == nil || succeeded.FinishedAt == nil {
+		t.Errorf("succeeded run = %+v", succeeded)
+	}
+	if failed.Status != models.CronRunStatusFailed || failed.Error != "database unavailable" {
+		t.Errorf("failed run = %+v", failed)
+	}
+}
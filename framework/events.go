@@ -0,0 +1,253 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"app/models"
+	"app/utils"
+
+	"github.com/google/uuid"
+
+	"gorm.io/gorm/schema"
+)
+
+const (
+	OpInsert = "INSERT"
+	OpUpdate = "UPDATE"
+	OpDelete = "DELETE"
+	OpManual = "MANUAL"
+)
+
+var ErrUnknownEvent = errors.New("no handler registered for event")
+
+type HasuraEvent struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	Trigger   struct {
+		Name string `json:"name"`
+	} `json:"trigger"`
+	Table struct {
+		Schema string `json:"schema"`
+		Name   string `json:"name"`
+	} `json:"table"`
+	Event struct {
+		Op               string            `json:"op"`
+		SessionVariables map[string]string `json:"session_variables"`
+		Data             struct {
+			Old json.RawMessage `json:"old"`
+			New json.RawMessage `json:"new"`
+		} `json:"data"`
+	} `json:"event"`
+	DeliveryInfo struct {
+		CurrentRetry int `json:"current_retry"`
+		MaxRetries   int `json:"max_retries"`
+	} `json:"delivery_info"`
+}
+
+// UserID is the user whose request caused the change, if any.
+func (e *HasuraEvent) UserID() (uuid.UUID, error) {
+	return uuid.Parse(e.Event.SessionVariables["x-hasura-user-id"])
+}
+
+func (e *HasuraEvent) Role() string {
+	return e.Event.SessionVariables["x-hasura-role"]
+}
+
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event *HasuraEvent) error
+}
+
+type EventHandlerFunc func(ctx context.Context, event *HasuraEvent) error
+
+func (f EventHandlerFunc) HandleEvent(ctx context.Context, event *HasuraEvent) error {
+	return f(ctx, event)
+}
+
+// Change is an event whose row data has been decoded into the table's model.
+// Old is nil for INSERT and New is nil for DELETE.
+type Change[T any] struct {
+	Op    string
+	Old   *T
+	New   *T
+	Event *HasuraEvent
+}
+
+// EventLedger makes handlers idempotent across Hasura's at-least-once
+// deliveries, see repositories.EventLedgerRepository.
+type EventLedger interface {
+	RunOnce(ctx context.Context, eventID, triggerName string, fn func(ctx context.Context) error) (bool, error)
+}
+
+// DeadLetterStore keeps events that failed their last delivery attempt, see
+// repositories.DeadLetterRepository.
+type DeadLetterStore interface {
+	Add(ctx context.Context, letter *models.DeadLetter) error
+}
+
+type EventDispatcher struct {
+	handlers    map[string]EventHandler
+	ledger      EventLedger
+	deadLetters DeadLetterStore
+}
+
+var (
+	eventDispatcherSingleton *EventDispatcher
+	eventDispatcherOnce      sync.Once
+)
+
+func GetEventDispatcher() *EventDispatcher {
+	eventDispatcherOnce.Do(func() {
+		eventDispatcherSingleton = &EventDispatcher{
+			handlers: make(map[string]EventHandler),
+		}
+	})
+
+	return eventDispatcherSingleton
+}
+
+func eventKey(triggerName, table string) string {
+	return triggerName + "/" + table
+}
+
+func (ed *EventDispatcher) RegisterHandler(triggerName, table string, handler EventHandler) {
+	ed.handlers[eventKey(triggerName, table)] = handler
+}
+
+// RegisterEventHandler registers a handler that receives the event rows
+// decoded into T, which must be a GORM model of the trigger's table.
+func RegisterEventHandler[T any](ed *EventDispatcher, triggerName, table string, handler func(ctx context.Context, change Change[T]) error) {
+	ed.RegisterHandler(triggerName, table, EventHandlerFunc(func(ctx context.Context, event *HasuraEvent) error {
+		change := Change[T]{Op: event.Event.Op, Event: event}
+
+		var err error
+		if change.Old, err = decodeRow[T](event.Event.Data.Old); err != nil {
+			return fmt.Errorf("failed to decode old row: %w", err)
+		}
+		if change.New, err = decodeRow[T](event.Event.Data.New); err != nil {
+			return fmt.Errorf("failed to decode new row: %w", err)
+		}
+		return handler(ctx, change)
+	}))
+}
+
+func (ed *EventDispatcher) SetLedger(ledger EventLedger) {
+	ed.ledger = ledger
+}
+
+func (ed *EventDispatcher) SetDeadLetters(store DeadLetterStore) {
+	ed.deadLetters = store
+}
+
+// Dispatch runs the handler registered for the event's trigger and table.
+// With a ledger set, the handler runs in a transaction that records the
+// event id, and events already recorded are skipped.
+func (ed *EventDispatcher) Dispatch(ctx context.Context, event *HasuraEvent) error {
+	handler, exists := ed.handlers[eventKey(event.Trigger.Name, event.Table.Name)]
+	if !exists {
+		return fmt.Errorf("%w: %s on %s", ErrUnknownEvent, event.Trigger.Name, event.Table.Name)
+	}
+	if ed.ledger == nil {
+		return handler.HandleEvent(ctx, event)
+	}
+
+	duplicate, err := ed.ledger.RunOnce(ctx, event.ID, event.Trigger.Name, func(ctx context.Context) error {
+		return handler.HandleEvent(ctx, event)
+	})
+	if duplicate {
+		log.Printf("Skipping already processed event %s (%s)", event.ID, event.Trigger.Name)
+	}
+	return err
+}
+
+// Handle answers Hasura event trigger deliveries. Any non-2xx status makes
+// Hasura retry the delivery according to the trigger's retry_conf; when the
+// last retry fails too, the event is kept as a dead letter.
+func (ed *EventDispatcher) Handle(w http.ResponseWriter, r *http.Request) {
+	var event HasuraEvent
+	if err := utils.DecodeJSON(r, &event); err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "INVALID_EVENT_PAYLOAD", "Invalid event payload: "+err.Error())
+		return
+	}
+
+	if _, exists := ed.handlers[eventKey(event.Trigger.Name, event.Table.Name)]; !exists {
+		utils.WriteProblem(w, r, http.StatusNotFound, "UNKNOWN_EVENT", "No handler for trigger "+event.Trigger.Name+" on table "+event.Table.Name)
+		return
+	}
+
+	if err := ed.Dispatch(r.Context(), &event); err != nil {
+		if event.DeliveryInfo.CurrentRetry >= event.DeliveryInfo.MaxRetries {
+			ed.deadLetter(r.Context(), &event, err)
+		}
+		utils.WriteProblem(w, r, http.StatusInternalServerError, "EVENT_HANDLER_FAILED", "Failed to handle event "+event.ID+": "+err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ed *EventDispatcher) deadLetter(ctx context.Context, event *HasuraEvent, cause error) {
+	if ed.deadLetters == nil {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode dead letter for event %s: %v", event.ID, err)
+		return
+	}
+	letter := &models.DeadLetter{
+		Source:    models.DeadLetterSourceEvent,
+		Name:      event.Trigger.Name,
+		Reference: event.ID,
+		Payload:   payload,
+		Error:     cause.Error(),
+		Attempts:  event.DeliveryInfo.CurrentRetry + 1,
+	}
+	// The request may have run out of time, which is often why it failed.
+	if err := ed.deadLetters.Add(context.WithoutCancel(ctx), letter); err != nil {
+		log.Printf("Failed to record dead letter for event %s: %v", event.ID, err)
+	}
+}
+
+var modelSchemas sync.Map
+
+// decodeRow maps Hasura's column-keyed row onto the model's fields using the
+// same column names GORM uses, so models need no json tags.
+func decodeRow[T any](raw json.RawMessage) (*T, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var columns map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &columns); err != nil {
+		return nil, err
+	}
+
+	row := new(T)
+	modelSchema, err := schema.Parse(row, &modelSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(columns))
+	for column, value := range columns {
+		if field := modelSchema.LookUpField(column); field != nil {
+			fields[field.Name] = value
+		}
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
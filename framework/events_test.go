@@ -0,0 +1,180 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"app/models"
+
+	"github.com/google/uuid"
+)
+
+type eventRow struct {
+	ID        uuid.UUID
+	Title     string
+	LikeCount int
+}
+
+func newTestEvent(trigger, table, op, old, new string) *HasuraEvent {
+	event := &HasuraEvent{ID: uuid.NewString()}
+	event.Trigger.Name = trigger
+	event.Table.Name = table
+	event.Event.Op = op
+	event.Event.Data.Old = []byte(old)
+	event.Event.Data.New = []byte(new)
+	return event
+}
+
+func TestEventDispatcherRoutesByTriggerAndTable(t *testing.T) {
+	dispatcher := &EventDispatcher{handlers: make(map[string]EventHandler)}
+	var changes []Change[eventRow]
+	RegisterEventHandler(dispatcher, "recipe_changed", "recipe", func(_ context.Context, change Change[eventRow]) error {
+		changes = append(changes, change)
+		return nil
+	})
+	dispatcher.RegisterHandler("recipe_changed", "comment", EventHandlerFunc(func(context.Context, *HasuraEvent) error {
+		return errors.New("comment handler called")
+	}))
+
+	id := uuid.New()
+	event := newTestEvent("recipe_changed", "recipe", OpUpdate,
+		`{"id":"`+id.String()+`","title":"Soup","like_count":1,"unknown_column":true}`,
+		`{"id":"`+id.String()+`","title":"Soup","like_count":2}`)
+	if err := dispatcher.Dispatch(context.Background(), event); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("handler ran %d times, want once", len(changes))
+	}
+	change := changes[0]
+	if change.Op != OpUpdate || change.Old.LikeCount != 1 || change.New.LikeCount != 2 || change.New.ID != id || change.New.Title != "Soup" {
+		t.Errorf("change = %+v old %+v new %+v", change, change.Old, change.New)
+	}
+
+	deleted := newTestEvent("recipe_changed", "recipe", OpDelete, `{"id":"`+id.String()+`"}`, "null")
+	if err := dispatcher.Dispatch(context.Background(), deleted); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if changes[1].New != nil || changes[1].Old == nil {
+		t.Errorf("delete decoded as old %v new %v", changes[1].Old, changes[1].New)
+	}
+
+	unknown := newTestEvent("recipe_changed", "user", OpInsert, "", "{}")
+	if err := dispatcher.Dispatch(context.Background(), unknown); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("unknown table: err = %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestEventDispatcherHandle(t *testing.T) {
+	dispatcher := &EventDispatcher{handlers: make(map[string]EventHandler)}
+	dispatcher.RegisterHandler("recipe_changed", "recipe", EventHandlerFunc(func(_ context.Context, event *HasuraEvent) error {
+		if event.Event.Op == OpDelete {
+			return errors.New("cannot handle deletes")
+		}
+		return nil
+	}))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		dispatcher.Handle(recorder, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+		return recorder
+	}
+	if recorder := post(`{"id":"` + uuid.NewString() + `","trigger":{"name":"recipe_changed"},"table":{"name":"recipe"},"event":{"op":"INSERT","data":{"new":{}}}}`); recorder.Code != http.StatusOK {
+		t.Errorf("handled event = %d, want 200", recorder.Code)
+	}
+	if recorder := post(`{"trigger":{"name":"other"},"table":{"name":"recipe"}}`); recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown trigger = %d, want 404", recorder.Code)
+	}
+	if recorder := post(`{"id":"` + uuid.NewString() + `","trigger":{"name":"recipe_changed"},"table":{"name":"recipe"},"event":{"op":"DELETE"}}`); recorder.Code != http.StatusInternalServerError {
+		t.Errorf("failed event = %d, want 500 so Hasura retries", recorder.Code)
+	}
+	if recorder := post(`not json`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("invalid payload = %d, want 400", recorder.Code)
+	}
+}
+
+// fakeLedger remembers event ids and, like the database ledger, forgets one
+// again when its handler fails.
+type fakeLedger struct {
+	processed map[string]bool
+}
+
+func (l *fakeLedger) RunOnce(ctx context.Context, eventID, _ string, fn func(ctx context.Context) error) (bool, error) {
+	if l.processed[eventID] {
+		return true, nil
+	}
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+	l.processed[eventID] = true
+	return false, nil
+}
+
+func TestEventDispatcherSkipsDuplicates(t *testing.T) {
+	dispatcher := &EventDispatcher{handlers: make(map[string]EventHandler)}
+	dispatcher.SetLedger(&fakeLedger{processed: make(map[string]bool)})
+	runs := 0
+	fail := true
+	dispatcher.RegisterHandler("recipe_liked", "liked_recipe", EventHandlerFunc(func(context.Context, *HasuraEvent) error {
+		runs++
+		if fail {
+			return errors.New("database unavailable")
+		}
+		return nil
+	}))
+
+	event := newTestEvent("recipe_liked", "liked_recipe", OpInsert, "", "{}")
+	if err := dispatcher.Dispatch(context.Background(), event); err == nil {
+		t.Fatal("a failed handler was reported as handled")
+	}
+	fail = false
+	for i := 0; i < 2; i++ {
+		if err := dispatcher.Dispatch(context.Background(), event); err != nil {
+			t.Fatalf("redelivery %d: %v", i, err)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("handler ran %d times, want a retry after the failure and no run for the duplicate", runs)
+	}
+}
+
+type fakeDeadLetterStore struct {
+	letters []*models.DeadLetter
+}
+
+func (s *fakeDeadLetterStore) Add(_ context.Context, letter *models.DeadLetter) error {
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+func TestEventDispatcherDeadLettersLastRetry(t *testing.T) {
+	dispatcher := &EventDispatcher{handlers: make(map[string]EventHandler)}
+	deadLetters := &fakeDeadLetterStore{}
+	dispatcher.SetDeadLetters(deadLetters)
+	dispatcher.RegisterHandler("recipe_rated", "rating", EventHandlerFunc(func(context.Context, *HasuraEvent) error {
+		return errors.New("recipe is gone")
+	}))
+
+	deliver := func(retry int) {
+		body := `{"id":"` + uuid.NewString() + `","trigger":{"name":"recipe_rated"},"table":{"name":"rating"},"delivery_info":{"current_retry":` + strconv.Itoa(retry) + `,"max_retries":2}}`
+		dispatcher.Handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+	}
+	deliver(0)
+	deliver(1)
+	if len(deadLetters.letters) != 0 {
+		t.Fatal("an event with retries left was dead-lettered")
+	}
+	deliver(2)
+	if len(deadLetters.letters) != 1 {
+		t.Fatalf("%d dead letters after the last retry, want 1", len(deadLetters.letters))
+	}
+	letter := deadLetters.letters[0]
+	if letter.Source != models.DeadLetterSourceEvent || letter.Name != "recipe_rated" || letter.Attempts != 3 || letter.Error != "recipe is gone" {
+		t.Errorf("dead letter = %+v", letter)
+	}
+}
@@ -0,0 +1,152 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+type Message struct {
+	Topic   string          `json:"topic"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Broker relays hub messages between app replicas. Every published message
+// comes back through Listen, including on the replica that published it.
+type Broker interface {
+	Publish(ctx context.Context, message Message) error
+	Listen(ctx context.Context, deliver func(Message)) error
+}
+
+const subscriptionBuffer = 32
+
+type Subscription struct {
+	C      chan Message
+	hub    *Hub
+	mu     sync.Mutex
+	topics map[string]struct{}
+	closed bool
+}
+
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+	broker      Broker
+}
+
+var (
+	hubSingleton *Hub
+	hubOnce      sync.Once
+)
+
+func GetHub() *Hub {
+	hubOnce.Do(func() {
+		hubSingleton = &Hub{subscribers: make(map[string]map[*Subscription]struct{})}
+	})
+
+	return hubSingleton
+}
+
+// UseBroker routes published messages through broker so that subscribers on
+// every replica receive them. It blocks while listening, so run it in its own
+// goroutine.
+func (h *Hub) UseBroker(ctx context.Context, broker Broker) {
+	h.mu.Lock()
+	h.broker = broker
+	h.mu.Unlock()
+
+	if err := broker.Listen(ctx, h.deliver); err != nil && ctx.Err() == nil {
+		log.Printf("Realtime broker stopped: %v", err)
+	}
+}
+
+func (h *Hub) Publish(ctx context.Context, topic, messageType string, payload any) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	message := Message{Topic: topic, Type: messageType, Payload: encoded}
+
+	h.mu.RLock()
+	broker := h.broker
+	h.mu.RUnlock()
+	if broker != nil {
+		return broker.Publish(ctx, message)
+	}
+
+	h.deliver(message)
+	return nil
+}
+
+// deliver never blocks on a slow subscriber; messages that do not fit in its
+// buffer are dropped.
+func (h *Hub) deliver(message Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for subscription := range h.subscribers[message.Topic] {
+		select {
+		case subscription.C <- message:
+		default:
+			log.Printf("Dropping %s message on %s for a slow subscriber", message.Type, message.Topic)
+		}
+	}
+}
+
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	subscription := &Subscription{
+		C:      make(chan Message, subscriptionBuffer),
+		hub:    h,
+		topics: make(map[string]struct{}),
+	}
+	for _, topic := range topics {
+		subscription.Add(topic)
+	}
+	return subscription
+}
+
+func (s *Subscription) Add(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.topics[topic] = struct{}{}
+
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.hub.subscribers[topic] == nil {
+		s.hub.subscribers[topic] = make(map[*Subscription]struct{})
+	}
+	s.hub.subscribers[topic][s] = struct{}{}
+}
+
+func (s *Subscription) Remove(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.topics, topic)
+	s.hub.unsubscribe(topic, s)
+}
+
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for topic := range s.topics {
+		s.hub.unsubscribe(topic, s)
+	}
+}
+
+func (h *Hub) unsubscribe(topic string, subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers[topic], subscription)
+	if len(h.subscribers[topic]) == 0 {
+		delete(h.subscribers, topic)
+	}
+}
@@ -0,0 +1,88 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestHubDeliversToTopicSubscribers(t *testing.T) {
+	hub := &Hub{subscribers: make(map[string]map[*Subscription]struct{})}
+	recipe := hub.Subscribe("recipe:1")
+	other := hub.Subscribe("recipe:2")
+	defer other.Close()
+
+	if err := hub.Publish(context.Background(), "recipe:1", "recipe_updated", map[string]int{"like_count": 3}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	select {
+	case message := <-recipe.C:
+		if message.Type != "recipe_updated" || string(message.Payload) != `{"like_count":3}` {
+			t.Errorf("message = %+v", message)
+		}
+	default:
+		t.Fatal("the subscriber got nothing")
+	}
+	if len(other.C) != 0 {
+		t.Error("a subscriber to another topic got the message")
+	}
+
+	recipe.Close()
+	hub.Publish(context.Background(), "recipe:1", "recipe_updated", nil)
+	if len(recipe.C) != 0 {
+		t.Error("a closed subscription still gets messages")
+	}
+	if _, exists := hub.subscribers["recipe:1"]; exists {
+		t.Error("the topic outlived its last subscriber")
+	}
+}
+
+func TestHubDropsMessagesForSlowSubscribers(t *testing.T) {
+	hub := &Hub{subscribers: make(map[string]map[*Subscription]struct{})}
+	slow := hub.Subscribe("user:1")
+	defer slow.Close()
+
+	for i := 0; i < subscriptionBuffer+5; i++ {
+		hub.Publish(context.Background(), "user:1", "notification", i)
+	}
+	if len(slow.C) != subscriptionBuffer {
+		t.Errorf("%d messages buffered, want %d", len(slow.C), subscriptionBuffer)
+	}
+}
+
+// loopbackBroker hands every published message straight back, as a broker
+// shared by the replicas does.
+type loopbackBroker struct {
+	deliver func(Message)
+	ready   chan struct{}
+}
+
+func (b *loopbackBroker) Publish(_ context.Context, message Message) error {
+	b.deliver(message)
+	return nil
+}
+
+func (b *loopbackBroker) Listen(ctx context.Context, deliver func(Message)) error {
+	b.deliver = deliver
+	close(b.ready)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHubPublishesThroughBroker(t *testing.T) {
+	hub := &Hub{subscribers: make(map[string]map[*Subscription]struct{})}
+	subscription := hub.Subscribe("user:1")
+	defer subscription.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := &loopbackBroker{ready: make(chan struct{})}
+	go hub.UseBroker(ctx, broker)
+	<-broker.ready
+
+	hub.Publish(ctx, "user:1", "notification", json.RawMessage(`{"id":"1"}`))
+	message := <-subscription.C
+	if string(message.Payload) != `{"id":"1"}` {
+		t.Errorf("payload = %s", message.Payload)
+	}
+}
@@ -0,0 +1,298 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"app/models"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type JobStore interface {
+	Enqueue(ctx context.Context, job *models.Job) error
+	ClaimNext(ctx context.Context, kinds []string, lease time.Duration) (*models.Job, error)
+	Extend(ctx context.Context, id uuid.UUID) error
+	Complete(ctx context.Context, id uuid.UUID, result json.RawMessage) error
+	Fail(ctx context.Context, id uuid.UUID, message string, retryAt *time.Time) error
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Job, error)
+}
+
+type JobProcessor interface {
+	Process(ctx context.Context, job *models.Job) (any, error)
+}
+
+type JobProcessorFunc func(ctx context.Context, job *models.Job) (any, error)
+
+func (f JobProcessorFunc) Process(ctx context.Context, job *models.Job) (any, error) {
+	return f(ctx, job)
+}
+
+// permanentError is a job failure retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one retrying cannot fix, such as invalid input or a
+// missing permission, so the job fails at once.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+const (
+	// jobLease is how long a job may go without a heartbeat before another
+	// worker takes it over. Workers renew it every jobHeartbeat while
+	// processing, so it does not bound how long a job runs.
+	jobLease          = 5 * time.Minute
+	jobHeartbeat      = jobLease / 3
+	jobRetryBase      = 10 * time.Second
+	jobRetryCeiling   = 10 * time.Minute
+	asyncPollInterval = 500 * time.Millisecond
+)
+
+type WorkerPool struct {
+	store        JobStore
+	workers      int
+	pollInterval time.Duration
+	mu           sync.RWMutex
+	processors   map[string]JobProcessor
+}
+
+var (
+	workerPoolSingleton *WorkerPool
+	workerPoolOnce      sync.Once
+)
+
+func GetWorkerPool(store JobStore, workers int, pollInterval time.Duration) *WorkerPool {
+	workerPoolOnce.Do(func() {
+		workerPoolSingleton = &WorkerPool{
+			store:        store,
+			workers:      workers,
+			pollInterval: pollInterval,
+			processors:   make(map[string]JobProcessor),
+		}
+	})
+
+	return workerPoolSingleton
+}
+
+func (p *WorkerPool) Register(kind string, processor JobProcessor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processors[strings.ToLower(kind)] = processor
+}
+
+func (p *WorkerPool) Enqueue(ctx context.Context, job *models.Job) error {
+	return p.store.Enqueue(ctx, job)
+}
+
+func (p *WorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		go p.work(ctx)
+	}
+}
+
+func (p *WorkerPool) work(ctx context.Context) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going back to sleep.
+		for p.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *WorkerPool) kinds() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	kinds := make([]string, 0, len(p.processors))
+	for kind := range p.processors {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+func (p *WorkerPool) processor(kind string) JobProcessor {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.processors[kind]
+}
+
+func (p *WorkerPool) runNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := p.store.ClaimNext(ctx, p.kinds(), jobLease)
+	if err != nil {
+		log.Printf("Failed to claim job: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	result, err := p.process(ctx, job)
+	if err != nil {
+		var retryAt *time.Time
+		var permanent permanentError
+		if job.Attempts < job.MaxAttempts && !errors.As(err, &permanent) {
+			next := time.Now().Add(retryDelay(job.Attempts))
+			retryAt = &next
+		}
+		log.Printf("Job %s (%s) failed on attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+		if err := p.store.Fail(ctx, job.ID, err.Error(), retryAt); err != nil {
+			log.Printf("Failed to record failure of job %s: %v", job.ID, err)
+		}
+		return true
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to encode result of job %s: %v", job.ID, err)
+		p.store.Fail(ctx, job.ID, "failed to encode result: "+err.Error(), nil)
+		return true
+	}
+	if err := p.store.Complete(ctx, job.ID, encoded); err != nil {
+		log.Printf("Failed to complete job %s: %v", job.ID, err)
+	}
+	return true
+}
+
+// process runs the job's processor, renewing its lease meanwhile. A panic
+// fails the job for good instead of taking the server down.
+func (p *WorkerPool) process(ctx context.Context, job *models.Job) (result any, err error) {
+	done := make(chan struct{})
+	defer close(done)
+	go p.heartbeat(ctx, job.ID, done)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("Job %s (%s) panicked: %v\n%s", job.ID, job.Kind, recovered, debug.Stack())
+			err = Permanent(fmt.Errorf("job panicked: %v", recovered))
+		}
+	}()
+	return p.processor(job.Kind).Process(ctx, job)
+}
+
+func (p *WorkerPool) heartbeat(ctx context.Context, id uuid.UUID, done <-chan struct{}) {
+	ticker := time.NewTicker(jobHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := p.store.Extend(ctx, id); err != nil {
+			log.Printf("Failed to extend lease of job %s: %v", id, err)
+		}
+	}
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := jobRetryBase << (attempt - 1)
+	if delay <= 0 || delay > jobRetryCeiling {
+		return jobRetryCeiling
+	}
+	return delay
+}
+
+// AsyncActionHandler serves Hasura actions of kind asynchronous. Hasura
+// answers the client with its own action id and calls the handler in the
+// background, storing whatever it returns as the action's output for the
+// client's subscription. The handler runs the action as a job, so it shares
+// the worker pool's concurrency and retries, and responds with the job's
+// result once it finishes.
+type AsyncActionHandler struct {
+	store JobStore
+	kind  string
+}
+
+func NewAsyncActionHandler(store JobStore, kind string) *AsyncActionHandler {
+	return &AsyncActionHandler{store: store, kind: kind}
+}
+
+func (h *AsyncActionHandler) Handle(w http.ResponseWriter, r *http.Request, action HasuraAction) {
+	session, err := json.Marshal(action.SessionVariables)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid session variables: "+err.Error())
+		return
+	}
+
+	input := action.Input
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	job := &models.Job{
+		Kind:        h.kind,
+		Input:       input,
+		Session:     session,
+		MaxAttempts: 3,
+	}
+	if userID, err := action.UserID(); err == nil {
+		job.UserID = &userID
+	}
+
+	if err := h.store.Enqueue(r.Context(), job); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "JOB_ENQUEUE_FAILED", "Failed to enqueue job: "+err.Error())
+		return
+	}
+
+	finished, err := h.wait(r.Context(), job.ID)
+	if err != nil {
+		// Past the action deadline the dispatcher reports a timeout instead.
+		utils.WriteError(w, http.StatusInternalServerError, "JOB_UNFINISHED", "Job "+job.ID.String()+" did not finish: "+err.Error())
+		return
+	}
+	if finished.Status == models.JobStatusFailed {
+		utils.WriteError(w, http.StatusBadRequest, "JOB_FAILED", finished.Error)
+		return
+	}
+	w.Write(finished.Result)
+}
+
+// wait polls the job until it has succeeded or failed for good.
+func (h *AsyncActionHandler) wait(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	ticker := time.NewTicker(asyncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		job, err := h.store.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status == models.JobStatusSucceeded || job.Status == models.JobStatusFailed {
+			return job, nil
+		}
+	}
+}
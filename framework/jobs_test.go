@@ -0,0 +1,202 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+)
+
+// fakeJobStore hands out its queued jobs in order and records how each one
+// ended.
+type fakeJobStore struct {
+	mu       sync.Mutex
+	queue    []*models.Job
+	jobs     map[uuid.UUID]*models.Job
+	retryAt  map[uuid.UUID]*time.Time
+	extended int
+}
+
+func newFakeJobStore() *fakeJobStore {
+	return &fakeJobStore{jobs: make(map[uuid.UUID]*models.Job), retryAt: make(map[uuid.UUID]*time.Time)}
+}
+
+func (s *fakeJobStore) Enqueue(_ context.Context, job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	job.Status = models.JobStatusPending
+	s.queue = append(s.queue, job)
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *fakeJobStore) ClaimNext(_ context.Context, _ []string, _ time.Duration) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, nil
+	}
+	job := s.queue[0]
+	s.queue = s.queue[1:]
+	job.Status = models.JobStatusRunning
+	job.Attempts++
+	copied := *job
+	return &copied, nil
+}
+
+func (s *fakeJobStore) Extend(context.Context, uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extended++
+	return nil
+}
+
+func (s *fakeJobStore) Complete(_ context.Context, id uuid.UUID, result json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status = models.JobStatusSucceeded
+	s.jobs[id].Result = result
+	return nil
+}
+
+func (s *fakeJobStore) Fail(_ context.Context, id uuid.UUID, message string, retryAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	job.Error = message
+	s.retryAt[id] = retryAt
+	if retryAt != nil {
+		job.Status = models.JobStatusPending
+		s.queue = append(s.queue, job)
+	} else {
+		job.Status = models.JobStatusFailed
+	}
+	return nil
+}
+
+func (s *fakeJobStore) FindByID(_ context.Context, id uuid.UUID) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, errors.New("not found")
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func newTestPool(store JobStore) *WorkerPool {
+	return &WorkerPool{store: store, workers: 1, pollInterval: time.Millisecond, processors: make(map[string]JobProcessor)}
+}
+
+func TestWorkerPoolCompletesJobs(t *testing.T) {
+	store := newFakeJobStore()
+	pool := newTestPool(store)
+	pool.Register("Echo", JobProcessorFunc(func(_ context.Context, job *models.Job) (any, error) {
+		return map[string]string{"echo": string(job.Input)}, nil
+	}))
+	job := &models.Job{Kind: "echo", Input: json.RawMessage(`"hi"`), MaxAttempts: 3}
+	store.Enqueue(context.Background(), job)
+
+	if !pool.runNext(context.Background()) {
+		t.Fatal("runNext found no job")
+	}
+	if job.Status != models.JobStatusSucceeded {
+		t.Fatalf("status = %s, want succeeded", job.Status)
+	}
+	if string(job.Result) != `{"echo":"\"hi\""}` {
+		t.Errorf("result = %s", job.Result)
+	}
+	if pool.runNext(context.Background()) {
+		t.Error("runNext ran a job from an empty queue")
+	}
+}
+
+func TestWorkerPoolRetriesFailures(t *testing.T) {
+	store := newFakeJobStore()
+	pool := newTestPool(store)
+	pool.Register("flaky", JobProcessorFunc(func(context.Context, *models.Job) (any, error) {
+		return nil, errors.New("temporarily unavailable")
+	}))
+	pool.Register("invalid", JobProcessorFunc(func(context.Context, *models.Job) (any, error) {
+		return nil, Permanent(errors.New("bad input"))
+	}))
+	flaky := &models.Job{Kind: "flaky", MaxAttempts: 2}
+	invalid := &models.Job{Kind: "invalid", MaxAttempts: 2}
+	store.Enqueue(context.Background(), flaky)
+	store.Enqueue(context.Background(), invalid)
+
+	pool.runNext(context.Background())
+	if store.retryAt[flaky.ID] == nil || flaky.Status != models.JobStatusPending {
+		t.Errorf("a failure with attempts left was not retried: %s", flaky.Status)
+	}
+	pool.runNext(context.Background())
+	if invalid.Status != models.JobStatusFailed {
+		t.Errorf("a permanent failure left the job %s, want failed", invalid.Status)
+	}
+
+	// The last attempt fails for good.
+	pool.runNext(context.Background())
+	if flaky.Status != models.JobStatusFailed {
+		t.Errorf("the last failed attempt left the job %s, want failed", flaky.Status)
+	}
+}
+
+func TestWorkerPoolRecoversPanics(t *testing.T) {
+	store := newFakeJobStore()
+	pool := newTestPool(store)
+	pool.Register("broken", JobProcessorFunc(func(context.Context, *models.Job) (any, error) {
+		panic("nil map")
+	}))
+	job := &models.Job{Kind: "broken", MaxAttempts: 3}
+	store.Enqueue(context.Background(), job)
+
+	if !pool.runNext(context.Background()) {
+		t.Fatal("runNext found no job")
+	}
+	if job.Status != models.JobStatusFailed {
+		t.Errorf("a panicking job is %s, want failed", job.Status)
+	}
+	if job.Error != "job panicked: nil map" {
+		t.Errorf("error = %q", job.Error)
+	}
+}
+
+func TestAsyncActionHandlerWaitsForResult(t *testing.T) {
+	store := newFakeJobStore()
+	handler := NewAsyncActionHandler(store, "export")
+	go func() {
+		for {
+			store.mu.Lock()
+			queued := len(store.queue)
+			store.mu.Unlock()
+			if queued > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		job, _ := store.ClaimNext(context.Background(), nil, jobLease)
+		store.Complete(context.Background(), job.ID, json.RawMessage(`{"rows":3}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var action HasuraAction
+	json.Unmarshal([]byte(`{"action":{"name":"export"},"input":{},"session_variables":{"x-hasura-role":"user"}}`), &action)
+	recorder := httptest.NewRecorder()
+	handler.Handle(recorder, httptest.NewRequest(http.MethodPost, "/actions", nil).WithContext(ctx), action)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"rows":3}` {
+		t.Errorf("response = %d %s, want the job's result", recorder.Code, recorder.Body)
+	}
+}
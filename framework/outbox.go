@@ -0,0 +1,188 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+)
+
+type OutboxStore interface {
+	WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+	FindUnpublished(ctx context.Context, createdBefore time.Time, limit int) ([]models.OutboxEvent, error)
+	Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error
+	Release(ctx context.Context, ids []uuid.UUID) error
+	MarkPublished(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, message string, retryAt time.Time) error
+}
+
+// OutboxSink publishes outbox events outside the process.
+type OutboxSink interface {
+	Name() string
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+type OutboxHandlerFunc func(ctx context.Context, event *models.OutboxEvent) error
+
+const (
+	outboxBatchSize    = 100
+	outboxRetryBase    = time.Second
+	outboxRetryCeiling = 5 * time.Minute
+	// outboxClaimLease is how long a claimed batch is left to its relay. The
+	// relay stops publishing at half of it, so a batch taken over after a
+	// crash is never being published twice at once.
+	outboxClaimLease = 5 * time.Minute
+)
+
+// OutboxRelay publishes outbox rows to in-process subscribers and sinks.
+// Delivery is at-least-once: an event is only marked published once every
+// subscriber and sink accepted it, and is retried as a whole otherwise.
+type OutboxRelay struct {
+	store           OutboxStore
+	pollInterval    time.Duration
+	visibilityDelay time.Duration
+	mu              sync.RWMutex
+	subscribers     map[string][]OutboxHandlerFunc
+	sinks           []OutboxSink
+}
+
+var (
+	outboxRelaySingleton *OutboxRelay
+	outboxRelayOnce      sync.Once
+)
+
+// GetOutboxRelay returns the relay. Events are relayed once they are older
+// than visibilityDelay: sequences are taken before commit, so a transaction
+// still committing may yet add an event ahead of newer ones.
+func GetOutboxRelay(store OutboxStore, pollInterval, visibilityDelay time.Duration) *OutboxRelay {
+	outboxRelayOnce.Do(func() {
+		outboxRelaySingleton = &OutboxRelay{
+			store:           store,
+			pollInterval:    pollInterval,
+			visibilityDelay: visibilityDelay,
+			subscribers:     make(map[string][]OutboxHandlerFunc),
+		}
+	})
+
+	return outboxRelaySingleton
+}
+
+// Subscribe registers an in-process handler for one event type.
+func (o *OutboxRelay) Subscribe(eventType string, handler OutboxHandlerFunc) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	eventType = strings.ToLower(eventType)
+	o.subscribers[eventType] = append(o.subscribers[eventType], handler)
+}
+
+// AddSink registers a sink that receives every event.
+func (o *OutboxRelay) AddSink(sink OutboxSink) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sinks = append(o.sinks, sink)
+}
+
+func (o *OutboxRelay) Start(ctx context.Context) {
+	RunPeriodically(ctx, "relay outbox events", o.pollInterval, o.relay)
+}
+
+// relay claims a batch, then publishes it outside the transaction that
+// claimed it. An event that fails blocks the later events of its aggregate
+// until it goes through, which keeps each aggregate's events in order.
+func (o *OutboxRelay) relay(ctx context.Context) error {
+	events, err := o.claim(ctx)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	deadline := time.Now().Add(outboxClaimLease / 2)
+	blocked := make(map[uuid.UUID]bool)
+	var unpublished []uuid.UUID
+	for i := range events {
+		event := &events[i]
+		if blocked[event.AggregateID] || time.Now().After(deadline) {
+			unpublished = append(unpublished, event.ID)
+			continue
+		}
+
+		if err := o.publish(ctx, event); err != nil {
+			blocked[event.AggregateID] = true
+			log.Printf("Outbox event %s (%s) failed on attempt %d: %v", event.ID, event.EventType, event.Attempts+1, err)
+			retryAt := time.Now().Add(outboxRetryDelay(event.Attempts + 1))
+			if err := o.store.MarkFailed(ctx, event.ID, err.Error(), retryAt); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := o.store.MarkPublished(ctx, event.ID); err != nil {
+			return err
+		}
+	}
+	return o.store.Release(ctx, unpublished)
+}
+
+// claim takes the events ready to be published, in order, while holding the
+// relay lock. An aggregate whose next event is waiting for a retry or claimed
+// by another relay has none of its events taken.
+func (o *OutboxRelay) claim(ctx context.Context) ([]models.OutboxEvent, error) {
+	var claimed []models.OutboxEvent
+	_, err := o.store.WithRelayLock(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		events, err := o.store.FindUnpublished(ctx, now.Add(-o.visibilityDelay), outboxBatchSize)
+		if err != nil {
+			return err
+		}
+
+		blocked := make(map[uuid.UUID]bool)
+		ids := make([]uuid.UUID, 0, len(events))
+		for _, event := range events {
+			if blocked[event.AggregateID] {
+				continue
+			}
+			if event.NextAttemptAt.After(now) || (event.ClaimedUntil != nil && event.ClaimedUntil.After(now)) {
+				blocked[event.AggregateID] = true
+				continue
+			}
+			claimed = append(claimed, event)
+			ids = append(ids, event.ID)
+		}
+		return o.store.Claim(ctx, ids, now.Add(outboxClaimLease))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (o *OutboxRelay) publish(ctx context.Context, event *models.OutboxEvent) error {
+	o.mu.RLock()
+	subscribers := o.subscribers[strings.ToLower(event.EventType)]
+	sinks := o.sinks
+	o.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		if err := subscriber(ctx, event); err != nil {
+			return fmt.Errorf("subscriber failed: %w", err)
+		}
+	}
+	for _, sink := range sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s sink failed: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+func outboxRetryDelay(attempt int) time.Duration {
+	delay := outboxRetryBase << (attempt - 1)
+	if delay <= 0 || delay > outboxRetryCeiling {
+		return outboxRetryCeiling
+	}
+	return delay
+}
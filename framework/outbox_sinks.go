@@ -0,0 +1,143 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"app/models"
+	"app/utils"
+)
+
+// OutboxMessage is how outbox events are encoded for sinks.
+type OutboxMessage struct {
+	ID            string          `json:"id"`
+	Sequence      int64           `json:"sequence"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func encodeOutboxEvent(event *models.OutboxEvent) ([]byte, error) {
+	return json.Marshal(OutboxMessage{
+		ID:            event.ID.String(),
+		Sequence:      event.Sequence,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID.String(),
+		Type:          event.EventType,
+		Payload:       event.Payload,
+		CreatedAt:     event.CreatedAt,
+	})
+}
+
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	log.Printf("Outbox event %s: %s on %s %s: %s", event.ID, event.EventType, event.AggregateType, event.AggregateID, event.Payload)
+	return nil
+}
+
+// WebhookSink posts every event to one URL, signed like partner webhooks.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookSink(url, secret string) *WebhookSink {
+	return &WebhookSink{url: url, secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	body, err := encodeOutboxEvent(event)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Webhook-Id", event.ID.String())
+	request.Header.Set("X-Webhook-Event", event.EventType)
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	if s.secret != "" {
+		request.Header.Set("X-Webhook-Signature", "v1="+utils.SignPayload(s.secret, timestamp, body))
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("endpoint responded %d: %s", response.StatusCode, detail)
+	}
+	return nil
+}
+
+// NATSPublisher is satisfied by *nats.Conn, so a NATS connection, or anything
+// speaking the same interface, can be plugged in without this package
+// depending on a client library.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// natsFlushTimeout bounds how long a sink waits for the server to have
+// everything published so far.
+const natsFlushTimeout = 5 * time.Second
+
+// NATSSink publishes events on "<prefix>.<event type>".
+type NATSSink struct {
+	conn   NATSPublisher
+	prefix string
+}
+
+func NewNATSSink(conn NATSPublisher, prefix string) *NATSSink {
+	return &NATSSink{conn: conn, prefix: prefix}
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+func (s *NATSSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	body, err := encodeOutboxEvent(event)
+	if err != nil {
+		return err
+	}
+	if err := s.conn.Publish(s.prefix+"."+event.EventType, body); err != nil {
+		return err
+	}
+
+	// Publish only buffers the message. Connections that can flush are
+	// flushed, so an event is not marked published before the server has it.
+	flusher, ok := s.conn.(interface {
+		FlushWithContext(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, natsFlushTimeout)
+	defer cancel()
+	return flusher.FlushWithContext(ctx)
+}
@@ -0,0 +1,164 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+)
+
+// fakeOutboxStore keeps events in sequence order, as FindUnpublished returns
+// them.
+type fakeOutboxStore struct {
+	events []*models.OutboxEvent
+}
+
+func (s *fakeOutboxStore) add(aggregateID uuid.UUID, eventType string) *models.OutboxEvent {
+	event := &models.OutboxEvent{
+		ID:          uuid.New(),
+		Sequence:    int64(len(s.events) + 1),
+		AggregateID: aggregateID,
+		EventType:   eventType,
+		CreatedAt:   time.Now().Add(-time.Minute),
+	}
+	s.events = append(s.events, event)
+	return event
+}
+
+func (s *fakeOutboxStore) find(id uuid.UUID) *models.OutboxEvent {
+	for _, event := range s.events {
+		if event.ID == id {
+			return event
+		}
+	}
+	return nil
+}
+
+func (s *fakeOutboxStore) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	return true, fn(ctx)
+}
+
+func (s *fakeOutboxStore) FindUnpublished(_ context.Context, createdBefore time.Time, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	for _, event := range s.events {
+		if event.PublishedAt == nil && event.CreatedAt.Before(createdBefore) && len(events) < limit {
+			events = append(events, *event)
+		}
+	}
+	return events, nil
+}
+
+func (s *fakeOutboxStore) Claim(_ context.Context, ids []uuid.UUID, until time.Time) error {
+	for _, id := range ids {
+		s.find(id).ClaimedUntil = &until
+	}
+	return nil
+}
+
+func (s *fakeOutboxStore) Release(_ context.Context, ids []uuid.UUID) error {
+	for _, id := range ids {
+		s.find(id).ClaimedUntil = nil
+	}
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkPublished(_ context.Context, id uuid.UUID) error {
+	now := time.Now()
+	event := s.find(id)
+	event.PublishedAt = &now
+	event.ClaimedUntil = nil
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkFailed(_ context.Context, id uuid.UUID, message string, retryAt time.Time) error {
+	event := s.find(id)
+	event.Attempts++
+	event.LastError = message
+	event.NextAttemptAt = retryAt
+	event.ClaimedUntil = nil
+	return nil
+}
+
+func newTestRelay(store OutboxStore) *OutboxRelay {
+	return &OutboxRelay{store: store, subscribers: make(map[string][]OutboxHandlerFunc)}
+}
+
+func TestOutboxRelayKeepsAggregatesInOrder(t *testing.T) {
+	store := &fakeOutboxStore{}
+	failing, healthy := uuid.New(), uuid.New()
+	store.add(failing, "created")
+	store.add(healthy, "created")
+	store.add(failing, "updated")
+	store.add(healthy, "updated")
+
+	relay := newTestRelay(store)
+	var published []int64
+	fail := true
+	handler := func(_ context.Context, event *models.OutboxEvent) error {
+		if fail && event.AggregateID == failing {
+			return errors.New("subscriber unavailable")
+		}
+		published = append(published, event.Sequence)
+		return nil
+	}
+	relay.Subscribe("Created", handler)
+	relay.Subscribe("updated", handler)
+
+	if err := relay.relay(context.Background()); err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	if !slices.Equal(published, []int64{2, 4}) {
+		t.Errorf("published %v, want only the healthy aggregate's events", published)
+	}
+	if store.events[0].Attempts != 1 || store.events[2].Attempts != 0 || store.events[2].ClaimedUntil != nil {
+		t.Errorf("the failed aggregate's events were not left for a retry: %+v %+v", store.events[0], store.events[2])
+	}
+
+	// Until its retry is due, the failed event holds back its aggregate.
+	fail = false
+	relay.relay(context.Background())
+	if len(published) != 2 {
+		t.Errorf("published %v before the retry was due", published)
+	}
+
+	store.events[0].NextAttemptAt = time.Now().Add(-time.Second)
+	relay.relay(context.Background())
+	if !slices.Equal(published, []int64{2, 4, 1, 3}) {
+		t.Errorf("published %v, want the failed aggregate's events in order after the retry", published)
+	}
+}
+
+func TestOutboxRelaySkipsAggregatesClaimedElsewhere(t *testing.T) {
+	store := &fakeOutboxStore{}
+	aggregate := uuid.New()
+	store.add(aggregate, "created")
+	store.add(aggregate, "updated")
+	claimedUntil := time.Now().Add(time.Minute)
+	store.events[0].ClaimedUntil = &claimedUntil
+
+	relay := newTestRelay(store)
+	claimed, err := relay.claim(context.Background())
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("claimed %d events of an aggregate another relay is publishing", len(claimed))
+	}
+}
+
+func TestOutboxRelayWaitsForVisibilityDelay(t *testing.T) {
+	store := &fakeOutboxStore{}
+	store.add(uuid.New(), "created").CreatedAt = time.Now()
+
+	relay := newTestRelay(store)
+	relay.visibilityDelay = time.Minute
+	claimed, _ := relay.claim(context.Background())
+	if len(claimed) != 0 {
+		t.Error("an event younger than the visibility delay was claimed")
+	}
+}
@@ -0,0 +1,26 @@
+package framework
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPeriodically calls fn every interval until ctx is cancelled.
+func RunPeriodically(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					log.Printf("Periodic task %s failed: %v", name, err)
+				}
+			}
+		}
+	}()
+}
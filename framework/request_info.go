@@ -0,0 +1,27 @@
+package framework
+
+import (
+	"net/http"
+	"net/netip"
+
+	"app/utils"
+)
+
+// SetTrustedProxies lists the proxies, Hasura among them, whose forwarding
+// headers are believed when capturing the client IP. Without any, the peer
+// address is recorded.
+func (r *Router) SetTrustedProxies(trusted []netip.Prefix) {
+	r.trustedProxies = trusted
+}
+
+// captureRequestInfo stores the client IP and user agent in the request
+// context, where the audit log picks them up.
+func (r *Router) captureRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := utils.WithRequestInfo(req.Context(), utils.RequestInfo{
+			IP:        utils.ClientIP(req, r.trustedProxies),
+			UserAgent: req.UserAgent(),
+		})
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
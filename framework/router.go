@@ -2,14 +2,19 @@ package framework
 
 import (
 	"net/http"
+	"net/netip"
 	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 )
 
 type Router struct {
-	Instance chi.Router
+	Instance       chi.Router
+	defaultTimeout time.Duration
+	routeTimeouts  map[string]time.Duration
+	trustedProxies []netip.Prefix
 }
 
 var singleton *Router
@@ -21,20 +26,59 @@ func GetRouter() *Router {
 			singleton = &Router{Instance: chi.NewRouter()}
 			singleton.Instance.Use(cors.Handler(cors.Options{
 				AllowedOrigins:   []string{"*"},
-				AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+				AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
 				AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 				AllowCredentials: true,
 				MaxAge:           300,
 			}))
+			singleton.Instance.Use(singleton.captureRequestInfo)
 		})
 	}
 	return singleton
 }
 
+// SetTimeouts configures the deadline applied to handlers registered
+// afterwards. A zero duration, by default or for a path, disables it.
+func (r *Router) SetTimeouts(defaultTimeout time.Duration, routeTimeouts map[string]time.Duration) {
+	r.defaultTimeout = defaultTimeout
+	r.routeTimeouts = routeTimeouts
+}
+
+func (r *Router) withTimeout(path string, handlerFunc http.HandlerFunc) http.Handler {
+	timeout, ok := r.routeTimeouts[path]
+	if !ok {
+		timeout = r.defaultTimeout
+	}
+	if timeout <= 0 {
+		return handlerFunc
+	}
+	return Timeout(timeout)(handlerFunc)
+}
+
 func (r *Router) AddPostHandler(path string, handlerFunc http.HandlerFunc) {
-	r.Instance.Post(path, handlerFunc)
+	r.Instance.Post(path, r.withTimeout(path, handlerFunc).ServeHTTP)
 }
 
 func (r *Router) AddGetHandler(path string, handlerFunc http.HandlerFunc) {
+	r.Instance.Get(path, r.withTimeout(path, handlerFunc).ServeHTTP)
+}
+
+func (r *Router) AddHeadHandler(path string, handlerFunc http.HandlerFunc) {
+	r.Instance.Head(path, r.withTimeout(path, handlerFunc).ServeHTTP)
+}
+
+func (r *Router) AddDeleteHandler(path string, handlerFunc http.HandlerFunc) {
+	r.Instance.Delete(path, r.withTimeout(path, handlerFunc).ServeHTTP)
+}
+
+// AddActionHandler registers a Hasura action endpoint. The dispatcher enforces
+// its own per-action deadlines, so no route timeout is applied here.
+func (r *Router) AddActionHandler(path string, dispatcher *ActionDispatcher) {
+	r.Instance.Post(path, dispatcher.Handle)
+}
+
+// AddStreamHandler registers a long-lived GET endpoint such as a WebSocket or
+// server-sent events stream, which must not be cut off by a route timeout.
+func (r *Router) AddStreamHandler(path string, handlerFunc http.HandlerFunc) {
 	r.Instance.Get(path, handlerFunc)
 }
@@ -0,0 +1,59 @@
+package framework
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type sessionKey struct{}
+
+func WithSession(ctx context.Context, session map[string]string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func SessionFromContext(ctx context.Context) map[string]string {
+	session, _ := ctx.Value(sessionKey{}).(map[string]string)
+	return session
+}
+
+func SessionUserID(ctx context.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(SessionFromContext(ctx)["x-hasura-user-id"])
+	return userID, err == nil
+}
+
+// SessionHeaders collects the x-hasura-* headers Hasura forwards to remote
+// schemas into the request context, keyed like action session variables.
+// Anyone can send such headers, so it belongs behind RequireSecret.
+func SessionHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := make(map[string]string)
+		for name, values := range r.Header {
+			name = strings.ToLower(name)
+			if strings.HasPrefix(name, "x-hasura-") && len(values) > 0 {
+				session[name] = values[0]
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithSession(r.Context(), session)))
+	})
+}
+
+// RequireSecret rejects requests whose header does not carry secret. An empty
+// secret rejects everything, so a missing configuration fails closed.
+func RequireSecret(header, secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get(header)
+			if secret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+				utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid or missing "+header+" header")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
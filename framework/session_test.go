@@ -0,0 +1,31 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireSecret(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		secret, provided string
+		want             int
+	}{
+		{"s3cret", "s3cret", http.StatusOK},
+		{"s3cret", "wrong", http.StatusUnauthorized},
+		{"s3cret", "", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		if test.provided != "" {
+			r.Header.Set("X-Webhook-Secret", test.provided)
+		}
+		recorder := httptest.NewRecorder()
+		RequireSecret("X-Webhook-Secret", test.secret)(ok).ServeHTTP(recorder, r)
+		if recorder.Code != test.want {
+			t.Errorf("secret %q, provided %q: %d, want %d", test.secret, test.provided, recorder.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"app/utils"
+)
+
+// timeoutWriter swaps the first error response written after the request
+// deadline has passed for onTimeout, so that a handler reporting e.g. a
+// DB_ERROR caused by the cancelled context surfaces as a TIMEOUT instead.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	onTimeout   func(w http.ResponseWriter)
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if status >= http.StatusBadRequest && errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timedOut = true
+		tw.onTimeout(tw.ResponseWriter)
+		return
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.timedOut {
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// writeDeadlineSlack is how long a handler has to write its response once its
+// deadline has passed, such as the timeout error itself.
+const writeDeadlineSlack = 30 * time.Second
+
+func serveWithTimeout(w http.ResponseWriter, r *http.Request, timeout time.Duration, onTimeout func(w http.ResponseWriter), next func(w http.ResponseWriter, r *http.Request)) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	// The server's WriteTimeout would otherwise cut off handlers allowed to
+	// run longer, such as cron jobs, and the caller would retry them.
+	// Writers that cannot change their deadline keep the server's.
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + writeDeadlineSlack))
+
+	tw := &timeoutWriter{ResponseWriter: w, ctx: ctx, onTimeout: onTimeout}
+	next(tw, r.WithContext(ctx))
+
+	if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		tw.WriteHeader(http.StatusGatewayTimeout)
+	}
+}
+
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serveWithTimeout(w, r, timeout, func(w http.ResponseWriter) {
+				utils.WriteProblem(w, r, http.StatusGatewayTimeout, "TIMEOUT", "Request timed out")
+			}, next.ServeHTTP)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"app/utils"
+)
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, action HasuraAction)
+
+func (f handlerFunc) Handle(w http.ResponseWriter, r *http.Request, action HasuraAction) {
+	f(w, r, action)
+}
+
+// slowHandler fails the way a database call does once its context expires.
+var slowHandler = handlerFunc(func(w http.ResponseWriter, r *http.Request, _ HasuraAction) {
+	<-r.Context().Done()
+	utils.WriteError(w, http.StatusInternalServerError, "DB_ERROR", r.Context().Err().Error())
+})
+
+func TestActionTimeouts(t *testing.T) {
+	fast := handlerFunc(func(w http.ResponseWriter, _ *http.Request, _ HasuraAction) {
+		w.Write([]byte(`{"ok":true}`))
+	})
+	dispatcher := &ActionDispatcher{handlers: map[string]Handler{"slow": slowHandler, "fast": fast}, defaultHandler: fast}
+	dispatcher.SetTimeouts(time.Hour, map[string]time.Duration{"Slow": 10 * time.Millisecond})
+
+	recorder := httptest.NewRecorder()
+	dispatcher.Handle(recorder, httptest.NewRequest(http.MethodPost, "/actions", strings.NewReader(`{"action":{"name":"slow"}}`)))
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Errorf("slow action = %d, want 504", recorder.Code)
+	}
+	if want := `{"message":"Action slow timed out","extensions":{"code":"TIMEOUT"}}` + "\n"; recorder.Body.String() != want {
+		t.Errorf("slow action body = %s, want %s", recorder.Body, want)
+	}
+
+	recorder = httptest.NewRecorder()
+	dispatcher.Handle(recorder, httptest.NewRequest(http.MethodPost, "/actions", strings.NewReader(`{"action":{"name":"fast"}}`)))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"ok":true}` {
+		t.Errorf("fast action = %d %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recipes", nil))
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want a problem", got)
+	}
+}
@@ -0,0 +1,24 @@
+package graph
+
+import (
+	"errors"
+
+	"app/services"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func toGraphQLError(err error, message string) error {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return &gqlerror.Error{Message: message, Extensions: map[string]any{"code": "NOT_FOUND"}}
+	case errors.As(err, &validationErr):
+		return &gqlerror.Error{Message: validationErr.Error(), Extensions: map[string]any{
+			"code":    "INVALID_INPUT",
+			"details": []map[string]string{{"field": validationErr.Field, "message": validationErr.Message}},
+		}}
+	default:
+		return &gqlerror.Error{Message: message, Extensions: map[string]any{"code": "INTERNAL_SERVER_ERROR"}}
+	}
+}
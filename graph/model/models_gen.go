@@ -0,0 +1,25 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package model
+
+type Query struct {
+}
+
+type RecipeRecommendation struct {
+	RecipeID string  `json:"recipe_id"`
+	Score    float64 `json:"score"`
+	Reason   string  `json:"reason"`
+}
+
+type ScaledIngredient struct {
+	IngredientID string  `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+}
+
+type ScaledRecipe struct {
+	RecipeID    string              `json:"recipe_id"`
+	Factor      float64             `json:"factor"`
+	Ingredients []*ScaledIngredient `json:"ingredients"`
+}
@@ -0,0 +1,15 @@
+package graph
+
+import "app/services"
+
+// This file will not be regenerated automatically.
+//
+// It serves as dependency injection for your app, add any dependencies you require here.
+
+type Resolver struct {
+	recipeService services.RecipeService
+}
+
+func NewResolver(recipeService services.RecipeService) *Resolver {
+	return &Resolver{recipeService: recipeService}
+}
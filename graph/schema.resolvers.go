@@ -0,0 +1,73 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.73
+
+import (
+	"app/framework"
+	"app/graph/model"
+	"app/services"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ScaledRecipe is the resolver for the scaledRecipe field.
+func (r *queryResolver) ScaledRecipe(ctx context.Context, recipeID string, factor float64) (*model.ScaledRecipe, error) {
+	id, err := uuid.Parse(recipeID)
+	if err != nil {
+		return nil, toGraphQLError(services.ErrNotFound, "Recipe not found")
+	}
+
+	ingredients, err := r.recipeService.ScaleRecipeIngredients(ctx, id, factor)
+	if err != nil {
+		return nil, toGraphQLError(err, "Failed to scale recipe")
+	}
+
+	scaled := &model.ScaledRecipe{
+		RecipeID:    recipeID,
+		Factor:      factor,
+		Ingredients: make([]*model.ScaledIngredient, 0, len(ingredients)),
+	}
+	for _, ingredient := range ingredients {
+		scaled.Ingredients = append(scaled.Ingredients, &model.ScaledIngredient{
+			IngredientID: ingredient.IngredientID.String(),
+			Name:         ingredient.Ingredient.Name,
+			Quantity:     ingredient.Quantity,
+			Unit:         ingredient.Unit,
+		})
+	}
+	return scaled, nil
+}
+
+// RecommendedRecipes is the resolver for the recommendedRecipes field.
+func (r *queryResolver) RecommendedRecipes(ctx context.Context, limit *int) ([]*model.RecipeRecommendation, error) {
+	// Anonymous callers get the plain popularity ranking.
+	userID, _ := framework.SessionUserID(ctx)
+
+	n := 10
+	if limit != nil {
+		n = *limit
+	}
+
+	recommendations, err := r.recipeService.RecommendRecipes(ctx, userID, n)
+	if err != nil {
+		return nil, toGraphQLError(err, "Failed to recommend recipes")
+	}
+
+	result := make([]*model.RecipeRecommendation, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		result = append(result, &model.RecipeRecommendation{
+			RecipeID: recommendation.RecipeID.String(),
+			Score:    recommendation.Score,
+			Reason:   recommendation.Reason,
+		})
+	}
+	return result, nil
+}
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type queryResolver struct{ *Resolver }
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"app/framework"
+	"app/models"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type AuditLogInput struct {
+	ActorID    string     `json:"actor_id"`
+	Action     string     `json:"action"`
+	EntityType string     `json:"entity_type"`
+	EntityID   string     `json:"entity_id"`
+	Since      *time.Time `json:"since"`
+	Until      *time.Time `json:"until"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+}
+
+type AuditLogInputWrapper struct {
+	Arg1 AuditLogInput `json:"arg1"`
+}
+
+type AuditLogEntryOutput struct {
+	ID         string          `json:"id"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	Changes    json.RawMessage `json:"changes"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditLogResponse struct {
+	Entries []AuditLogEntryOutput `json:"entries"`
+	Total   int64                 `json:"total"`
+}
+
+type AuditLogHandler struct {
+	auditor services.Auditor
+}
+
+func (h *AuditLogHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller := services.Caller{Admin: action.Role() == "admin"}
+
+	var wrapper AuditLogInputWrapper
+	if len(action.Input) > 0 {
+		if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+			return
+		}
+	}
+
+	input := wrapper.Arg1
+	filter := services.AuditLogFilter{
+		Action:     input.Action,
+		EntityType: input.EntityType,
+		EntityID:   input.EntityID,
+		Since:      input.Since,
+		Until:      input.Until,
+	}
+	if input.ActorID != "" {
+		actorID, err := uuid.Parse(input.ActorID)
+		if err != nil {
+			utils.WriteValidationError(w, "INVALID_ID", "Invalid actor id", []utils.FieldError{
+				{Field: "actor_id", Message: err.Error()},
+			})
+			return
+		}
+		filter.ActorID = &actorID
+	}
+
+	entries, total, err := h.auditor.Query(r.Context(), caller, filter, input.Limit, input.Offset)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Only admins can read the audit log")
+		} else {
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to query audit log: "+err.Error())
+		}
+		return
+	}
+
+	response := AuditLogResponse{Entries: make([]AuditLogEntryOutput, 0, len(entries)), Total: total}
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, AuditLogEntryOutput{
+			ID:         entry.ID.String(),
+			ActorID:    entry.ActorID,
+			ActorRole:  entry.ActorRole,
+			Action:     entry.Action,
+			EntityType: entry.EntityType,
+			EntityID:   entry.EntityID,
+			IP:         entry.IP,
+			UserAgent:  entry.UserAgent,
+			Before:     entry.Before,
+			After:      entry.After,
+			Changes:    entry.Changes,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+	utils.EncodeJSON(w, response)
+}
+
+type AuditEventHandler struct {
+	auditor services.Auditor
+}
+
+// HandleRecipeChanged audits recipe writes made through Hasura, using the
+// session of the request that made them. A soft delete is audited as a
+// deletion. Events are delivered by Hasura, not the client, so no IP or user
+// agent is recorded.
+func (h *AuditEventHandler) HandleRecipeChanged(ctx context.Context, change framework.Change[models.Recipe]) error {
+	var action string
+	switch change.Op {
+	case framework.OpInsert:
+		action = models.AuditRecipeCreated
+	case framework.OpUpdate:
+		action = models.AuditRecipeUpdated
+		if change.New != nil && change.New.DeletedAt.Valid && (change.Old == nil || !change.Old.DeletedAt.Valid) {
+			action = models.AuditRecipeDeleted
+		}
+	case framework.OpDelete:
+		action = models.AuditRecipeDeleted
+	default:
+		return nil
+	}
+
+	data := change.Event.Event.Data
+	entry := services.AuditEntry{
+		ActorRole:  change.Event.Role(),
+		Action:     action,
+		EntityType: models.Recipe{}.TableName(),
+	}
+	if len(data.Old) > 0 && string(data.Old) != "null" {
+		entry.Before = data.Old
+	}
+	if len(data.New) > 0 && string(data.New) != "null" {
+		entry.After = data.New
+	}
+	if actorID, err := change.Event.UserID(); err == nil {
+		entry.ActorID = &actorID
+	}
+	if change.New != nil {
+		entry.EntityID = change.New.ID.String()
+	} else if change.Old != nil {
+		entry.EntityID = change.Old.ID.String()
+	}
+	return h.auditor.Record(utils.WithRequestInfo(ctx, utils.RequestInfo{}), entry)
+}
+
+func RegisterAuditHandlers(auditor services.Auditor) {
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("auditLog", &AuditLogHandler{auditor: auditor})
+
+	events := &AuditEventHandler{auditor: auditor}
+	framework.RegisterEventHandler(framework.GetEventDispatcher(), "recipe_audit", models.Recipe{}.TableName(), events.HandleRecipeChanged)
+}
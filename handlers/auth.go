@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"app/framework"
+	"app/services"
+	"app/utils"
+)
+
+// authenticate parses the bearer token in authorization and checks that it
+// has not been revoked since it was issued.
+func authenticate(ctx context.Context, tokens services.TokenVerifier, authorization string) (*utils.Claims, error) {
+	claims, err := utils.ParseJWT(authorization)
+	if err != nil {
+		return nil, err
+	}
+	if err := tokens.VerifyToken(ctx, claims.UserID, claims.TokenVersion); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySession checks the token behind signed in action sessions. Sessions
+// without a user, such as admin secret calls, have no token to check.
+func verifySession(tokens services.TokenVerifier) framework.SessionVerifier {
+	return func(ctx context.Context, action framework.HasuraAction) error {
+		userID, err := action.UserID()
+		if err != nil {
+			return nil
+		}
+		err = tokens.VerifyToken(ctx, userID, action.TokenVersion())
+		if errors.Is(err, services.ErrTokenRevoked) {
+			return framework.ErrSessionRevoked
+		}
+		return err
+	}
+}
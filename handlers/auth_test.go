@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"app/framework"
+	"app/services"
+
+	"github.com/google/uuid"
+)
+
+type fakeTokenVerifier struct {
+	versions map[uuid.UUID]int
+}
+
+func (v fakeTokenVerifier) VerifyToken(_ context.Context, userID uuid.UUID, tokenVersion int) error {
+	if version, exists := v.versions[userID]; !exists || version != tokenVersion {
+		return services.ErrTokenRevoked
+	}
+	return nil
+}
+
+func TestVerifySession(t *testing.T) {
+	userID := uuid.New()
+	verify := verifySession(fakeTokenVerifier{versions: map[uuid.UUID]int{userID: 2}})
+	session := func(variables map[string]string) framework.HasuraAction {
+		return framework.HasuraAction{SessionVariables: variables}
+	}
+
+	current := session(map[string]string{"x-hasura-user-id": userID.String(), "x-hasura-token-version": "2"})
+	if err := verify(context.Background(), current); err != nil {
+		t.Errorf("current token: %v", err)
+	}
+	revoked := session(map[string]string{"x-hasura-user-id": userID.String(), "x-hasura-token-version": "1"})
+	if err := verify(context.Background(), revoked); !errors.Is(err, framework.ErrSessionRevoked) {
+		t.Errorf("revoked token: err = %v, want ErrSessionRevoked", err)
+	}
+	unversioned := session(map[string]string{"x-hasura-user-id": userID.String()})
+	if err := verify(context.Background(), unversioned); !errors.Is(err, framework.ErrSessionRevoked) {
+		t.Errorf("token without a version after a role change: err = %v, want ErrSessionRevoked", err)
+	}
+	if err := verify(context.Background(), session(map[string]string{"x-hasura-role": "admin"})); err != nil {
+		t.Errorf("admin secret session: %v", err)
+	}
+}
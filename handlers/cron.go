@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+
+	"app/framework"
+	"app/services"
+)
+
+// Cron trigger names, as declared in hasura/metadata/cron_triggers.yaml.
+const (
+	CronPurgeSoftDeleted        = "purge_soft_deleted"
+	CronReconcileCounts         = "reconcile_counts"
+	CronCollectOrphanedObjects  = "collect_orphaned_objects"
+	CronRecomputeTrendingScores = "recompute_trending_scores"
+	CronPruneProcessedEvents    = "prune_processed_events"
+	CronExpirePictureUploads    = "expire_picture_uploads"
+)
+
+type countResult struct {
+	Count int64 `json:"count"`
+}
+
+func countJob(job func(ctx context.Context) (int64, error)) framework.CronJobFunc {
+	return func(ctx context.Context) (any, error) {
+		count, err := job(ctx)
+		return countResult{Count: count}, err
+	}
+}
+
+func RegisterCronJobs(registry *framework.CronRegistry, maintenanceService services.MaintenanceService, uploadService services.PictureUploadService) {
+	registry.Register(CronPurgeSoftDeleted, func(ctx context.Context) (any, error) {
+		return maintenanceService.PurgeSoftDeleted(ctx)
+	})
+	registry.Register(CronReconcileCounts, countJob(maintenanceService.ReconcileCounts))
+	registry.Register(CronCollectOrphanedObjects, func(ctx context.Context) (any, error) {
+		return maintenanceService.CollectOrphanedObjects(ctx)
+	})
+	registry.Register(CronRecomputeTrendingScores, countJob(maintenanceService.RecomputeTrendingScores))
+	registry.Register(CronPruneProcessedEvents, countJob(maintenanceService.PruneProcessedEvents))
+	registry.Register(CronExpirePictureUploads, countJob(uploadService.ExpireUploads))
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"app/framework"
+	"app/models"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+func writeDeadLetterError(w http.ResponseWriter, err error, message string) {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.WriteValidationError(w, "INVALID_INPUT", message, []utils.FieldError{
+			{Field: validationErr.Field, Message: validationErr.Message},
+		})
+	case errors.Is(err, services.ErrForbidden):
+		utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Only admins can manage dead letters")
+	case errors.Is(err, services.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Dead letter not found")
+	case errors.Is(err, services.ErrConflict):
+		utils.WriteError(w, http.StatusConflict, "DEAD_LETTER_RESOLVED", err.Error())
+	case errors.Is(err, services.ErrReplayFailed):
+		utils.WriteError(w, http.StatusBadGateway, "REPLAY_FAILED", err.Error())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", message+": "+err.Error())
+	}
+}
+
+type DeadLetterOutput struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Name        string          `json:"name"`
+	Reference   string          `json:"reference"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Error       string          `json:"error"`
+	Attempts    int             `json:"attempts"`
+	Status      string          `json:"status"`
+	ReplayCount int             `json:"replay_count"`
+	ResolvedAt  *time.Time      `json:"resolved_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// newDeadLetterOutput leaves out the payload, which can be large, unless
+// withPayload is set.
+func newDeadLetterOutput(letter models.DeadLetter, withPayload bool) DeadLetterOutput {
+	output := DeadLetterOutput{
+		ID:          letter.ID.String(),
+		Source:      letter.Source,
+		Name:        letter.Name,
+		Reference:   letter.Reference,
+		Error:       letter.Error,
+		Attempts:    letter.Attempts,
+		Status:      letter.Status,
+		ReplayCount: letter.ReplayCount,
+		ResolvedAt:  letter.ResolvedAt,
+		CreatedAt:   letter.CreatedAt,
+		UpdatedAt:   letter.UpdatedAt,
+	}
+	if withPayload {
+		output.Payload = letter.Payload
+	}
+	return output
+}
+
+type DeadLettersInput struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type DeadLettersInputWrapper struct {
+	Arg1 DeadLettersInput `json:"arg1"`
+}
+
+type DeadLettersResponse struct {
+	DeadLetters []DeadLetterOutput `json:"dead_letters"`
+	Total       int64              `json:"total"`
+}
+
+type DeadLettersHandler struct {
+	deadLetterService services.DeadLetterService
+}
+
+func (h *DeadLettersHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller := services.Caller{Admin: action.Role() == "admin"}
+
+	var wrapper DeadLettersInputWrapper
+	if len(action.Input) > 0 {
+		if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+			return
+		}
+	}
+
+	input := wrapper.Arg1
+	filter := services.DeadLetterFilter{Source: input.Source, Name: input.Name, Status: input.Status}
+	letters, total, err := h.deadLetterService.List(r.Context(), caller, filter, input.Limit, input.Offset)
+	if err != nil {
+		writeDeadLetterError(w, err, "Failed to list dead letters")
+		return
+	}
+
+	response := DeadLettersResponse{DeadLetters: make([]DeadLetterOutput, 0, len(letters)), Total: total}
+	for _, letter := range letters {
+		response.DeadLetters = append(response.DeadLetters, newDeadLetterOutput(letter, false))
+	}
+	utils.EncodeJSON(w, response)
+}
+
+type DeadLetterInput struct {
+	ID string `json:"id"`
+}
+
+type DeadLetterInputWrapper struct {
+	Arg1 DeadLetterInput `json:"arg1"`
+}
+
+func parseDeadLetterID(w http.ResponseWriter, action framework.HasuraAction) (uuid.UUID, bool) {
+	var wrapper DeadLetterInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(wrapper.Arg1.ID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid dead letter id", []utils.FieldError{
+			{Field: "id", Message: err.Error()},
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+type DeadLetterHandler struct {
+	deadLetterService services.DeadLetterService
+}
+
+func (h *DeadLetterHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	id, ok := parseDeadLetterID(w, action)
+	if !ok {
+		return
+	}
+
+	letter, err := h.deadLetterService.Get(r.Context(), services.Caller{Admin: action.Role() == "admin"}, id)
+	if err != nil {
+		writeDeadLetterError(w, err, "Failed to find dead letter")
+		return
+	}
+	utils.EncodeJSON(w, newDeadLetterOutput(*letter, true))
+}
+
+type ReplayDeadLetterHandler struct {
+	deadLetterService services.DeadLetterService
+}
+
+func (h *ReplayDeadLetterHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	id, ok := parseDeadLetterID(w, action)
+	if !ok {
+		return
+	}
+
+	letter, err := h.deadLetterService.Replay(r.Context(), services.Caller{Admin: action.Role() == "admin"}, id)
+	if err != nil {
+		writeDeadLetterError(w, err, "Failed to replay dead letter")
+		return
+	}
+	utils.EncodeJSON(w, newDeadLetterOutput(*letter, false))
+}
+
+type DiscardDeadLetterHandler struct {
+	deadLetterService services.DeadLetterService
+}
+
+func (h *DiscardDeadLetterHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	id, ok := parseDeadLetterID(w, action)
+	if !ok {
+		return
+	}
+
+	letter, err := h.deadLetterService.Discard(r.Context(), services.Caller{Admin: action.Role() == "admin"}, id)
+	if err != nil {
+		writeDeadLetterError(w, err, "Failed to discard dead letter")
+		return
+	}
+	utils.EncodeJSON(w, newDeadLetterOutput(*letter, false))
+}
+
+// replayEvent dispatches a dead Hasura event again, in-process. The event
+// ledger still applies, so an event that did get through is not run twice.
+func replayEvent(ctx context.Context, letter *models.DeadLetter) error {
+	var event framework.HasuraEvent
+	if err := json.Unmarshal(letter.Payload, &event); err != nil {
+		return fmt.Errorf("invalid event payload: %w", err)
+	}
+	return framework.GetEventDispatcher().Dispatch(ctx, &event)
+}
+
+func RegisterDeadLetterHandlers(deadLetterService services.DeadLetterService, webhookService services.WebhookService) {
+	deadLetterService.RegisterReplayer(models.DeadLetterSourceEvent, replayEvent)
+	deadLetterService.RegisterReplayer(models.DeadLetterSourceWebhook, func(ctx context.Context, letter *models.DeadLetter) error {
+		deliveryID, err := uuid.Parse(letter.Reference)
+		if err != nil {
+			return fmt.Errorf("invalid webhook delivery id: %w", err)
+		}
+		_, err = webhookService.Replay(ctx, services.Caller{Admin: true}, deliveryID)
+		return err
+	})
+
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("deadLetters", &DeadLettersHandler{deadLetterService: deadLetterService})
+	dispatcher.RegisterHandler("deadLetter", &DeadLetterHandler{deadLetterService: deadLetterService})
+	dispatcher.RegisterHandler("replayDeadLetter", &ReplayDeadLetterHandler{deadLetterService: deadLetterService})
+	dispatcher.RegisterHandler("discardDeadLetter", &DiscardDeadLetterHandler{deadLetterService: deadLetterService})
+}
@@ -1,32 +1,26 @@
 package handlers
 
 import (
-	"log"
-	"net/http"
+	"context"
 
+	"app/framework"
 	"app/models"
-	"app/utils"
+	"app/services"
 )
 
-type UserEventPayload struct {
-	Event struct {
-		Op   string `json:"op"`
-		Data struct {
-			New models.User `json:"new"`
-		} `json:"data"`
-	} `json:"event"`
+type UserEventHandler struct {
+	onboardingService services.OnboardingService
 }
 
-func HandleEvents(w http.ResponseWriter, r *http.Request) {
-	var payload UserEventPayload
-	if err := utils.DecodeJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_EVENT_PAYLOAD", "Invalid event payload")
-		return
-	}
-
-	if payload.Event.Op == "INSERT" {
-		log.Printf("User created: ID=%s, Username=%s", payload.Event.Data.New.ID.String(), payload.Event.Data.New.Username)
+func (h *UserEventHandler) HandleUserCreated(ctx context.Context, change framework.Change[models.User]) error {
+	if change.Op != framework.OpInsert && change.Op != framework.OpManual {
+		return nil
 	}
+	return h.onboardingService.QueueOnboarding(ctx, change.New.ID)
+}
 
-	w.WriteHeader(http.StatusOK)
+func RegisterUserEventHandlers(onboardingService services.OnboardingService) {
+	handler := &UserEventHandler{onboardingService: onboardingService}
+	dispatcher := framework.GetEventDispatcher()
+	framework.RegisterEventHandler(dispatcher, "user_created", models.User{}.TableName(), handler.HandleUserCreated)
 }
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"app/framework"
+	"app/graph"
+	"app/services"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// NewGraphQLHandler serves the remote schema Hasura stitches in from
+// remote_schemas.yaml. Hasura forwards the caller's session as x-hasura-*
+// headers, which resolvers read back through framework.SessionFromContext,
+// so the handler must only be reachable with the secret Hasura sends.
+func NewGraphQLHandler(recipeService services.RecipeService) http.Handler {
+	server := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: graph.NewResolver(recipeService),
+	}))
+	server.AddTransport(transport.GET{})
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+
+	return framework.SessionHeaders(server)
+}
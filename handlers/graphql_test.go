@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"app/models"
+	"app/services"
+
+	"github.com/google/uuid"
+)
+
+type fakeGraphQLRecipeService struct {
+	services.RecipeService
+	recommendedFor uuid.UUID
+}
+
+func (s *fakeGraphQLRecipeService) ScaleRecipeIngredients(_ context.Context, _ uuid.UUID, factor float64) ([]models.RecipeIngredient, error) {
+	if factor <= 0 {
+		return nil, &services.ValidationError{Field: "factor", Message: "must be greater than zero"}
+	}
+	ingredient := models.RecipeIngredient{IngredientID: uuid.New(), Quantity: 1.5 * factor, Unit: "cup"}
+	ingredient.Ingredient.Name = "flour"
+	return []models.RecipeIngredient{ingredient}, nil
+}
+
+func (s *fakeGraphQLRecipeService) RecommendRecipes(_ context.Context, userID uuid.UUID, _ int) ([]models.RecipeRecommendation, error) {
+	s.recommendedFor = userID
+	return nil, nil
+}
+
+func queryGraphQL(t *testing.T, handler http.Handler, query string, header http.Header) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+
+	var response map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response %s: %v", recorder.Body, err)
+	}
+	return response
+}
+
+func TestGraphQLScaledRecipe(t *testing.T) {
+	handler := NewGraphQLHandler(&fakeGraphQLRecipeService{})
+	recipeID := uuid.New().String()
+
+	response := queryGraphQL(t, handler, `{ scaledRecipe(recipe_id: "`+recipeID+`", factor: 2) { recipe_id factor ingredients { name quantity unit } } }`, nil)
+	encoded, _ := json.Marshal(response)
+	want := `{"data":{"scaledRecipe":{"factor":2,"ingredients":[{"name":"flour","quantity":3,"unit":"cup"}],"recipe_id":"` + recipeID + `"}}}`
+	if string(encoded) != want {
+		t.Errorf("response = %s, want %s", encoded, want)
+	}
+
+	response = queryGraphQL(t, handler, `{ scaledRecipe(recipe_id: "`+recipeID+`", factor: 0) { factor } }`, nil)
+	errs, _ := response["errors"].([]any)
+	if len(errs) != 1 || errs[0].(map[string]any)["extensions"].(map[string]any)["code"] != "INVALID_INPUT" {
+		t.Errorf("a zero factor answered %v, want an INVALID_INPUT error", response)
+	}
+
+	response = queryGraphQL(t, handler, `{ scaledRecipe(recipe_id: "not-a-uuid", factor: 2) { factor } }`, nil)
+	errs, _ = response["errors"].([]any)
+	if len(errs) != 1 || errs[0].(map[string]any)["extensions"].(map[string]any)["code"] != "NOT_FOUND" {
+		t.Errorf("a malformed id answered %v, want a NOT_FOUND error", response)
+	}
+}
+
+func TestGraphQLReadsForwardedSession(t *testing.T) {
+	service := &fakeGraphQLRecipeService{}
+	handler := NewGraphQLHandler(service)
+	userID := uuid.New()
+
+	queryGraphQL(t, handler, `{ recommendedRecipes { recipe_id } }`, http.Header{"X-Hasura-User-Id": {userID.String()}})
+	if service.recommendedFor != userID {
+		t.Errorf("recommended for %s, want the forwarded user %s", service.recommendedFor, userID)
+	}
+}
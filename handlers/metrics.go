@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"app/models"
+	"app/services"
+	"app/utils"
+)
+
+type MetricsHandler struct {
+	deadLetterService services.DeadLetterService
+}
+
+func NewMetricsHandler(deadLetterService services.DeadLetterService) *MetricsHandler {
+	return &MetricsHandler{deadLetterService: deadLetterService}
+}
+
+// Handle serves metrics in the Prometheus text exposition format. Every
+// source and status is reported, with zeros, so series do not come and go.
+func (h *MetricsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	depths, err := h.deadLetterService.Depth(r.Context())
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, "METRICS_UNAVAILABLE", "Failed to collect metrics: "+err.Error())
+		return
+	}
+
+	counts := make(map[[2]string]int64, len(depths))
+	for _, depth := range depths {
+		counts[[2]string{depth.Source, depth.Status}] = depth.Count
+	}
+
+	var body strings.Builder
+	body.WriteString("# HELP app_dead_letters Dead letters by source and status.\n")
+	body.WriteString("# TYPE app_dead_letters gauge\n")
+	for _, source := range models.DeadLetterSources {
+		for _, status := range models.DeadLetterStatuses {
+			fmt.Fprintf(&body, "app_dead_letters{source=%q,status=%q} %d\n", source, status, counts[[2]string{source, status}])
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(body.String()))
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"app/framework"
+	"app/models"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type NotificationOutput struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	RecipeID   string     `json:"recipe_id"`
+	ActorID    string     `json:"actor_id"`
+	ActorCount int        `json:"actor_count"`
+	Message    string     `json:"message"`
+	Preview    string     `json:"preview"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type ListNotificationsInput struct {
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	UnreadOnly bool `json:"unread_only"`
+}
+
+type ListNotificationsInputWrapper struct {
+	Arg1 ListNotificationsInput `json:"arg1"`
+}
+
+type ListNotificationsResponse struct {
+	Notifications []NotificationOutput `json:"notifications"`
+	UnreadCount   int64                `json:"unread_count"`
+}
+
+type ListNotificationsHandler struct {
+	notificationService services.NotificationService
+}
+
+func (h *ListNotificationsHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper ListNotificationsInputWrapper
+	if len(action.Input) > 0 {
+		if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+			return
+		}
+	}
+
+	input := wrapper.Arg1
+	notifications, unread, err := h.notificationService.List(r.Context(), userID, input.UnreadOnly, input.Limit, input.Offset)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to list notifications: "+err.Error())
+		return
+	}
+
+	response := ListNotificationsResponse{
+		Notifications: make([]NotificationOutput, 0, len(notifications)),
+		UnreadCount:   unread,
+	}
+	for _, notification := range notifications {
+		response.Notifications = append(response.Notifications, NotificationOutput{
+			ID:         notification.ID.String(),
+			Type:       notification.Type,
+			RecipeID:   notification.RecipeID.String(),
+			ActorID:    notification.LastActorID.String(),
+			ActorCount: notification.ActorCount,
+			Message:    services.NotificationMessage(notification),
+			Preview:    notification.Preview,
+			ReadAt:     notification.ReadAt,
+			CreatedAt:  notification.CreatedAt,
+			UpdatedAt:  notification.UpdatedAt,
+		})
+	}
+	utils.EncodeJSON(w, response)
+}
+
+type MarkNotificationReadInput struct {
+	ID string `json:"id"`
+}
+
+type MarkNotificationReadInputWrapper struct {
+	Arg1 MarkNotificationReadInput `json:"arg1"`
+}
+
+type MarkNotificationReadResponse struct {
+	ID     string     `json:"id"`
+	ReadAt *time.Time `json:"read_at"`
+}
+
+type MarkNotificationReadHandler struct {
+	notificationService services.NotificationService
+}
+
+func (h *MarkNotificationReadHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper MarkNotificationReadInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	id, err := uuid.Parse(wrapper.Arg1.ID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid notification id", []utils.FieldError{
+			{Field: "id", Message: err.Error()},
+		})
+		return
+	}
+
+	notification, err := h.notificationService.MarkRead(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Notification not found")
+		} else {
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to mark notification read: "+err.Error())
+		}
+		return
+	}
+
+	utils.EncodeJSON(w, MarkNotificationReadResponse{
+		ID:     notification.ID.String(),
+		ReadAt: notification.ReadAt,
+	})
+}
+
+type MarkAllNotificationsReadResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+type MarkAllNotificationsReadHandler struct {
+	notificationService services.NotificationService
+}
+
+func (h *MarkAllNotificationsReadHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	updated, err := h.notificationService.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to mark notifications read: "+err.Error())
+		return
+	}
+	utils.EncodeJSON(w, MarkAllNotificationsReadResponse{Updated: updated})
+}
+
+type NotificationPreferenceInput struct {
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+type NotificationPreferenceInputWrapper struct {
+	Arg1 NotificationPreferenceInput `json:"arg1"`
+}
+
+type NotificationPreferenceOutput struct {
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+type NotificationPreferencesHandler struct {
+	notificationService services.NotificationService
+}
+
+func (h *NotificationPreferencesHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	preferences, err := h.notificationService.Preferences(r.Context(), userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to load notification preferences: "+err.Error())
+		return
+	}
+
+	response := make([]NotificationPreferenceOutput, 0, len(models.NotificationTypes))
+	for _, notificationType := range models.NotificationTypes {
+		response = append(response, NotificationPreferenceOutput{Type: notificationType, Enabled: preferences[notificationType]})
+	}
+	utils.EncodeJSON(w, response)
+}
+
+type UpdateNotificationPreferenceHandler struct {
+	notificationService services.NotificationService
+}
+
+func (h *UpdateNotificationPreferenceHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper NotificationPreferenceInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+
+	input := wrapper.Arg1
+	if err := h.notificationService.SetPreference(r.Context(), userID, input.Type, input.Enabled); err != nil {
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteValidationError(w, "INVALID_NOTIFICATION_TYPE", "Unknown notification type", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		} else {
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to save notification preference: "+err.Error())
+		}
+		return
+	}
+	utils.EncodeJSON(w, NotificationPreferenceOutput{Type: input.Type, Enabled: input.Enabled})
+}
+
+type NotificationEventHandler struct {
+	notificationService services.NotificationService
+}
+
+func (h *NotificationEventHandler) HandleLike(ctx context.Context, change framework.Change[models.LikedRecipe]) error {
+	if change.New == nil {
+		return nil
+	}
+	return h.notificationService.NotifyLike(ctx, change.New.RecipeID, change.New.UserID)
+}
+
+func (h *NotificationEventHandler) HandleComment(ctx context.Context, change framework.Change[models.Comment]) error {
+	if change.New == nil {
+		return nil
+	}
+	return h.notificationService.NotifyComment(ctx, change.New.RecipeID, change.New.UserID, change.New.Content)
+}
+
+func (h *NotificationEventHandler) HandleRating(ctx context.Context, change framework.Change[models.Rating]) error {
+	if change.New == nil {
+		return nil
+	}
+	return h.notificationService.NotifyRating(ctx, change.New.RecipeID, change.New.UserID, change.New.Value)
+}
+
+func RegisterNotificationHandlers(notificationService services.NotificationService) {
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("listNotifications", &ListNotificationsHandler{notificationService: notificationService})
+	dispatcher.RegisterHandler("markNotificationRead", &MarkNotificationReadHandler{notificationService: notificationService})
+	dispatcher.RegisterHandler("markAllNotificationsRead", &MarkAllNotificationsReadHandler{notificationService: notificationService})
+	dispatcher.RegisterHandler("notificationPreferences", &NotificationPreferencesHandler{notificationService: notificationService})
+	dispatcher.RegisterHandler("updateNotificationPreference", &UpdateNotificationPreferenceHandler{notificationService: notificationService})
+
+	events := &NotificationEventHandler{notificationService: notificationService}
+	eventDispatcher := framework.GetEventDispatcher()
+	framework.RegisterEventHandler(eventDispatcher, "liked_recipe_created", models.LikedRecipe{}.TableName(), events.HandleLike)
+	framework.RegisterEventHandler(eventDispatcher, "comment_created", models.Comment{}.TableName(), events.HandleComment)
+	framework.RegisterEventHandler(eventDispatcher, "rating_created", models.Rating{}.TableName(), events.HandleRating)
+}
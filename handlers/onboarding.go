@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"app/framework"
+	"app/models"
+	"app/services"
+
+	"github.com/google/uuid"
+)
+
+func onboardingStep(step func(ctx context.Context, userID uuid.UUID) error) framework.JobProcessorFunc {
+	return func(ctx context.Context, job *models.Job) (any, error) {
+		var input services.OnboardingInput
+		if err := json.Unmarshal(job.Input, &input); err != nil {
+			return nil, fmt.Errorf("invalid onboarding input: %w", err)
+		}
+		if err := step(ctx, input.UserID); err != nil {
+			return nil, err
+		}
+		return map[string]string{"user_id": input.UserID.String()}, nil
+	}
+}
+
+func RegisterOnboardingJobs(pool *framework.WorkerPool, onboardingService services.OnboardingService) {
+	pool.Register(services.JobWelcomeMessage, onboardingStep(onboardingService.SendWelcomeMessage))
+	pool.Register(services.JobDefaultCollections, onboardingStep(onboardingService.CreateDefaultCollections))
+	pool.Register(services.JobSeedFeed, onboardingStep(onboardingService.SeedFeed))
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"app/framework"
+	"app/models"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	MessageTypeRecipeUpdated = "recipe.updated"
+	MessageTypeRecipeDeleted = "recipe.deleted"
+
+	realtimeWriteWait    = 10 * time.Second
+	realtimePongWait     = 60 * time.Second
+	realtimePingInterval = 25 * time.Second
+)
+
+// RecipeUpdate is the payload sent to a recipe topic whenever the recipe row
+// changes, including the counters maintained by database triggers.
+type RecipeUpdate struct {
+	ID            string     `json:"id"`
+	Title         string     `json:"title"`
+	ThumbnailID   *uuid.UUID `json:"thumbnail_id"`
+	LikeCount     int64      `json:"like_count"`
+	RatingCount   int64      `json:"rating_count"`
+	AverageRating float64    `json:"average_rating"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SubscriptionRequest is sent by WebSocket clients to follow or stop
+// following a recipe, e.g. {"action": "subscribe", "recipe_id": "..."}.
+type SubscriptionRequest struct {
+	Action   string `json:"action"`
+	RecipeID string `json:"recipe_id"`
+}
+
+type RealtimeHandler struct {
+	hub      *framework.Hub
+	tokens   services.TokenVerifier
+	upgrader websocket.Upgrader
+}
+
+func NewRealtimeHandler(hub *framework.Hub, tokens services.TokenVerifier) *RealtimeHandler {
+	return &RealtimeHandler{
+		hub:    hub,
+		tokens: tokens,
+		upgrader: websocket.Upgrader{
+			// Browsers connect from the frontend origin, which CORS already allows.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// authenticate accepts the JWT in the Authorization header or, since browsers
+// cannot set headers on WebSocket and EventSource requests, a token parameter.
+func (h *RealtimeHandler) authenticate(r *http.Request) (uuid.UUID, error) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		if token := r.URL.Query().Get("token"); token != "" {
+			authorization = "Bearer " + token
+		}
+	}
+	if authorization == "" {
+		return uuid.Nil, fmt.Errorf("missing token")
+	}
+
+	claims, err := authenticate(r.Context(), h.tokens, authorization)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+func (h *RealtimeHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticate(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "A valid token is required")
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response.
+		return
+	}
+	defer conn.Close()
+
+	subscription := h.hub.Subscribe(services.UserTopic(userID))
+	defer subscription.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go h.readSubscriptions(conn, subscription, cancel)
+
+	ticker := time.NewTicker(realtimePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-subscription.C:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteWait))
+			if err := conn.WriteJSON(message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readSubscriptions applies subscribe and unsubscribe requests until the
+// client goes away, then cancels the write loop.
+func (h *RealtimeHandler) readSubscriptions(conn *websocket.Conn, subscription *framework.Subscription, cancel context.CancelFunc) {
+	defer cancel()
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(realtimePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(realtimePongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request SubscriptionRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			continue
+		}
+
+		recipeID, err := uuid.Parse(request.RecipeID)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(request.Action) {
+		case "subscribe":
+			subscription.Add(services.RecipeTopic(recipeID))
+		case "unsubscribe":
+			subscription.Remove(services.RecipeTopic(recipeID))
+		}
+	}
+}
+
+// HandleEvents streams the same messages as server-sent events for clients
+// that cannot use WebSockets. Recipes are followed with ?recipe_id=...
+func (h *RealtimeHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticate(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "A valid token is required")
+		return
+	}
+
+	topics := []string{services.UserTopic(userID)}
+	for _, value := range r.URL.Query()["recipe_id"] {
+		recipeID, err := uuid.Parse(value)
+		if err != nil {
+			utils.WriteValidationProblem(w, r, "INVALID_INPUT", "Invalid recipe ID", []utils.FieldError{
+				{Field: "recipe_id", Message: "must be a UUID"},
+			})
+			return
+		}
+		topics = append(topics, services.RecipeTopic(recipeID))
+	}
+
+	controller := http.NewResponseController(w)
+	// The server write timeout would otherwise end the stream.
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, "STREAMING_UNSUPPORTED", "Streaming is not supported")
+		return
+	}
+
+	subscription := h.hub.Subscribe(topics...)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	controller.Flush()
+
+	ticker := time.NewTicker(realtimePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message := <-subscription.C:
+			encoded, err := json.Marshal(message)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, encoded); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+type RecipeEventHandler struct {
+	publisher services.Publisher
+}
+
+func (h *RecipeEventHandler) HandleRecipeUpdated(ctx context.Context, change framework.Change[models.Recipe]) error {
+	if change.Op != framework.OpUpdate || change.New == nil {
+		return nil
+	}
+
+	recipe := change.New
+	messageType := MessageTypeRecipeUpdated
+	if recipe.DeletedAt.Valid {
+		messageType = MessageTypeRecipeDeleted
+	}
+	return h.publisher.Publish(ctx, services.RecipeTopic(recipe.ID), messageType, RecipeUpdate{
+		ID:            recipe.ID.String(),
+		Title:         recipe.Title,
+		ThumbnailID:   recipe.ThumbnailID,
+		LikeCount:     recipe.LikeCount,
+		RatingCount:   recipe.RatingCount,
+		AverageRating: recipe.AverageRating,
+		UpdatedAt:     recipe.UpdatedAt,
+	})
+}
+
+// HandleOutboxEvent forwards a recipe outbox event to the recipe's topic.
+func (h *RecipeEventHandler) HandleOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	return h.publisher.Publish(ctx, services.RecipeTopic(event.AggregateID), event.EventType, event.Payload)
+}
+
+func RegisterRealtimeHandlers(publisher services.Publisher, relay *framework.OutboxRelay) {
+	handler := &RecipeEventHandler{publisher: publisher}
+	framework.RegisterEventHandler(framework.GetEventDispatcher(), "recipe_updated", models.Recipe{}.TableName(), handler.HandleRecipeUpdated)
+	relay.Subscribe(models.OutboxEventRecipePictureAdded, handler.HandleOutboxEvent)
+}
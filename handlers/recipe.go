@@ -2,180 +2,728 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"app/framework"
+	"app/models"
+	"app/repositories"
 	"app/services"
 	"app/utils"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 type UploadRecipePictureHandler struct {
-	recipeService services.RecipeService
-	s3Client      *s3.Client
-	bucketName    string
+	uploadService services.PictureUploadService
+	tokens        services.TokenVerifier
+	maxBytes      int64
 }
 
-func NewUploadRecipePictureHandler(recipeService services.RecipeService, s3Client *s3.Client, bucketName string) *UploadRecipePictureHandler {
+func NewUploadRecipePictureHandler(uploadService services.PictureUploadService, tokens services.TokenVerifier, maxBytes int64) *UploadRecipePictureHandler {
 	return &UploadRecipePictureHandler{
-		recipeService: recipeService,
-		s3Client:      s3Client,
-		bucketName:    bucketName,
+		uploadService: uploadService,
+		tokens:        tokens,
+		maxBytes:      maxBytes,
 	}
 }
 
 type UploadRecipePictureResponse struct {
-	ID        string    `json:"id"`
-	RecipeID  string    `json:"recipe_id"`
-	Path      string    `json:"path"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	RecipeID    string    `json:"recipe_id"`
+	Path        string    `json:"path"`
+	ContentType string    `json:"content_type"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newUploadRecipePictureResponse(picture *models.RecipePicture) UploadRecipePictureResponse {
+	return UploadRecipePictureResponse{
+		ID:          picture.ID.String(),
+		RecipeID:    picture.RecipeId.String(),
+		Path:        picture.Path,
+		ContentType: picture.ContentType,
+		Width:       picture.Width,
+		Height:      picture.Height,
+		CreatedAt:   picture.CreatedAt,
+	}
+}
+
+// pictureUploadError maps what storing an uploaded picture can fail with to
+// a status, error code and message.
+func pictureUploadError(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, utils.ErrUnsupportedImage):
+		return http.StatusBadRequest, "INVALID_FILE_TYPE", "Only JPEG, PNG and WebP images are allowed"
+	case errors.Is(err, utils.ErrImageTooLarge):
+		return http.StatusBadRequest, "IMAGE_TOO_LARGE", err.Error()
+	case errors.Is(err, utils.ErrInvalidImage):
+		return http.StatusBadRequest, "INVALID_IMAGE", "File is not a valid image: " + err.Error()
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden, "FORBIDDEN", "Recipe is not owned by user"
+	case errors.Is(err, services.ErrNotFound), errors.Is(err, services.ErrInvalidReference):
+		return http.StatusBadRequest, "INVALID_RECIPE", "Recipe does not exist"
+	case errors.Is(err, services.ErrPresignUnsupported):
+		return http.StatusNotImplemented, "DIRECT_UPLOAD_UNSUPPORTED", "Direct uploads are not available with this storage backend"
+	default:
+		return http.StatusInternalServerError, "UPLOAD_FAILED", "Failed to upload picture: " + err.Error()
+	}
 }
 
 func (h *UploadRecipePictureHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_FORM", "Failed to parse form: "+err.Error())
+		utils.WriteProblem(w, r, http.StatusBadRequest, "INVALID_FORM", "Failed to parse form: "+err.Error())
 		return
 	}
 
-	_, err := utils.ParseJWT(r.Header.Get("Authorization"))
+	claims, err := authenticate(r.Context(), h.tokens, r.Header.Get("Authorization"))
 	if err != nil {
-		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid authorization token")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid authorization token")
 		return
 	}
 
 	recipeIDStr := r.FormValue("recipe_id")
 	if recipeIDStr == "" {
-		utils.WriteError(w, http.StatusBadRequest, "MISSING_RECIPE_ID", "recipe_id is required")
+		utils.WriteValidationProblem(w, r, "MISSING_RECIPE_ID", "recipe_id is required", []utils.FieldError{
+			{Field: "recipe_id", Message: "is required"},
+		})
 		return
 	}
 
 	recipeID, err := uuid.Parse(recipeIDStr)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_RECIPE_ID", "Invalid recipe_id: "+err.Error())
+		utils.WriteValidationProblem(w, r, "INVALID_RECIPE_ID", "Invalid recipe_id", []utils.FieldError{
+			{Field: "recipe_id", Message: err.Error()},
+		})
 		return
 	}
 
 	file, handler, err := r.FormFile("file")
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_FILE", "Failed to get file: "+err.Error())
+		utils.WriteProblem(w, r, http.StatusBadRequest, "INVALID_FILE", "Failed to get file: "+err.Error())
 		return
 	}
 	defer file.Close()
 
-	if handler.Size > 5<<20 { // 5MB limit
-		utils.WriteError(w, http.StatusBadRequest, "FILE_TOO_LARGE", "File size exceeds 5MB")
+	if handler.Size > h.maxBytes {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "FILE_TOO_LARGE", fmt.Sprintf("File size exceeds %d bytes", h.maxBytes))
 		return
 	}
-	ext := strings.ToLower(filepath.Ext(handler.Filename))
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_FILE_TYPE", "Only JPG and PNG files are allowed")
+
+	picture, err := h.uploadService.Upload(r.Context(), claims.UserID, recipeID, file)
+	if err != nil {
+		status, code, message := pictureUploadError(err)
+		utils.WriteProblem(w, r, status, code, message)
 		return
 	}
 
-	fileID := uuid.New()
-	objectKey := fmt.Sprintf("%s%s", fileID, ext)
+	utils.EncodeJSON(w, newUploadRecipePictureResponse(picture))
+}
+
+type DeleteRecipePictureHandler struct {
+	recipeService  services.RecipeService
+	pictureService services.PictureService
+	tokens         services.TokenVerifier
+}
+
+func NewDeleteRecipePictureHandler(recipeService services.RecipeService, pictureService services.PictureService, tokens services.TokenVerifier) *DeleteRecipePictureHandler {
+	return &DeleteRecipePictureHandler{
+		recipeService:  recipeService,
+		pictureService: pictureService,
+		tokens:         tokens,
+	}
+}
+
+func (h *DeleteRecipePictureHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims, err := authenticate(r.Context(), h.tokens, r.Header.Get("Authorization"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid authorization token")
+		return
+	}
 
-	_, err = h.s3Client.PutObject(context.Background(), &s3.PutObjectInput{
-		Bucket:      &h.bucketName,
-		Key:         &objectKey,
-		Body:        file,
-		ContentType: &handler.Header["Content-Type"][0],
-	})
+	pictureID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "UPLOAD_FAILED", "Failed to upload to MinIO: "+err.Error())
+		utils.WriteProblem(w, r, http.StatusBadRequest, "INVALID_ID", "Invalid picture ID: "+err.Error())
 		return
 	}
 
-	picture, err := h.recipeService.SaveRecipePicture(recipeID, objectKey)
+	picture, err := h.recipeService.DeleteRecipePicture(r.Context(), claims.UserID, pictureID)
 	if err != nil {
-		h.s3Client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
-			Bucket: &h.bucketName,
-			Key:    &objectKey,
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			utils.WriteProblem(w, r, http.StatusForbidden, "FORBIDDEN", "Recipe is not owned by user")
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteProblem(w, r, http.StatusNotFound, "NOT_FOUND", "Picture not found")
+		default:
+			utils.WriteProblem(w, r, http.StatusInternalServerError, "DB_ERROR", "Failed to delete picture: "+err.Error())
+		}
+		return
+	}
+
+	h.pictureService.RemoveObjects(r.Context(), picture)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type DeleteRecipePictureInput struct {
+	ID string `json:"id"`
+}
+
+type DeleteRecipePictureInputWrapper struct {
+	Arg1 DeleteRecipePictureInput `json:"arg1"`
+}
+
+type DeleteRecipePictureResponse struct {
+	ID       uuid.UUID `json:"id"`
+	RecipeID uuid.UUID `json:"recipe_id"`
+}
+
+type DeleteRecipePictureActionHandler struct {
+	recipeService  services.RecipeService
+	pictureService services.PictureService
+}
+
+func (h *DeleteRecipePictureActionHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper DeleteRecipePictureInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+
+	pictureID, err := uuid.Parse(wrapper.Arg1.ID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid picture id", []utils.FieldError{
+			{Field: "id", Message: err.Error()},
 		})
-		if strings.Contains(err.Error(), "foreign key") {
-			utils.WriteError(w, http.StatusBadRequest, "INVALID_RECIPE", "Recipe does not exist or not owned by user")
-		} else {
-			utils.WriteError(w, http.StatusInternalServerError, "DB_ERROR", "Failed to save picture: "+err.Error())
+		return
+	}
+
+	picture, err := h.recipeService.DeleteRecipePicture(r.Context(), userID, pictureID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Recipe is not owned by user")
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Picture not found")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to delete picture: "+err.Error())
+		}
+		return
+	}
+
+	h.pictureService.RemoveObjects(r.Context(), picture)
+	utils.EncodeJSON(w, DeleteRecipePictureResponse{ID: picture.ID, RecipeID: picture.RecipeId})
+}
+
+type ReorderRecipePicturesInput struct {
+	RecipeID   string   `json:"recipe_id"`
+	PictureIDs []string `json:"picture_ids"`
+}
+
+type ReorderRecipePicturesInputWrapper struct {
+	Arg1 ReorderRecipePicturesInput `json:"arg1"`
+}
+
+type RecipePicturePositionOutput struct {
+	ID       uuid.UUID `json:"id"`
+	Position int       `json:"position"`
+}
+
+type ReorderRecipePicturesHandler struct {
+	recipeService services.RecipeService
+}
+
+func (h *ReorderRecipePicturesHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper ReorderRecipePicturesInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	input := wrapper.Arg1
+
+	recipeID, err := uuid.Parse(input.RecipeID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid recipe id", []utils.FieldError{
+			{Field: "recipe_id", Message: err.Error()},
+		})
+		return
+	}
+	pictureIDs := make([]uuid.UUID, 0, len(input.PictureIDs))
+	for _, raw := range input.PictureIDs {
+		pictureID, err := uuid.Parse(raw)
+		if err != nil {
+			utils.WriteValidationError(w, "INVALID_ID", "Invalid picture id", []utils.FieldError{
+				{Field: "picture_ids", Message: err.Error()},
+			})
+			return
+		}
+		pictureIDs = append(pictureIDs, pictureID)
+	}
+
+	pictures, err := h.recipeService.ReorderRecipePictures(r.Context(), userID, recipeID, pictureIDs)
+	if err != nil {
+		var validationErr *services.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.WriteValidationError(w, "INVALID_ORDER", "Invalid picture order", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		case errors.Is(err, services.ErrForbidden):
+			utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Recipe is not owned by user")
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Recipe not found")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to reorder pictures: "+err.Error())
 		}
 		return
 	}
 
-	response := UploadRecipePictureResponse{
-		ID:        picture.ID.String(),
-		RecipeID:  picture.RecipeId.String(),
-		Path:      picture.Path,
-		CreatedAt: picture.CreatedAt,
+	response := make([]RecipePicturePositionOutput, 0, len(pictures))
+	for _, picture := range pictures {
+		response = append(response, RecipePicturePositionOutput{ID: picture.ID, Position: picture.Position})
 	}
 	utils.EncodeJSON(w, response)
 }
 
-type GetRecipePictureHandler struct {
+type SetRecipeThumbnailInput struct {
+	RecipeID  string  `json:"recipe_id"`
+	PictureID *string `json:"picture_id"`
+}
+
+type SetRecipeThumbnailInputWrapper struct {
+	Arg1 SetRecipeThumbnailInput `json:"arg1"`
+}
+
+type SetRecipeThumbnailResponse struct {
+	ID          string     `json:"id"`
+	ThumbnailID *uuid.UUID `json:"thumbnail_id"`
+}
+
+type SetRecipeThumbnailHandler struct {
 	recipeService services.RecipeService
-	s3Client      *s3.Client
-	bucketName    string
 }
 
-func NewGetRecipePictureHandler(recipeService services.RecipeService, s3Client *s3.Client, bucketName string) *GetRecipePictureHandler {
+func (h *SetRecipeThumbnailHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper SetRecipeThumbnailInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	input := wrapper.Arg1
+
+	recipeID, err := uuid.Parse(input.RecipeID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid recipe id", []utils.FieldError{
+			{Field: "recipe_id", Message: err.Error()},
+		})
+		return
+	}
+	var pictureID *uuid.UUID
+	if input.PictureID != nil {
+		parsed, err := uuid.Parse(*input.PictureID)
+		if err != nil {
+			utils.WriteValidationError(w, "INVALID_ID", "Invalid picture id", []utils.FieldError{
+				{Field: "picture_id", Message: err.Error()},
+			})
+			return
+		}
+		pictureID = &parsed
+	}
+
+	recipe, err := h.recipeService.SetRecipeThumbnail(r.Context(), userID, recipeID, pictureID)
+	if err != nil {
+		var validationErr *services.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.WriteValidationError(w, "INVALID_PICTURE", "Invalid thumbnail picture", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		case errors.Is(err, services.ErrForbidden):
+			utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Recipe is not owned by user")
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Recipe not found")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to set recipe thumbnail: "+err.Error())
+		}
+		return
+	}
+	utils.EncodeJSON(w, SetRecipeThumbnailResponse{ID: recipe.ID.String(), ThumbnailID: recipe.ThumbnailID})
+}
+
+type SetRecipeStepPictureInput struct {
+	StepID    string  `json:"step_id"`
+	PictureID *string `json:"picture_id"`
+}
+
+type SetRecipeStepPictureInputWrapper struct {
+	Arg1 SetRecipeStepPictureInput `json:"arg1"`
+}
+
+type SetRecipeStepPictureResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	RecipeID  uuid.UUID  `json:"recipe_id"`
+	PictureID *uuid.UUID `json:"picture_id"`
+}
+
+type SetRecipeStepPictureHandler struct {
+	recipeService services.RecipeService
+}
+
+func (h *SetRecipeStepPictureHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper SetRecipeStepPictureInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	input := wrapper.Arg1
+
+	stepID, err := uuid.Parse(input.StepID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid step id", []utils.FieldError{
+			{Field: "step_id", Message: err.Error()},
+		})
+		return
+	}
+	var pictureID *uuid.UUID
+	if input.PictureID != nil {
+		parsed, err := uuid.Parse(*input.PictureID)
+		if err != nil {
+			utils.WriteValidationError(w, "INVALID_ID", "Invalid picture id", []utils.FieldError{
+				{Field: "picture_id", Message: err.Error()},
+			})
+			return
+		}
+		pictureID = &parsed
+	}
+
+	step, err := h.recipeService.SetRecipeStepPicture(r.Context(), userID, stepID, pictureID)
+	if err != nil {
+		var validationErr *services.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.WriteValidationError(w, "INVALID_PICTURE", "Invalid step picture", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		case errors.Is(err, services.ErrForbidden):
+			utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Recipe is not owned by user")
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Recipe step not found")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to set step picture: "+err.Error())
+		}
+		return
+	}
+	utils.EncodeJSON(w, SetRecipeStepPictureResponse{ID: step.ID, RecipeID: step.RecipeID, PictureID: step.PictureID})
+}
+
+func RegisterRecipeHandlers(recipeService services.RecipeService, pictureService services.PictureService) {
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("deleteRecipePicture", &DeleteRecipePictureActionHandler{recipeService: recipeService, pictureService: pictureService})
+	dispatcher.RegisterHandler("reorderRecipePictures", &ReorderRecipePicturesHandler{recipeService: recipeService})
+	dispatcher.RegisterHandler("setRecipeThumbnail", &SetRecipeThumbnailHandler{recipeService: recipeService})
+	dispatcher.RegisterHandler("setRecipeStepPicture", &SetRecipeStepPictureHandler{recipeService: recipeService})
+}
+
+type GetRecipePictureHandler struct {
+	recipeService  services.RecipeService
+	pictureService services.PictureService
+	objects        repositories.ObjectStore
+}
+
+func NewGetRecipePictureHandler(recipeService services.RecipeService, pictureService services.PictureService, objects repositories.ObjectStore) *GetRecipePictureHandler {
 	return &GetRecipePictureHandler{
-		recipeService: recipeService,
-		s3Client:      s3Client,
-		bucketName:    bucketName,
+		recipeService:  recipeService,
+		pictureService: pictureService,
+		objects:        objects,
 	}
 }
 
+// Handle streams a picture. ?variant= names a resized variant and ?w= picks
+// the narrowest one at least that wide; without either the original is sent.
+// Clients that name a converted format in Accept get the picture in it, and
+// those that do not name WebP get WebP pictures as PNG.
 func (h *GetRecipePictureHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	pictureIDStr := chi.URLParam(r, "id")
 	if pictureIDStr == "" {
-		utils.WriteError(w, http.StatusBadRequest, "MISSING_ID", "Picture ID is required")
+		utils.WriteProblem(w, r, http.StatusBadRequest, "MISSING_ID", "Picture ID is required")
 		return
 	}
 
 	pictureID, err := uuid.Parse(pictureIDStr)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_ID", "Invalid picture ID: "+err.Error())
+		utils.WriteProblem(w, r, http.StatusBadRequest, "INVALID_ID", "Invalid picture ID: "+err.Error())
 		return
 	}
 
-	picture, err := h.recipeService.FindRecipePictureByID(pictureID)
+	var variant *services.PictureVariant
+	if name := r.URL.Query().Get("variant"); name != "" {
+		found, ok := services.FindPictureVariant(name)
+		if !ok {
+			names := make([]string, 0, len(services.PictureVariants))
+			for _, known := range services.PictureVariants {
+				names = append(names, known.Name)
+			}
+			utils.WriteValidationProblem(w, r, "INVALID_VARIANT", "Unknown picture variant", []utils.FieldError{
+				{Field: "variant", Message: "must be one of " + strings.Join(names, ", ")},
+			})
+			return
+		}
+		variant = &found
+	} else if widthStr := r.URL.Query().Get("w"); widthStr != "" {
+		width, err := strconv.Atoi(widthStr)
+		if err != nil || width <= 0 {
+			utils.WriteValidationProblem(w, r, "INVALID_WIDTH", "Invalid picture width", []utils.FieldError{
+				{Field: "w", Message: "must be a positive integer"},
+			})
+			return
+		}
+		found := services.PictureVariantForWidth(width)
+		variant = &found
+	}
+
+	picture, err := h.recipeService.FindRecipePictureByID(r.Context(), pictureID)
 	if err != nil {
-		if strings.Contains(err.Error(), "record not found") {
-			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Picture not found")
+		if errors.Is(err, services.ErrNotFound) {
+			utils.WriteProblem(w, r, http.StatusNotFound, "NOT_FOUND", "Picture not found")
 		} else {
-			utils.WriteError(w, http.StatusInternalServerError, "DB_ERROR", "Failed to fetch picture: "+err.Error())
+			utils.WriteProblem(w, r, http.StatusInternalServerError, "DB_ERROR", "Failed to fetch picture: "+err.Error())
 		}
 		return
 	}
+	if _, ok := utils.ImageFormats[picture.ContentType]; !ok {
+		if err := h.resolveContentType(r.Context(), picture); err != nil {
+			writePictureObjectProblem(w, r, err, "STORAGE_ERROR", "Failed to fetch image from storage")
+			return
+		}
+	}
 
-	obj, err := h.s3Client.GetObject(context.Background(), &s3.GetObjectInput{
-		Bucket: &h.bucketName,
-		Key:    &picture.Path,
-	})
+	w.Header().Add("Vary", "Accept")
+	contentType := picture.ContentType
+	if _, ok := utils.ImageFormats[contentType]; ok {
+		for _, converted := range utils.ConvertedImageFormats {
+			// Nothing the picture is already stored as is worth converting to.
+			if converted == picture.ContentType {
+				break
+			}
+			if utils.AcceptsExplicitly(r, converted) {
+				contentType = converted
+				break
+			}
+		}
+	}
+	// Clients that do not name a picture's format get its fallback, as do
+	// variants of formats this build cannot encode.
+	if fallback, ok := utils.ImageFallbacks[contentType]; ok {
+		if !utils.AcceptsExplicitly(r, contentType) || (variant != nil && !utils.CanEncodeImage(contentType)) {
+			contentType = fallback
+		}
+	}
+	if variant == nil && contentType != picture.ContentType {
+		variant = &services.FullPicture
+	}
 
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "MINIO_ERROR", "Failed to fetch image from MinIO: "+err.Error())
+	var info *repositories.ObjectInfo
+	if variant != nil {
+		info, err = h.pictureService.VariantInfo(r.Context(), picture, *variant, contentType)
+		if err != nil {
+			writePictureObjectProblem(w, r, err, "VARIANT_FAILED", "Failed to render picture variant")
+			return
+		}
+	} else {
+		info, err = h.objects.Head(r.Context(), picture.Path)
+		if err != nil {
+			writePictureObjectProblem(w, r, err, "STORAGE_ERROR", "Failed to fetch image from storage")
+			return
+		}
+		if contentType == "" {
+			contentType = info.ContentType
+		}
+	}
+
+	if utils.NotModified(r, info.ETag, info.LastModified) {
+		setPictureValidators(w, picture, info)
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
-	defer obj.Body.Close()
 
-	contentType := "application/octet-stream"
-	if strings.HasSuffix(picture.Path, ".jpg") || strings.HasSuffix(picture.Path, ".jpeg") {
-		contentType = "image/jpeg"
-	} else if strings.HasSuffix(picture.Path, ".png") {
-		contentType = "image/png"
+	start, end, ranged, err := utils.RequestedRange(r, info.ETag, info.LastModified, info.Size)
+	if errors.Is(err, utils.ErrRangeNotSatisfiable) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		utils.WriteProblem(w, r, http.StatusRequestedRangeNotSatisfiable, "RANGE_NOT_SATISFIABLE", "Requested range is outside the picture")
+		return
 	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	setPictureValidators(w, picture, info)
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Cache-Control", "max-age=31536000")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	status := http.StatusOK
+	length := info.Size
+	var byteRange *repositories.ByteRange
+	if ranged {
+		status = http.StatusPartialContent
+		length = end - start + 1
+		byteRange = &repositories.ByteRange{Start: start, End: end}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(status)
+		return
+	}
 
-	_, err = io.Copy(w, obj.Body)
+	var object *repositories.Object
+	if variant != nil {
+		object, err = h.pictureService.OpenVariant(r.Context(), picture, *variant, contentType, byteRange)
+	} else {
+		object, err = h.objects.Get(r.Context(), picture.Path, byteRange)
+	}
+	if err != nil {
+		writePictureObjectProblem(w, r, err, "STORAGE_ERROR", "Failed to fetch image from storage")
+		return
+	}
+	defer object.Body.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(status)
+	_, err = io.Copy(w, object.Body)
 	if err != nil {
 		fmt.Printf("Error streaming image: %v\n", err)
 		return
 	}
 }
+
+// resolveContentType fills in the format of pictures recorded before formats
+// were sniffed on upload, from the stored object or else the path's
+// extension.
+func (h *GetRecipePictureHandler) resolveContentType(ctx context.Context, picture *models.RecipePicture) error {
+	info, err := h.objects.Head(ctx, picture.Path)
+	if err != nil {
+		return err
+	}
+	if _, ok := utils.ImageFormats[info.ContentType]; ok {
+		picture.ContentType = info.ContentType
+	} else {
+		picture.ContentType = utils.ImageFormatForExtension(picture.Path)
+	}
+	return nil
+}
+
+// writePictureObjectProblem answers a failure to read a picture's object. A
+// picture whose object is gone is reported as missing, not as a server error.
+func writePictureObjectProblem(w http.ResponseWriter, r *http.Request, err error, code, message string) {
+	if errors.Is(err, services.ErrNotFound) {
+		utils.WriteProblem(w, r, http.StatusNotFound, "NOT_FOUND", "Picture file not found")
+		return
+	}
+	if errors.Is(err, repositories.ErrInvalidRange) {
+		utils.WriteProblem(w, r, http.StatusRequestedRangeNotSatisfiable, "RANGE_NOT_SATISFIABLE", "Requested range is outside the picture")
+		return
+	}
+	utils.WriteProblem(w, r, http.StatusInternalServerError, code, message+": "+err.Error())
+}
+
+// setPictureValidators sets what caches need to keep and revalidate a
+// picture. Only pictures stored under their content's hash never change, so
+// the others are revalidated on every use.
+func setPictureValidators(w http.ResponseWriter, picture *models.RecipePicture, info *repositories.ObjectInfo) {
+	if info.ETag != "" {
+		w.Header().Set("ETag", info.ETag)
+	}
+	if !info.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if services.ContentAddressed(picture.Path) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+func RegisterPictureJobs(pool *framework.WorkerPool, pictureService services.PictureService) {
+	pool.Register(services.JobPictureVariants, framework.JobProcessorFunc(func(ctx context.Context, job *models.Job) (any, error) {
+		var input services.PictureInput
+		if err := json.Unmarshal(job.Input, &input); err != nil {
+			return nil, fmt.Errorf("invalid picture variants input: %w", err)
+		}
+		if err := pictureService.GenerateVariants(ctx, input.PictureID); err != nil {
+			return nil, err
+		}
+		return input, nil
+	}))
+
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterAsyncHandler("regenerateRecipePictures", pool, framework.JobProcessorFunc(func(ctx context.Context, job *models.Job) (any, error) {
+		var wrapper RegenerateRecipePicturesInputWrapper
+		if err := json.Unmarshal(job.Input, &wrapper); err != nil {
+			return nil, framework.Permanent(fmt.Errorf("Invalid input format: %w", err))
+		}
+		recipeID, err := uuid.Parse(wrapper.Arg1.RecipeID)
+		if err != nil {
+			return nil, framework.Permanent(fmt.Errorf("Invalid recipe id: %w", err))
+		}
+		if job.UserID == nil {
+			return nil, framework.Permanent(errors.New("A signed in user is required"))
+		}
+
+		count, err := pictureService.RegenerateVariants(ctx, *job.UserID, recipeID)
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			return nil, framework.Permanent(errors.New("Recipe is not owned by user"))
+		case errors.Is(err, services.ErrNotFound):
+			return nil, framework.Permanent(errors.New("Recipe not found"))
+		case err != nil:
+			return nil, err
+		}
+		return RegenerateRecipePicturesResponse{RecipeID: recipeID, Pictures: count}, nil
+	}))
+}
+
+type RegenerateRecipePicturesInput struct {
+	RecipeID string `json:"recipe_id"`
+}
+
+type RegenerateRecipePicturesInputWrapper struct {
+	Arg1 RegenerateRecipePicturesInput `json:"arg1"`
+}
+
+type RegenerateRecipePicturesResponse struct {
+	RecipeID uuid.UUID `json:"recipe_id"`
+	Pictures int       `json:"pictures"`
+}
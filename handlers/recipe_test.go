@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"app/models"
+	"app/repositories"
+	"app/services"
+	"app/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// fakeStoredObjects counts references the way the recipe_picture trigger
+// does, when fakeRecipeRepository saves and deletes pictures.
+type fakeStoredObjects struct {
+	repositories.StoredObjectRepository
+	objects map[string]*models.StoredObject
+}
+
+func (r *fakeStoredObjects) Claim(_ context.Context, object *models.StoredObject) (int, error) {
+	if existing, exists := r.objects[object.Key]; exists {
+		return existing.RefCount, nil
+	}
+	copied := *object
+	r.objects[object.Key] = &copied
+	return 0, nil
+}
+
+func (r *fakeStoredObjects) LockUnreferenced(_ context.Context, key string) (*models.StoredObject, error) {
+	object, exists := r.objects[key]
+	if !exists || object.RefCount != 0 {
+		return nil, repositories.ErrNotFound
+	}
+	return object, nil
+}
+
+func (r *fakeStoredObjects) Delete(_ context.Context, key string) error {
+	delete(r.objects, key)
+	return nil
+}
+
+type fakeRecipeRepository struct {
+	repositories.RecipeRepository
+	stored   *fakeStoredObjects
+	recipes  map[string]*models.Recipe
+	pictures map[string]*models.RecipePicture
+}
+
+func (r *fakeRecipeRepository) FindRecipeByID(_ context.Context, id string) (*models.Recipe, error) {
+	recipe, exists := r.recipes[id]
+	if !exists {
+		return nil, repositories.ErrNotFound
+	}
+	return recipe, nil
+}
+
+func (r *fakeRecipeRepository) LockRecipe(_ context.Context, id string) error {
+	if _, exists := r.recipes[id]; !exists {
+		return repositories.ErrNotFound
+	}
+	return nil
+}
+
+func (r *fakeRecipeRepository) SaveRecipePicture(_ context.Context, picture *models.RecipePicture) error {
+	r.pictures[picture.ID.String()] = picture
+	r.stored.objects[picture.Path].RefCount++
+	return nil
+}
+
+func (r *fakeRecipeRepository) FindRecipePictureByID(_ context.Context, id string) (*models.RecipePicture, error) {
+	picture, exists := r.pictures[id]
+	if !exists {
+		return nil, repositories.ErrNotFound
+	}
+	copied := *picture
+	return &copied, nil
+}
+
+func (r *fakeRecipeRepository) DeleteRecipePicture(_ context.Context, id string) error {
+	picture, exists := r.pictures[id]
+	if !exists {
+		return repositories.ErrNotFound
+	}
+	delete(r.pictures, id)
+	r.stored.objects[picture.Path].RefCount--
+	return nil
+}
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeOutbox struct {
+	repositories.OutboxRepository
+}
+
+func (fakeOutbox) Add(context.Context, *models.OutboxEvent) error {
+	return nil
+}
+
+type fakeJobs struct {
+	repositories.JobRepository
+}
+
+func (fakeJobs) Enqueue(context.Context, *models.Job) error {
+	return nil
+}
+
+type fakeAuditor struct {
+	services.Auditor
+}
+
+func (fakeAuditor) Record(context.Context, services.AuditEntry) error {
+	return nil
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) VerifyToken(context.Context, uuid.UUID, int) error {
+	return nil
+}
+
+// pictureFixture serves the picture routes for one recipe, storing objects
+// in memory.
+type pictureFixture struct {
+	t        *testing.T
+	recipes  *fakeRecipeRepository
+	stored   *fakeStoredObjects
+	objects  repositories.ObjectStore
+	router   chi.Router
+	recipeID uuid.UUID
+	token    string
+}
+
+func newPictureFixture(t *testing.T) *pictureFixture {
+	stored := &fakeStoredObjects{objects: map[string]*models.StoredObject{}}
+	ownerID, recipeID := uuid.New(), uuid.New()
+	recipes := &fakeRecipeRepository{
+		stored:   stored,
+		recipes:  map[string]*models.Recipe{recipeID.String(): {ID: recipeID, CreatorID: ownerID}},
+		pictures: map[string]*models.RecipePicture{},
+	}
+	objects := repositories.NewMemoryObjectStore()
+
+	recipeService := services.NewRecipeService(recipes, fakeTransactor{}, fakeOutbox{}, fakeJobs{}, fakeAuditor{})
+	pictureService := services.NewPictureService(recipes, stored, objects, fakeTransactor{})
+	uploadService := services.NewPictureUploadService(recipeService, recipes, nil, stored, objects, fakeTransactor{}, services.PictureUploadSettings{
+		MaxBytes: 1 << 20,
+		Limits:   utils.ImageLimits{MaxDimension: 1024, MaxPixels: 1 << 20},
+	})
+
+	getHandler := NewGetRecipePictureHandler(recipeService, pictureService, objects)
+	router := chi.NewRouter()
+	router.Post("/api/recipe/picture", NewUploadRecipePictureHandler(uploadService, fakeTokens{}, 1<<20).Handle)
+	router.Get("/api/recipe/picture/{id}", getHandler.Handle)
+	router.Head("/api/recipe/picture/{id}", getHandler.Handle)
+	router.Delete("/api/recipe/picture/{id}", NewDeleteRecipePictureHandler(recipeService, pictureService, fakeTokens{}).Handle)
+
+	token, err := utils.GenerateJWT(ownerID, models.RoleUser, 0)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	return &pictureFixture{
+		t:        t,
+		recipes:  recipes,
+		stored:   stored,
+		objects:  objects,
+		router:   router,
+		recipeID: recipeID,
+		token:    token,
+	}
+}
+
+func (f *pictureFixture) serve(request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	f.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// upload posts a PNG filled with fill and returns the picture recorded.
+func (f *pictureFixture) upload(fill color.Color) UploadRecipePictureResponse {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := range 48 {
+		for x := range 64 {
+			img.Set(x, y, fill)
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		f.t.Fatalf("png.Encode: %v", err)
+	}
+	return f.uploadFile("picture.png", encoded.Bytes())
+}
+
+func (f *pictureFixture) uploadFile(name string, data []byte) UploadRecipePictureResponse {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("recipe_id", f.recipeID.String())
+	file, _ := form.CreateFormFile("file", name)
+	file.Write(data)
+	form.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/recipe/picture", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	request.Header.Set("Authorization", "Bearer "+f.token)
+	recorder := f.serve(request)
+	if recorder.Code != http.StatusOK {
+		f.t.Fatalf("upload = %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var picture UploadRecipePictureResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &picture); err != nil {
+		f.t.Fatalf("decoding upload response: %v", err)
+	}
+	return picture
+}
+
+func (f *pictureFixture) get(id string, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/api/recipe/picture/"+id, nil)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	return f.serve(request)
+}
+
+func (f *pictureFixture) delete(id string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodDelete, "/api/recipe/picture/"+id, nil)
+	request.Header.Set("Authorization", "Bearer "+f.token)
+	return f.serve(request)
+}
+
+func (f *pictureFixture) stores(key string) bool {
+	_, err := f.objects.Head(context.Background(), key)
+	return err == nil
+}
+
+func TestGetRecipePicture(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.upload(color.RGBA{R: 200, A: 255})
+
+	recorder := f.get(picture.ID, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET = %d: %s", recorder.Code, recorder.Body)
+	}
+	object, err := f.objects.Get(context.Background(), picture.Path, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if recorder.Body.Len() != int(object.Info.Size) {
+		t.Errorf("body is %d bytes, want %d", recorder.Body.Len(), object.Info.Size)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":   "image/png",
+		"ETag":           object.Info.ETag,
+		"Accept-Ranges":  "bytes",
+		"Cache-Control":  "public, max-age=31536000, immutable",
+		"Content-Length": strconv.FormatInt(object.Info.Size, 10),
+	} {
+		if got := recorder.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGetRecipePictureNotModified(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.upload(color.RGBA{G: 200, A: 255})
+	etag := f.get(picture.ID, nil).Header().Get("ETag")
+
+	recorder := f.get(picture.ID, http.Header{"If-None-Match": {etag}})
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("GET with a matching If-None-Match = %d, want 304", recorder.Code)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Errorf("304 has a %d byte body", recorder.Body.Len())
+	}
+	if recorder.Header().Get("ETag") != etag {
+		t.Errorf("ETag = %q, want %q", recorder.Header().Get("ETag"), etag)
+	}
+
+	recorder = f.get(picture.ID, http.Header{"If-None-Match": {`"stale"`}})
+	if recorder.Code != http.StatusOK {
+		t.Errorf("GET with a stale If-None-Match = %d, want 200", recorder.Code)
+	}
+}
+
+func TestGetRecipePictureRange(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.upload(color.RGBA{B: 200, A: 255})
+	full := f.get(picture.ID, nil)
+	size := full.Body.Len()
+	etag := full.Header().Get("ETag")
+
+	recorder := f.get(picture.ID, http.Header{"Range": {"bytes=4-13"}})
+	if recorder.Code != http.StatusPartialContent {
+		t.Fatalf("ranged GET = %d, want 206", recorder.Code)
+	}
+	if want := fmt.Sprintf("bytes 4-13/%d", size); recorder.Header().Get("Content-Range") != want {
+		t.Errorf("Content-Range = %q, want %q", recorder.Header().Get("Content-Range"), want)
+	}
+	if !bytes.Equal(recorder.Body.Bytes(), full.Body.Bytes()[4:14]) {
+		t.Errorf("ranged body = %x, want %x", recorder.Body.Bytes(), full.Body.Bytes()[4:14])
+	}
+
+	recorder = f.get(picture.ID, http.Header{"Range": {"bytes=-5"}})
+	if recorder.Code != http.StatusPartialContent || !bytes.Equal(recorder.Body.Bytes(), full.Body.Bytes()[size-5:]) {
+		t.Errorf("suffix range = %d %x, want 206 with the last 5 bytes", recorder.Code, recorder.Body.Bytes())
+	}
+
+	recorder = f.get(picture.ID, http.Header{"Range": {fmt.Sprintf("bytes=%d-", size)}})
+	if recorder.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("range past the end = %d, want 416", recorder.Code)
+	}
+	if want := fmt.Sprintf("bytes */%d", size); recorder.Header().Get("Content-Range") != want {
+		t.Errorf("Content-Range = %q, want %q", recorder.Header().Get("Content-Range"), want)
+	}
+
+	recorder = f.get(picture.ID, http.Header{"Range": {"bytes=4-13"}, "If-Range": {etag}})
+	if recorder.Code != http.StatusPartialContent {
+		t.Errorf("range with a matching If-Range = %d, want 206", recorder.Code)
+	}
+	recorder = f.get(picture.ID, http.Header{"Range": {"bytes=4-13"}, "If-Range": {`"stale"`}})
+	if recorder.Code != http.StatusOK || recorder.Body.Len() != size {
+		t.Errorf("range with a stale If-Range = %d with %d bytes, want 200 with all %d", recorder.Code, recorder.Body.Len(), size)
+	}
+}
+
+func TestGetRecipePictureMissingObject(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.upload(color.RGBA{R: 100, G: 100, A: 255})
+	if err := f.objects.Delete(context.Background(), picture.Path); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if recorder := f.get(picture.ID, nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("GET of a picture without its object = %d, want 404", recorder.Code)
+	}
+	if recorder := f.get(uuid.NewString(), nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("GET of an unknown picture = %d, want 404", recorder.Code)
+	}
+}
+
+func TestDeleteRecipePictureRemovesObject(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.upload(color.RGBA{G: 200, A: 255})
+
+	if recorder := f.delete(picture.ID); recorder.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d: %s", recorder.Code, recorder.Body)
+	}
+	if f.stores(picture.Path) {
+		t.Error("the object outlived its picture")
+	}
+	if recorder := f.get(picture.ID, nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted picture = %d, want 404", recorder.Code)
+	}
+}
+
+func TestRecipePicturesShareObjects(t *testing.T) {
+	f := newPictureFixture(t)
+	first := f.upload(color.RGBA{R: 50, B: 50, A: 255})
+	second := f.upload(color.RGBA{R: 50, B: 50, A: 255})
+	other := f.upload(color.RGBA{G: 50, B: 50, A: 255})
+
+	if first.Path != second.Path {
+		t.Fatalf("the same content was stored as %s and %s", first.Path, second.Path)
+	}
+	if other.Path == first.Path {
+		t.Fatalf("different content was stored under the same key %s", first.Path)
+	}
+	if refs := f.stored.objects[first.Path].RefCount; refs != 2 {
+		t.Errorf("shared object has %d references, want 2", refs)
+	}
+
+	if recorder := f.delete(first.ID); recorder.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d: %s", recorder.Code, recorder.Body)
+	}
+	if !f.stores(first.Path) {
+		t.Fatal("deleting one of two pictures removed their shared object")
+	}
+	if recorder := f.get(second.ID, nil); recorder.Code != http.StatusOK {
+		t.Errorf("GET of the remaining picture = %d, want 200", recorder.Code)
+	}
+
+	if recorder := f.delete(second.ID); recorder.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d: %s", recorder.Code, recorder.Body)
+	}
+	if f.stores(first.Path) {
+		t.Error("the object outlived every picture using it")
+	}
+	if _, exists := f.stored.objects[first.Path]; exists {
+		t.Error("the stored object record outlived every picture using it")
+	}
+	if !f.stores(other.Path) {
+		t.Error("deleting pictures removed another picture's object")
+	}
+}
+
+// tinyWebP is a lossless 1x1 WebP.
+var tinyWebP, _ = base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+
+func TestUploadWebPPicture(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.uploadFile("picture.webp", tinyWebP)
+
+	// Without a WebP encoder built in, WebP uploads are stored as PNG.
+	want := utils.EncodedImageFormat("image/webp")
+	if stored := f.recipes.pictures[picture.ID].ContentType; stored != want {
+		t.Errorf("WebP upload stored as %s, want %s", stored, want)
+	}
+	if recorder := f.get(picture.ID, nil); recorder.Code != http.StatusOK {
+		t.Errorf("GET = %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestGetRecipePictureWebPFallback(t *testing.T) {
+	f := newPictureFixture(t)
+	key := "webp-picture.webp"
+	if err := f.objects.Put(context.Background(), key, bytes.NewReader(tinyWebP), "image/webp"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.stored.objects[key] = &models.StoredObject{Key: key, ContentType: "image/webp", RefCount: 1}
+	pictureID := uuid.New()
+	f.recipes.pictures[pictureID.String()] = &models.RecipePicture{
+		ID:          pictureID,
+		RecipeId:    f.recipeID,
+		Path:        key,
+		ContentType: "image/webp",
+		Width:       1,
+		Height:      1,
+	}
+
+	recorder := f.get(pictureID.String(), http.Header{"Accept": {"image/webp,*/*"}})
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/webp" {
+		t.Fatalf("GET accepting WebP = %d %s, want 200 image/webp", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if !bytes.Equal(recorder.Body.Bytes(), tinyWebP) {
+		t.Error("GET accepting WebP did not send the stored picture")
+	}
+
+	recorder = f.get(pictureID.String(), http.Header{"Accept": {"image/*"}})
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("GET without WebP = %d %s, want 200 image/png", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if _, err := png.Decode(recorder.Body); err != nil {
+		t.Errorf("fallback is not a PNG: %v", err)
+	}
+
+	recorder = f.get(pictureID.String()+"?variant=thumbnail", nil)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("variant without WebP = %d %s, want 200 image/png", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestGetLegacyRecipePicture(t *testing.T) {
+	f := newPictureFixture(t)
+	uploaded := f.upload(color.RGBA{R: 30, G: 90, B: 150, A: 255})
+	object, err := f.objects.Get(context.Background(), uploaded.Path, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer object.Body.Close()
+
+	// Pictures from before content sniffing have no recorded format and were
+	// stored under whatever type the client sent.
+	key := "legacy-picture.png"
+	if err := f.objects.Put(context.Background(), key, object.Body, "application/octet-stream"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.stored.objects[key] = &models.StoredObject{Key: key, ContentType: "application/octet-stream", RefCount: 1}
+	pictureID := uuid.New()
+	f.recipes.pictures[pictureID.String()] = &models.RecipePicture{ID: pictureID, RecipeId: f.recipeID, Path: key}
+
+	recorder := f.get(pictureID.String(), nil)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("GET of a legacy picture = %d %s, want 200 image/png", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	recorder = f.get(pictureID.String()+"?variant=thumbnail", nil)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("thumbnail of a legacy picture = %d %s, want 200 image/png", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+}
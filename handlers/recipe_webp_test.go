@@ -0,0 +1,54 @@
+//go:build webp_encode
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"net/http"
+	"testing"
+
+	"app/services"
+
+	"golang.org/x/image/webp"
+)
+
+func TestGetRecipePictureConvertsToWebP(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.upload(color.RGBA{R: 120, G: 60, A: 255})
+
+	recorder := f.get(picture.ID, http.Header{"Accept": {"image/webp,image/*;q=0.8"}})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET accepting WebP = %d: %s", recorder.Code, recorder.Body)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "image/webp" {
+		t.Fatalf("Content-Type = %q, want image/webp", got)
+	}
+	if got := recorder.Header().Get("Vary"); got != "Accept" {
+		t.Errorf("Vary = %q, want Accept", got)
+	}
+	if _, err := webp.Decode(bytes.NewReader(recorder.Body.Bytes())); err != nil {
+		t.Errorf("converted picture is not a WebP: %v", err)
+	}
+	if !f.stores(services.VariantKey(picture.Path, services.FullPicture, "image/webp")) {
+		t.Error("the converted picture was not kept in the object store")
+	}
+
+	recorder = f.get(picture.ID, http.Header{"Accept": {"image/*"}})
+	if got := recorder.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("GET without WebP has Content-Type %q, want image/png", got)
+	}
+}
+
+func TestUploadWebPPictureKeepsWebP(t *testing.T) {
+	f := newPictureFixture(t)
+	picture := f.uploadFile("picture.webp", tinyWebP)
+
+	if stored := f.recipes.pictures[picture.ID].ContentType; stored != "image/webp" {
+		t.Errorf("WebP upload stored as %s, want image/webp", stored)
+	}
+	if _, err := f.objects.Head(context.Background(), picture.Path); err != nil {
+		t.Errorf("Head: %v", err)
+	}
+}
@@ -1,42 +1,191 @@
 package handlers
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"app/config"
 	"app/framework"
 	"app/repositories"
 	"app/services"
-)
+	"app/utils"
 
-func SetupRoutes(router *framework.Router) {
-	cfg, minioCfg, err := config.NewConfig()
-	if err != nil {
-		log.Fatal("Failed to load config:", err)
-	}
+	"github.com/nats-io/nats.go"
+)
 
+func SetupRoutes(router *framework.Router, cfg *config.Config, minioCfg *config.MinIO) {
 	db, err := config.NewDB(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	minioClient, err := minioCfg.GetClient()
-	if err != nil {
-		log.Fatal("Failed to initialize MinIO client:", err)
+	objectStore := newObjectStore(cfg.Storage, minioCfg)
+
+	userRepository := repositories.NewUserRepository(db)
+	recipeRepository := repositories.NewRecipeRepository(db)
+	jobRepository := repositories.NewJobRepository(db)
+	outboxRepository := repositories.NewOutboxRepository(db)
+	deadLetterRepository := repositories.NewDeadLetterRepository(db)
+	storedObjectRepository := repositories.NewStoredObjectRepository(db)
+
+	transactor := repositories.NewTransactor(db)
+	auditor := services.NewAuditor(repositories.NewAuditLogRepository(db))
+
+	userService := services.NewUserService(userRepository, transactor, auditor)
+	recipeService := services.NewRecipeService(recipeRepository, transactor, outboxRepository, jobRepository, auditor)
+	pictureService := services.NewPictureService(recipeRepository, storedObjectRepository, objectStore, transactor)
+	pictureUploadService := services.NewPictureUploadService(
+		recipeService,
+		recipeRepository,
+		repositories.NewPendingUploadRepository(db),
+		storedObjectRepository,
+		objectStore,
+		transactor,
+		services.PictureUploadSettings{
+			MaxBytes: cfg.Pictures.MaxBytes,
+			Limits: utils.ImageLimits{
+				MaxDimension: cfg.Pictures.MaxDimension,
+				MaxPixels:    cfg.Pictures.MaxPixels,
+			},
+			KeepOriginal: cfg.Pictures.KeepOriginal,
+			Expiry:       cfg.Pictures.UploadExpiry,
+		},
+	)
+	onboardingService := services.NewOnboardingService(
+		userRepository,
+		recipeRepository,
+		repositories.NewCollectionRepository(db),
+		repositories.NewFeedRepository(db),
+		jobRepository,
+		services.NewLogMailer(),
+	)
+	hub := framework.GetHub()
+	if cfg.RealtimeBroker == "postgres" {
+		go hub.UseBroker(context.Background(), repositories.NewPostgresBroker(db, cfg.DatabaseURL))
 	}
 
-	userService := services.NewUserService(repositories.NewUserRepository(db))
-	recipeService := services.NewRecipeService(repositories.NewRecipeRepository(db))
-	recipePictureUploadHandler := NewUploadRecipePictureHandler(recipeService, minioClient, minioCfg.Bucket)
-	recipePictureGetHandler := NewGetRecipePictureHandler(recipeService, minioClient, minioCfg.Bucket)
+	notificationService := services.NewNotificationService(repositories.NewNotificationRepository(db), recipeRepository, hub)
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(db), jobRepository, deadLetterRepository)
+	deadLetterService := services.NewDeadLetterService(deadLetterRepository)
+	recipePictureUploadHandler := NewUploadRecipePictureHandler(pictureUploadService, userService, cfg.Pictures.MaxBytes)
+	recipePictureGetHandler := NewGetRecipePictureHandler(recipeService, pictureService, objectStore)
+	recipePictureDeleteHandler := NewDeleteRecipePictureHandler(recipeService, pictureService, userService)
 	healthCheckHandler := &HealthCheckHandler{}
+	// Resolvers trust the session headers, so only Hasura may call them.
+	graphqlHandler := framework.RequireSecret("X-Webhook-Secret", cfg.WebhookSecret)(NewGraphQLHandler(recipeService))
+	realtimeHandler := NewRealtimeHandler(hub, userService)
+	metricsHandler := NewMetricsHandler(deadLetterService)
+
+	workerPool := framework.GetWorkerPool(jobRepository, cfg.WorkerCount, cfg.JobPollInterval)
+	RegisterOnboardingJobs(workerPool, onboardingService)
+	RegisterWebhookHandlers(workerPool, webhookService)
+	RegisterPictureJobs(workerPool, pictureService)
+	workerPool.Start(context.Background())
+
+	outboxRelay := framework.GetOutboxRelay(outboxRepository, cfg.Outbox.PollInterval, cfg.Outbox.VisibilityDelay)
+	for _, sink := range cfg.Outbox.Sinks {
+		switch sink {
+		case "log":
+			outboxRelay.AddSink(framework.LogSink{})
+		case "webhook":
+			outboxRelay.AddSink(framework.NewWebhookSink(cfg.Outbox.WebhookURL, cfg.Outbox.WebhookSecret))
+		case "nats":
+			conn, err := nats.Connect(cfg.Outbox.NATSURL, nats.Name("recipe-app-outbox"), nats.MaxReconnects(-1))
+			if err != nil {
+				log.Fatal("Failed to connect to NATS:", err)
+			}
+			outboxRelay.AddSink(framework.NewNATSSink(conn, cfg.Outbox.NATSSubject))
+		}
+	}
+	RegisterRealtimeHandlers(hub, outboxRelay)
+	outboxRelay.Start(context.Background())
+
+	eventLedger := repositories.NewEventLedgerRepository(db)
+	framework.GetEventDispatcher().SetLedger(eventLedger)
+	framework.GetEventDispatcher().SetDeadLetters(deadLetterRepository)
+
+	maintenanceService := services.NewMaintenanceService(
+		repositories.NewMaintenanceRepository(db),
+		storedObjectRepository,
+		objectStore,
+		transactor,
+		eventLedger,
+		services.MaintenanceSettings{
+			SoftDeleteRetention:  cfg.Maintenance.SoftDeleteRetention,
+			OrphanObjectGrace:    cfg.Maintenance.OrphanObjectGrace,
+			TrendingWindow:       cfg.Maintenance.TrendingWindow,
+			EventLedgerRetention: cfg.EventLedgerRetention,
+		},
+	)
+	cronRegistry := framework.GetCronRegistry()
+	cronRegistry.SetStore(repositories.NewCronRunRepository(db))
+	RegisterCronJobs(cronRegistry, maintenanceService, pictureUploadService)
+	if cfg.WebhookSecret == "" {
+		log.Println("WEBHOOK_SECRET is not set, /cron and /graphql will reject every request")
+	}
+	cronHandler := framework.RequireSecret("X-Webhook-Secret", cfg.WebhookSecret)(http.HandlerFunc(cronRegistry.Handle))
+
+	// Scrapers send METRICS_SECRET as a bearer token. Without it /metrics,
+	// like /cron, rejects every request.
+	metricsToken := ""
+	if cfg.MetricsSecret != "" {
+		metricsToken = "Bearer " + cfg.MetricsSecret
+	} else {
+		log.Println("METRICS_SECRET is not set, /metrics will reject every request")
+	}
+	guardedMetricsHandler := framework.RequireSecret("Authorization", metricsToken)(http.HandlerFunc(metricsHandler.Handle))
 
 	RegisterSignUpHandler(userService)
 	RegisterSignInHandler(userService)
+	RegisterUserHandlers(userService)
+	RegisterRecipeHandlers(recipeService, pictureService)
+	RegisterPictureUploadHandlers(pictureUploadService)
+	RegisterAuditHandlers(auditor)
+	RegisterDeadLetterHandlers(deadLetterService, webhookService)
+	RegisterUserEventHandlers(onboardingService)
+	RegisterNotificationHandlers(notificationService)
+
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.SetTimeouts(cfg.Server.ActionTimeout, cfg.Server.ActionTimeouts)
+	dispatcher.SetSessionVerifier(verifySession(userService))
+	router.SetTimeouts(cfg.Server.RequestTimeout, cfg.Server.RouteTimeouts)
+	router.SetTrustedProxies(cfg.Server.TrustedProxies)
 
-	router.AddPostHandler("/actions", framework.GetActionDispatcher(&DefaultHandler{}).Handle)
-	router.AddPostHandler("/events", HandleEvents)
+	router.AddActionHandler("/actions", dispatcher)
+	router.AddPostHandler("/events", framework.GetEventDispatcher().Handle)
+	router.AddPostHandler("/cron", cronHandler.ServeHTTP)
 	router.AddPostHandler("/api/recipe/picture", recipePictureUploadHandler.Handle)
 	router.AddGetHandler("/api/recipe/picture/{id}", recipePictureGetHandler.Handle)
+	router.AddHeadHandler("/api/recipe/picture/{id}", recipePictureGetHandler.Handle)
+	router.AddDeleteHandler("/api/recipe/picture/{id}", recipePictureDeleteHandler.Handle)
+	router.AddPostHandler("/graphql", graphqlHandler.ServeHTTP)
+	router.AddGetHandler("/graphql", graphqlHandler.ServeHTTP)
+	router.AddStreamHandler("/ws", realtimeHandler.HandleWebSocket)
+	router.AddStreamHandler("/events/stream", realtimeHandler.HandleEvents)
+	router.AddGetHandler("/metrics", guardedMetricsHandler.ServeHTTP)
 	router.AddGetHandler("/health_check", healthCheckHandler.Handle)
 }
+
+func newObjectStore(storage config.Storage, minioCfg *config.MinIO) repositories.ObjectStore {
+	switch storage.Backend {
+	case "local":
+		objectStore, err := repositories.NewLocalObjectStore(storage.LocalDir)
+		if err != nil {
+			log.Fatal("Failed to initialize local object store:", err)
+		}
+		return objectStore
+	case "memory":
+		return repositories.NewMemoryObjectStore()
+	default:
+		minioClient, err := minioCfg.GetClient()
+		if err != nil {
+			log.Fatal("Failed to initialize MinIO client:", err)
+		}
+		minioPresignClient, err := minioCfg.GetPresignClient()
+		if err != nil {
+			log.Fatal("Failed to initialize MinIO presign client:", err)
+		}
+		return repositories.NewS3ObjectStore(minioClient, minioPresignClient, minioCfg.Bucket)
+	}
+}
@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 
 	"app/framework"
 	"app/services"
@@ -41,16 +41,21 @@ func (h *SignInHandler) Handle(w http.ResponseWriter, r *http.Request, action fr
 	}
 
 	input := wrapper.Arg1
-	if input.Username == "" || input.Password == "" {
-		utils.WriteError(w, http.StatusBadRequest, "MISSING_REQUIRED_FIELDS", "Username and password are required")
+	var missing []utils.FieldError
+	if input.Username == "" {
+		missing = append(missing, utils.FieldError{Field: "username", Message: "is required"})
+	}
+	if input.Password == "" {
+		missing = append(missing, utils.FieldError{Field: "password", Message: "is required"})
+	}
+	if len(missing) > 0 {
+		utils.WriteValidationError(w, "MISSING_REQUIRED_FIELDS", "Username and password are required", missing)
 		return
 	}
 
-	token, user, err := h.userService.SignIn(input.Username, input.Password)
+	token, user, err := h.userService.SignIn(r.Context(), input.Username, input.Password)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "no user") {
-			utils.WriteError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid username or password")
-		} else if strings.Contains(err.Error(), "password") {
+		if errors.Is(err, services.ErrInvalidCredentials) {
 			utils.WriteError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid username or password")
 		} else {
 			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to sign in: "+err.Error())
@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 
 	"app/framework"
 	"app/services"
@@ -40,23 +40,38 @@ func (h *SignUpHandler) Handle(w http.ResponseWriter, r *http.Request, action fr
 	}
 
 	input := wrapper.Arg1
-	if input.Username == "" || input.Password == "" || input.Name == "" {
-		utils.WriteError(w, http.StatusBadRequest, "MISSING_REQUIRED_FIELDS", "Username, password, and name are required")
+	var missing []utils.FieldError
+	if input.Username == "" {
+		missing = append(missing, utils.FieldError{Field: "username", Message: "is required"})
+	}
+	if input.Password == "" {
+		missing = append(missing, utils.FieldError{Field: "password", Message: "is required"})
+	}
+	if input.Name == "" {
+		missing = append(missing, utils.FieldError{Field: "name", Message: "is required"})
+	}
+	if len(missing) > 0 {
+		utils.WriteValidationError(w, "MISSING_REQUIRED_FIELDS", "Username, password, and name are required", missing)
 		return
 	}
 
 	// Basic password validation
 	if len(input.Password) < 8 {
-		utils.WriteError(w, http.StatusBadRequest, "INVALID_PASSWORD", "Password must be at least 8 characters long")
+		utils.WriteValidationError(w, "INVALID_PASSWORD", "Password must be at least 8 characters long", []utils.FieldError{
+			{Field: "password", Message: "must be at least 8 characters long"},
+		})
 		return
 	}
 
-	user, err := h.userService.SignUp(input.Username, input.Password, input.Name, input.Bio)
+	user, err := h.userService.SignUp(r.Context(), input.Username, input.Password, input.Name, input.Bio)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "username") {
+		var validationErr *services.ValidationError
+		if errors.Is(err, services.ErrConflict) {
 			utils.WriteError(w, http.StatusBadRequest, "USERNAME_TAKEN", "Username is already taken")
-		} else if strings.Contains(err.Error(), "password") {
-			utils.WriteError(w, http.StatusBadRequest, "INVALID_PASSWORD", "Invalid password format")
+		} else if errors.As(err, &validationErr) && validationErr.Field == "password" {
+			utils.WriteValidationError(w, "INVALID_PASSWORD", "Invalid password format", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
 		} else {
 			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to sign up: "+err.Error())
 		}
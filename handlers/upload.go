@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"app/framework"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type RequestPictureUploadInput struct {
+	RecipeID    string `json:"recipe_id"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type RequestPictureUploadInputWrapper struct {
+	Arg1 RequestPictureUploadInput `json:"arg1"`
+}
+
+type RequestPictureUploadResponse struct {
+	ID        uuid.UUID         `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type RequestPictureUploadHandler struct {
+	uploadService services.PictureUploadService
+}
+
+func (h *RequestPictureUploadHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper RequestPictureUploadInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	input := wrapper.Arg1
+
+	recipeID, err := uuid.Parse(input.RecipeID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid recipe id", []utils.FieldError{
+			{Field: "recipe_id", Message: err.Error()},
+		})
+		return
+	}
+
+	request, err := h.uploadService.RequestUpload(r.Context(), userID, recipeID, input.ContentType, input.Size)
+	if err != nil {
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			utils.WriteValidationError(w, "INVALID_UPLOAD", "Invalid picture upload", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+			return
+		}
+		status, code, message := pictureUploadError(err)
+		utils.WriteError(w, status, code, message)
+		return
+	}
+
+	utils.EncodeJSON(w, RequestPictureUploadResponse{
+		ID:        request.ID,
+		Method:    request.Method,
+		URL:       request.URL,
+		Headers:   request.Headers,
+		ExpiresAt: request.ExpiresAt,
+	})
+}
+
+type ConfirmPictureUploadInput struct {
+	ID string `json:"id"`
+}
+
+type ConfirmPictureUploadInputWrapper struct {
+	Arg1 ConfirmPictureUploadInput `json:"arg1"`
+}
+
+type ConfirmPictureUploadHandler struct {
+	uploadService services.PictureUploadService
+}
+
+func (h *ConfirmPictureUploadHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper ConfirmPictureUploadInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+
+	uploadID, err := uuid.Parse(wrapper.Arg1.ID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid upload id", []utils.FieldError{
+			{Field: "id", Message: err.Error()},
+		})
+		return
+	}
+
+	picture, err := h.uploadService.ConfirmUpload(r.Context(), userID, uploadID)
+	if err != nil {
+		var validationErr *services.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.WriteValidationError(w, "INVALID_UPLOAD", "Invalid picture upload", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		case errors.Is(err, services.ErrUploadExpired):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Upload not found or expired")
+		default:
+			status, code, message := pictureUploadError(err)
+			utils.WriteError(w, status, code, message)
+		}
+		return
+	}
+
+	utils.EncodeJSON(w, newUploadRecipePictureResponse(picture))
+}
+
+func RegisterPictureUploadHandlers(uploadService services.PictureUploadService) {
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("requestPictureUpload", &RequestPictureUploadHandler{uploadService: uploadService})
+	dispatcher.RegisterHandler("confirmPictureUpload", &ConfirmPictureUploadHandler{uploadService: uploadService})
+}
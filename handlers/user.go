@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"app/framework"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangePasswordInputWrapper struct {
+	Arg1 ChangePasswordInput `json:"arg1"`
+}
+
+type ChangePasswordResponse struct {
+	Success bool `json:"success"`
+}
+
+type ChangePasswordHandler struct {
+	userService services.UserService
+}
+
+func (h *ChangePasswordHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	userID, err := action.UserID()
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return
+	}
+
+	var wrapper ChangePasswordInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+
+	input := wrapper.Arg1
+	var missing []utils.FieldError
+	if input.CurrentPassword == "" {
+		missing = append(missing, utils.FieldError{Field: "current_password", Message: "is required"})
+	}
+	if input.NewPassword == "" {
+		missing = append(missing, utils.FieldError{Field: "new_password", Message: "is required"})
+	}
+	if len(missing) > 0 {
+		utils.WriteValidationError(w, "MISSING_REQUIRED_FIELDS", "Current and new passwords are required", missing)
+		return
+	}
+	if len(input.NewPassword) < 8 {
+		utils.WriteValidationError(w, "INVALID_PASSWORD", "Password must be at least 8 characters long", []utils.FieldError{
+			{Field: "new_password", Message: "must be at least 8 characters long"},
+		})
+		return
+	}
+
+	err = h.userService.ChangePassword(r.Context(), userID, input.CurrentPassword, input.NewPassword)
+	if err != nil {
+		var validationErr *services.ValidationError
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			utils.WriteError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Current password is incorrect")
+		case errors.As(err, &validationErr):
+			utils.WriteValidationError(w, "INVALID_PASSWORD", "Invalid password format", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to change password: "+err.Error())
+		}
+		return
+	}
+	utils.EncodeJSON(w, ChangePasswordResponse{Success: true})
+}
+
+type SetUserRoleInput struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type SetUserRoleInputWrapper struct {
+	Arg1 SetUserRoleInput `json:"arg1"`
+}
+
+type SetUserRoleResponse struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+type SetUserRoleHandler struct {
+	userService services.UserService
+}
+
+func (h *SetUserRoleHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller := services.Caller{Admin: action.Role() == "admin"}
+	if userID, err := action.UserID(); err == nil {
+		caller.UserID = userID
+	}
+
+	var wrapper SetUserRoleInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	userID, err := uuid.Parse(wrapper.Arg1.UserID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid user id", []utils.FieldError{
+			{Field: "user_id", Message: err.Error()},
+		})
+		return
+	}
+
+	user, err := h.userService.SetRole(r.Context(), caller, userID, wrapper.Arg1.Role)
+	if err != nil {
+		var validationErr *services.ValidationError
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			utils.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Only admins can change roles")
+		case errors.As(err, &validationErr):
+			utils.WriteValidationError(w, "INVALID_ROLE", "Invalid role", []utils.FieldError{
+				{Field: validationErr.Field, Message: validationErr.Message},
+			})
+		case errors.Is(err, services.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "Failed to change role: "+err.Error())
+		}
+		return
+	}
+	utils.EncodeJSON(w, SetUserRoleResponse{ID: user.ID.String(), Role: user.Role})
+}
+
+func RegisterUserHandlers(userService services.UserService) {
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("changePassword", &ChangePasswordHandler{userService: userService})
+	dispatcher.RegisterHandler("setUserRole", &SetUserRoleHandler{userService: userService})
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"app/framework"
+	"app/models"
+	"app/services"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+// webhookCaller identifies the session behind a webhook action. Requests made
+// with the admin role act on every endpoint and need no user id.
+func webhookCaller(w http.ResponseWriter, action framework.HasuraAction) (services.Caller, bool) {
+	caller := services.Caller{Admin: action.Role() == "admin"}
+	userID, err := action.UserID()
+	if err != nil && !caller.Admin {
+		utils.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A signed in user is required")
+		return caller, false
+	}
+	if err == nil {
+		caller.UserID = userID
+	}
+	return caller, true
+}
+
+func writeWebhookError(w http.ResponseWriter, err error, message string) {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.WriteValidationError(w, "INVALID_INPUT", message, []utils.FieldError{
+			{Field: validationErr.Field, Message: validationErr.Message},
+		})
+	case errors.Is(err, services.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, "NOT_FOUND", "Webhook endpoint or delivery not found")
+	case errors.Is(err, services.ErrConflict):
+		utils.WriteError(w, http.StatusConflict, "DELIVERY_NOT_REPLAYABLE", err.Error())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", message+": "+err.Error())
+	}
+}
+
+type WebhookEndpointOutput struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newWebhookEndpointOutput(endpoint models.WebhookEndpoint) WebhookEndpointOutput {
+	return WebhookEndpointOutput{
+		ID:         endpoint.ID.String(),
+		URL:        endpoint.URL,
+		EventTypes: endpoint.EventTypes,
+		Active:     endpoint.Active,
+		CreatedAt:  endpoint.CreatedAt,
+	}
+}
+
+type CreateWebhookEndpointInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type CreateWebhookEndpointInputWrapper struct {
+	Arg1 CreateWebhookEndpointInput `json:"arg1"`
+}
+
+type CreateWebhookEndpointHandler struct {
+	webhookService services.WebhookService
+}
+
+func (h *CreateWebhookEndpointHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller, ok := webhookCaller(w, action)
+	if !ok {
+		return
+	}
+
+	var wrapper CreateWebhookEndpointInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	if wrapper.Arg1.URL == "" {
+		utils.WriteValidationError(w, "MISSING_REQUIRED_FIELDS", "Missing required fields", []utils.FieldError{
+			{Field: "url", Message: "is required"},
+		})
+		return
+	}
+
+	endpoint, err := h.webhookService.CreateEndpoint(r.Context(), caller, wrapper.Arg1.URL, wrapper.Arg1.EventTypes)
+	if err != nil {
+		writeWebhookError(w, err, "Failed to create webhook endpoint")
+		return
+	}
+
+	// The secret is only ever returned here, when the endpoint is created.
+	output := newWebhookEndpointOutput(*endpoint)
+	output.Secret = endpoint.Secret
+	utils.EncodeJSON(w, output)
+}
+
+type ListWebhookEndpointsHandler struct {
+	webhookService services.WebhookService
+}
+
+func (h *ListWebhookEndpointsHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller, ok := webhookCaller(w, action)
+	if !ok {
+		return
+	}
+
+	endpoints, err := h.webhookService.ListEndpoints(r.Context(), caller)
+	if err != nil {
+		writeWebhookError(w, err, "Failed to list webhook endpoints")
+		return
+	}
+
+	response := make([]WebhookEndpointOutput, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, newWebhookEndpointOutput(endpoint))
+	}
+	utils.EncodeJSON(w, response)
+}
+
+type DeleteWebhookEndpointInput struct {
+	ID string `json:"id"`
+}
+
+type DeleteWebhookEndpointInputWrapper struct {
+	Arg1 DeleteWebhookEndpointInput `json:"arg1"`
+}
+
+type DeleteWebhookEndpointResponse struct {
+	ID string `json:"id"`
+}
+
+type DeleteWebhookEndpointHandler struct {
+	webhookService services.WebhookService
+}
+
+func (h *DeleteWebhookEndpointHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller, ok := webhookCaller(w, action)
+	if !ok {
+		return
+	}
+
+	var wrapper DeleteWebhookEndpointInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	id, err := uuid.Parse(wrapper.Arg1.ID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid webhook endpoint id", []utils.FieldError{
+			{Field: "id", Message: err.Error()},
+		})
+		return
+	}
+
+	if err := h.webhookService.DeleteEndpoint(r.Context(), caller, id); err != nil {
+		writeWebhookError(w, err, "Failed to delete webhook endpoint")
+		return
+	}
+	utils.EncodeJSON(w, DeleteWebhookEndpointResponse{ID: id.String()})
+}
+
+type WebhookDeliveryOutput struct {
+	ID             string     `json:"id"`
+	EndpointID     string     `json:"endpoint_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newWebhookDeliveryOutput(delivery models.WebhookDelivery) WebhookDeliveryOutput {
+	return WebhookDeliveryOutput{
+		ID:             delivery.ID.String(),
+		EndpointID:     delivery.EndpointID.String(),
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+type ListWebhookDeliveriesInput struct {
+	EndpointID string `json:"endpoint_id"`
+	Status     string `json:"status"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+type ListWebhookDeliveriesInputWrapper struct {
+	Arg1 ListWebhookDeliveriesInput `json:"arg1"`
+}
+
+type ListWebhookDeliveriesHandler struct {
+	webhookService services.WebhookService
+}
+
+func (h *ListWebhookDeliveriesHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller, ok := webhookCaller(w, action)
+	if !ok {
+		return
+	}
+
+	var wrapper ListWebhookDeliveriesInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	input := wrapper.Arg1
+	endpointID, err := uuid.Parse(input.EndpointID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid webhook endpoint id", []utils.FieldError{
+			{Field: "endpoint_id", Message: err.Error()},
+		})
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), caller, endpointID, input.Status, input.Limit, input.Offset)
+	if err != nil {
+		writeWebhookError(w, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	response := make([]WebhookDeliveryOutput, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, newWebhookDeliveryOutput(delivery))
+	}
+	utils.EncodeJSON(w, response)
+}
+
+type ReplayWebhookDeliveryInput struct {
+	ID string `json:"id"`
+}
+
+type ReplayWebhookDeliveryInputWrapper struct {
+	Arg1 ReplayWebhookDeliveryInput `json:"arg1"`
+}
+
+type ReplayWebhookDeliveryHandler struct {
+	webhookService services.WebhookService
+}
+
+func (h *ReplayWebhookDeliveryHandler) Handle(w http.ResponseWriter, r *http.Request, action framework.HasuraAction) {
+	caller, ok := webhookCaller(w, action)
+	if !ok {
+		return
+	}
+
+	var wrapper ReplayWebhookDeliveryInputWrapper
+	if err := json.Unmarshal(action.Input, &wrapper); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "INVALID_INPUT", "Invalid input format: "+err.Error())
+		return
+	}
+	id, err := uuid.Parse(wrapper.Arg1.ID)
+	if err != nil {
+		utils.WriteValidationError(w, "INVALID_ID", "Invalid webhook delivery id", []utils.FieldError{
+			{Field: "id", Message: err.Error()},
+		})
+		return
+	}
+
+	delivery, err := h.webhookService.Replay(r.Context(), caller, id)
+	if err != nil {
+		writeWebhookError(w, err, "Failed to replay webhook delivery")
+		return
+	}
+	utils.EncodeJSON(w, newWebhookDeliveryOutput(*delivery))
+}
+
+// WebhookRecipe is the data of recipe webhook events.
+type WebhookRecipe struct {
+	ID              string     `json:"id"`
+	Title           string     `json:"title"`
+	CategoryID      string     `json:"category_id"`
+	CreatorID       string     `json:"creator_id"`
+	PreparationTime int64      `json:"preparation_time"`
+	ThumbnailID     *uuid.UUID `json:"thumbnail_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type WebhookEventHandler struct {
+	webhookService services.WebhookService
+}
+
+// HandleRecipeChanged maps recipe row changes to webhook event types. Recipes
+// are soft deleted, so setting deleted_at counts as a deletion.
+func (h *WebhookEventHandler) HandleRecipeChanged(ctx context.Context, change framework.Change[models.Recipe]) error {
+	recipe := change.New
+	if change.Op == framework.OpDelete {
+		recipe = change.Old
+	}
+	if recipe == nil {
+		return fmt.Errorf("recipe %s event without row data", change.Op)
+	}
+
+	var eventType string
+	switch change.Op {
+	case framework.OpInsert:
+		eventType = models.WebhookEventRecipePublished
+	case framework.OpUpdate:
+		wasDeleted := change.Old != nil && change.Old.DeletedAt.Valid
+		switch {
+		case recipe.DeletedAt.Valid && !wasDeleted:
+			eventType = models.WebhookEventRecipeDeleted
+		case !recipe.DeletedAt.Valid:
+			eventType = models.WebhookEventRecipeUpdated
+		default:
+			return nil
+		}
+	case framework.OpDelete:
+		// Purging a soft deleted recipe was already announced as a deletion.
+		if recipe.DeletedAt.Valid {
+			return nil
+		}
+		eventType = models.WebhookEventRecipeDeleted
+	default:
+		return nil
+	}
+
+	return h.webhookService.Publish(ctx, change.Event.ID, eventType, WebhookRecipe{
+		ID:              recipe.ID.String(),
+		Title:           recipe.Title,
+		CategoryID:      recipe.CategoryID.String(),
+		CreatorID:       recipe.CreatorID.String(),
+		PreparationTime: recipe.PreparationTime,
+		ThumbnailID:     recipe.ThumbnailID,
+		CreatedAt:       recipe.CreatedAt,
+		UpdatedAt:       recipe.UpdatedAt,
+	})
+}
+
+func RegisterWebhookHandlers(pool *framework.WorkerPool, webhookService services.WebhookService) {
+	dispatcher := framework.GetActionDispatcher(&DefaultHandler{})
+	dispatcher.RegisterHandler("createWebhookEndpoint", &CreateWebhookEndpointHandler{webhookService: webhookService})
+	dispatcher.RegisterHandler("listWebhookEndpoints", &ListWebhookEndpointsHandler{webhookService: webhookService})
+	dispatcher.RegisterHandler("deleteWebhookEndpoint", &DeleteWebhookEndpointHandler{webhookService: webhookService})
+	dispatcher.RegisterHandler("listWebhookDeliveries", &ListWebhookDeliveriesHandler{webhookService: webhookService})
+	dispatcher.RegisterHandler("replayWebhookDelivery", &ReplayWebhookDeliveryHandler{webhookService: webhookService})
+
+	events := &WebhookEventHandler{webhookService: webhookService}
+	framework.RegisterEventHandler(framework.GetEventDispatcher(), "recipe_webhooks", models.Recipe{}.TableName(), events.HandleRecipeChanged)
+
+	pool.Register(services.JobWebhookDelivery, framework.JobProcessorFunc(func(ctx context.Context, job *models.Job) (any, error) {
+		var input services.WebhookDeliveryInput
+		if err := json.Unmarshal(job.Input, &input); err != nil {
+			return nil, fmt.Errorf("invalid webhook delivery input: %w", err)
+		}
+		if err := webhookService.Deliver(ctx, input.DeliveryID, job.Attempts >= job.MaxAttempts); err != nil {
+			return nil, err
+		}
+		return input, nil
+	}))
+}
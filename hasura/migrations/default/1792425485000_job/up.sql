@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS "job" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "kind" varchar(100) NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'pending',
+  "user_id" uuid,
+  "input" jsonb NOT NULL DEFAULT '{}',
+  "session" jsonb,
+  "result" jsonb,
+  "error" text,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "max_attempts" integer NOT NULL DEFAULT 3,
+  "run_after" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "locked_at" timestamp with time zone,
+  "finished_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_job_status" CHECK ("status" IN ('pending', 'running', 'succeeded', 'failed'))
+);
+CREATE INDEX IF NOT EXISTS "job_index_status_run_after" ON "job" ("status", "run_after");
+CREATE INDEX IF NOT EXISTS "job_index_user_id" ON "job" ("user_id");
+DROP TRIGGER IF EXISTS update_job_timestamp ON "job";
+CREATE TRIGGER update_job_timestamp
+  BEFORE UPDATE ON "job"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+ALTER TABLE "job"
+  DROP CONSTRAINT IF EXISTS "fk_job_user_id",
+  ADD CONSTRAINT "fk_job_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
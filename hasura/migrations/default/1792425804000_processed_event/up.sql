@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "processed_event" (
+  "event_id" uuid NOT NULL,
+  "trigger_name" varchar(255) NOT NULL,
+  "processed_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("event_id")
+);
+CREATE INDEX IF NOT EXISTS "processed_event_index_processed_at" ON "processed_event" ("processed_at");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "feed_item";
+DROP TABLE IF EXISTS "collection_recipe";
+DROP TABLE IF EXISTS "collection";
@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS "collection" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "user_id" uuid NOT NULL,
+  "name" varchar(100) NOT NULL,
+  "is_default" boolean NOT NULL DEFAULT false,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "collection_index_user_id_name" ON "collection" ("user_id", "name");
+DROP TRIGGER IF EXISTS update_collection_timestamp ON "collection";
+CREATE TRIGGER update_collection_timestamp
+  BEFORE UPDATE ON "collection"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+CREATE TABLE IF NOT EXISTS "collection_recipe" (
+  "collection_id" uuid NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("collection_id", "recipe_id")
+);
+CREATE INDEX IF NOT EXISTS "collection_recipe_index_recipe_id" ON "collection_recipe" ("recipe_id");
+
+CREATE TABLE IF NOT EXISTS "feed_item" (
+  "user_id" uuid NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "reason" varchar(50) NOT NULL,
+  "score" double precision NOT NULL DEFAULT 0,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("user_id", "recipe_id")
+);
+CREATE INDEX IF NOT EXISTS "feed_item_index_user_id_score" ON "feed_item" ("user_id", "score" DESC);
+
+ALTER TABLE "collection"
+  DROP CONSTRAINT IF EXISTS "fk_collection_user_id",
+  ADD CONSTRAINT "fk_collection_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "collection_recipe"
+  DROP CONSTRAINT IF EXISTS "fk_collection_recipe_collection_id",
+  ADD CONSTRAINT "fk_collection_recipe_collection_id"
+  FOREIGN KEY ("collection_id") REFERENCES "collection" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "collection_recipe"
+  DROP CONSTRAINT IF EXISTS "fk_collection_recipe_recipe_id",
+  ADD CONSTRAINT "fk_collection_recipe_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "feed_item"
+  DROP CONSTRAINT IF EXISTS "fk_feed_item_user_id",
+  ADD CONSTRAINT "fk_feed_item_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "feed_item"
+  DROP CONSTRAINT IF EXISTS "fk_feed_item_recipe_id",
+  ADD CONSTRAINT "fk_feed_item_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
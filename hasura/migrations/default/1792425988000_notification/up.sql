@@ -0,0 +1,71 @@
+CREATE TABLE IF NOT EXISTS "notification" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "user_id" uuid NOT NULL,
+  "type" varchar(20) NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "last_actor_id" uuid NOT NULL,
+  "actor_count" integer NOT NULL DEFAULT 1,
+  "preview" text,
+  "read_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "notification_index_unread_aggregate" ON "notification" ("user_id", "type", "recipe_id") WHERE "read_at" IS NULL;
+CREATE INDEX IF NOT EXISTS "notification_index_user_id_updated_at" ON "notification" ("user_id", "updated_at" DESC);
+DROP TRIGGER IF EXISTS update_notification_timestamp ON "notification";
+CREATE TRIGGER update_notification_timestamp
+  BEFORE UPDATE ON "notification"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+CREATE TABLE IF NOT EXISTS "notification_actor" (
+  "notification_id" uuid NOT NULL,
+  "actor_id" uuid NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("notification_id", "actor_id")
+);
+
+CREATE TABLE IF NOT EXISTS "notification_preference" (
+  "user_id" uuid NOT NULL,
+  "type" varchar(20) NOT NULL,
+  "enabled" boolean NOT NULL DEFAULT true,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("user_id", "type")
+);
+
+ALTER TABLE "notification"
+  DROP CONSTRAINT IF EXISTS "fk_notification_user_id",
+  ADD CONSTRAINT "fk_notification_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification"
+  DROP CONSTRAINT IF EXISTS "fk_notification_recipe_id",
+  ADD CONSTRAINT "fk_notification_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification"
+  DROP CONSTRAINT IF EXISTS "fk_notification_last_actor_id",
+  ADD CONSTRAINT "fk_notification_last_actor_id"
+  FOREIGN KEY ("last_actor_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification_actor"
+  DROP CONSTRAINT IF EXISTS "fk_notification_actor_notification_id",
+  ADD CONSTRAINT "fk_notification_actor_notification_id"
+  FOREIGN KEY ("notification_id") REFERENCES "notification" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification_actor"
+  DROP CONSTRAINT IF EXISTS "fk_notification_actor_actor_id",
+  ADD CONSTRAINT "fk_notification_actor_actor_id"
+  FOREIGN KEY ("actor_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification_preference"
+  DROP CONSTRAINT IF EXISTS "fk_notification_preference_user_id",
+  ADD CONSTRAINT "fk_notification_preference_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS "webhook_endpoint" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "owner_id" uuid,
+  "url" text NOT NULL,
+  "secret" varchar(100) NOT NULL,
+  "event_types" jsonb NOT NULL DEFAULT '[]',
+  "active" boolean NOT NULL DEFAULT true,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "webhook_endpoint_index_owner_id" ON "webhook_endpoint" ("owner_id");
+CREATE INDEX IF NOT EXISTS "webhook_endpoint_index_event_types" ON "webhook_endpoint" USING GIN ("event_types");
+DROP TRIGGER IF EXISTS update_webhook_endpoint_timestamp ON "webhook_endpoint";
+CREATE TRIGGER update_webhook_endpoint_timestamp
+  BEFORE UPDATE ON "webhook_endpoint"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+CREATE TABLE IF NOT EXISTS "webhook_delivery" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "endpoint_id" uuid NOT NULL,
+  "event_id" varchar(64) NOT NULL,
+  "event_type" varchar(50) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_status_code" integer,
+  "last_error" text,
+  "delivered_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_webhook_delivery_status" CHECK ("status" IN ('pending', 'succeeded', 'failed', 'dead'))
+);
+CREATE INDEX IF NOT EXISTS "webhook_delivery_index_endpoint_id_created_at" ON "webhook_delivery" ("endpoint_id", "created_at" DESC);
+DROP TRIGGER IF EXISTS update_webhook_delivery_timestamp ON "webhook_delivery";
+CREATE TRIGGER update_webhook_delivery_timestamp
+  BEFORE UPDATE ON "webhook_delivery"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+ALTER TABLE "webhook_endpoint"
+  DROP CONSTRAINT IF EXISTS "fk_webhook_endpoint_owner_id",
+  ADD CONSTRAINT "fk_webhook_endpoint_owner_id"
+  FOREIGN KEY ("owner_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "webhook_delivery"
+  DROP CONSTRAINT IF EXISTS "fk_webhook_delivery_endpoint_id",
+  ADD CONSTRAINT "fk_webhook_delivery_endpoint_id"
+  FOREIGN KEY ("endpoint_id") REFERENCES "webhook_endpoint" ("id")
+    ON DELETE CASCADE;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "outbox_event" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "sequence" bigserial NOT NULL,
+  "aggregate_type" varchar(50) NOT NULL,
+  "aggregate_id" uuid NOT NULL,
+  "event_type" varchar(100) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_error" text,
+  "next_attempt_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "published_at" timestamp with time zone,
+  "claimed_until" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE ("sequence")
+);
+CREATE INDEX IF NOT EXISTS "outbox_event_index_unpublished" ON "outbox_event" ("sequence") WHERE "published_at" IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "recipe_trend";
+DROP TABLE IF EXISTS "cron_run";
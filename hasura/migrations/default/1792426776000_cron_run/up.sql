@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS "cron_run" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "name" varchar(100) NOT NULL,
+  "cron_event_id" varchar(64),
+  "scheduled_time" timestamp with time zone,
+  "status" varchar(20) NOT NULL DEFAULT 'running',
+  "result" jsonb,
+  "error" text,
+  "duration_ms" bigint,
+  "started_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "finished_at" timestamp with time zone,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_cron_run_status" CHECK ("status" IN ('running', 'succeeded', 'failed'))
+);
+CREATE INDEX IF NOT EXISTS "cron_run_index_name_started_at" ON "cron_run" ("name", "started_at" DESC);
+
+CREATE TABLE IF NOT EXISTS "recipe_trend" (
+  "recipe_id" uuid NOT NULL,
+  "score" double precision NOT NULL DEFAULT 0,
+  "computed_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("recipe_id")
+);
+CREATE INDEX IF NOT EXISTS "recipe_trend_index_score" ON "recipe_trend" ("score" DESC);
+
+ALTER TABLE "recipe_trend"
+  DROP CONSTRAINT IF EXISTS "fk_recipe_trend_recipe_id",
+  ADD CONSTRAINT "fk_recipe_trend_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "audit_log";
+DROP FUNCTION IF EXISTS prevent_audit_log_change();
+
+ALTER TABLE "user"
+  DROP CONSTRAINT IF EXISTS "check_user_role",
+  DROP COLUMN IF EXISTS "token_version",
+  DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,44 @@
+ALTER TABLE "user"
+  ADD COLUMN IF NOT EXISTS "role" varchar(20) NOT NULL DEFAULT 'user',
+  ADD COLUMN IF NOT EXISTS "token_version" integer NOT NULL DEFAULT 0,
+  DROP CONSTRAINT IF EXISTS "check_user_role",
+  ADD CONSTRAINT "check_user_role" CHECK ("role" IN ('user', 'admin'));
+
+CREATE TABLE IF NOT EXISTS "audit_log" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "actor_id" uuid,
+  "actor_role" varchar(20),
+  "action" varchar(100) NOT NULL,
+  "entity_type" varchar(50) NOT NULL,
+  "entity_id" varchar(255),
+  "ip" varchar(64),
+  "user_agent" text,
+  "before" jsonb,
+  "after" jsonb,
+  "changes" jsonb,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "audit_log_index_created_at" ON "audit_log" ("created_at" DESC);
+CREATE INDEX IF NOT EXISTS "audit_log_index_actor_id_created_at" ON "audit_log" ("actor_id", "created_at" DESC);
+CREATE INDEX IF NOT EXISTS "audit_log_index_entity" ON "audit_log" ("entity_type", "entity_id");
+CREATE INDEX IF NOT EXISTS "audit_log_index_action" ON "audit_log" ("action");
+
+CREATE OR REPLACE FUNCTION prevent_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON "audit_log";
+CREATE TRIGGER audit_log_append_only
+  BEFORE UPDATE OR DELETE ON "audit_log"
+  FOR EACH ROW
+  EXECUTE FUNCTION prevent_audit_log_change();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON "audit_log";
+CREATE TRIGGER audit_log_no_truncate
+  BEFORE TRUNCATE ON "audit_log"
+  FOR EACH STATEMENT
+  EXECUTE FUNCTION prevent_audit_log_change();
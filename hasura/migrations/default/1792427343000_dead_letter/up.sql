@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS "dead_letter" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "source" varchar(20) NOT NULL,
+  "name" varchar(100) NOT NULL,
+  "reference" varchar(64) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "error" text NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "status" varchar(20) NOT NULL DEFAULT 'open',
+  "replay_count" integer NOT NULL DEFAULT 0,
+  "resolved_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE ("source", "reference"),
+  CONSTRAINT "check_dead_letter_source" CHECK ("source" IN ('event', 'webhook')),
+  CONSTRAINT "check_dead_letter_status" CHECK ("status" IN ('open', 'replaying', 'replayed', 'discarded'))
+);
+CREATE INDEX IF NOT EXISTS "dead_letter_index_status_updated_at" ON "dead_letter" ("status", "updated_at" DESC);
+DROP TRIGGER IF EXISTS update_dead_letter_timestamp ON "dead_letter";
+CREATE TRIGGER update_dead_letter_timestamp
+  BEFORE UPDATE ON "dead_letter"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
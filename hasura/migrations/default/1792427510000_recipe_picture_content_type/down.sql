@@ -0,0 +1,4 @@
+ALTER TABLE "recipe_picture"
+  DROP COLUMN IF EXISTS "height",
+  DROP COLUMN IF EXISTS "width",
+  DROP COLUMN IF EXISTS "content_type";
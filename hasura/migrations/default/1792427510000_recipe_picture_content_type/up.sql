@@ -0,0 +1,18 @@
+ALTER TABLE "recipe_picture"
+  ADD COLUMN IF NOT EXISTS "content_type" varchar(50) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS "width" integer,
+  ADD COLUMN IF NOT EXISTS "height" integer;
+
+-- Pictures uploaded before formats were sniffed take theirs from the path;
+-- the picture handler resolves any left empty from the stored object.
+UPDATE "recipe_picture"
+SET "content_type" = CASE lower(substring("path" FROM '\.([^./]+)$'))
+  WHEN 'jpg' THEN 'image/jpeg'
+  WHEN 'jpeg' THEN 'image/jpeg'
+  WHEN 'png' THEN 'image/png'
+  WHEN 'webp' THEN 'image/webp'
+  ELSE ''
+END
+WHERE "content_type" = '';
+
+ALTER TABLE "recipe_picture" ALTER COLUMN "content_type" DROP DEFAULT;
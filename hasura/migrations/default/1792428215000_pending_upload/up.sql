@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS "pending_upload" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "user_id" uuid NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "key" varchar(255) NOT NULL,
+  "content_type" varchar(100) NOT NULL,
+  "size" bigint NOT NULL,
+  "expires_at" timestamp with time zone NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE ("key"),
+  CONSTRAINT "check_pending_upload_size" CHECK ("size" > 0)
+);
+CREATE INDEX IF NOT EXISTS "pending_upload_index_expires_at" ON "pending_upload" ("expires_at");
+
+ALTER TABLE "pending_upload"
+  DROP CONSTRAINT IF EXISTS "fk_pending_upload_user_id",
+  ADD CONSTRAINT "fk_pending_upload_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "pending_upload"
+  DROP CONSTRAINT IF EXISTS "fk_pending_upload_recipe_id",
+  ADD CONSTRAINT "fk_pending_upload_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "recipe_picture_index_recipe_id_position";
+CREATE INDEX IF NOT EXISTS "recipe_picture_index_recipe_id" ON "recipe_picture" ("recipe_id");
+
+ALTER TABLE "recipe_picture" DROP COLUMN IF EXISTS "position";
@@ -0,0 +1,18 @@
+ALTER TABLE "recipe_picture"
+  ADD COLUMN IF NOT EXISTS "position" integer NOT NULL DEFAULT 0;
+
+-- Existing galleries keep the order pictures were uploaded in.
+UPDATE "recipe_picture"
+SET "position" = ordered."position"
+FROM (
+  SELECT "id", row_number() OVER (PARTITION BY "recipe_id" ORDER BY "created_at", "id") - 1 AS "position"
+  FROM "recipe_picture"
+) AS ordered
+WHERE "recipe_picture"."id" = ordered."id"
+  AND NOT EXISTS (
+    SELECT 1 FROM "recipe_picture" AS positioned
+    WHERE positioned."recipe_id" = "recipe_picture"."recipe_id" AND positioned."position" <> 0
+  );
+
+DROP INDEX IF EXISTS "recipe_picture_index_recipe_id";
+CREATE INDEX IF NOT EXISTS "recipe_picture_index_recipe_id_position" ON "recipe_picture" ("recipe_id", "position");
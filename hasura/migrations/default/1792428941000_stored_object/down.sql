@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS trigger_update_stored_object_ref_count ON "recipe_picture";
+DROP FUNCTION IF EXISTS update_stored_object_ref_count();
+
+ALTER TABLE "recipe_picture" DROP CONSTRAINT IF EXISTS "fk_recipe_picture_path";
+DROP TABLE IF EXISTS "stored_object";
+
+-- The path uniqueness is not restored: pictures uploaded since may share an
+-- object.
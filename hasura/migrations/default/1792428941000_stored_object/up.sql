@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS "stored_object" (
+  "key" varchar(255) NOT NULL,
+  "content_type" varchar(100) NOT NULL,
+  "size" bigint NOT NULL,
+  "ref_count" integer NOT NULL DEFAULT 0,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("key"),
+  CONSTRAINT "check_stored_object_ref_count" CHECK ("ref_count" >= 0)
+);
+CREATE INDEX IF NOT EXISTS "stored_object_index_unreferenced" ON "stored_object" ("updated_at") WHERE "ref_count" = 0;
+DROP TRIGGER IF EXISTS update_stored_object_timestamp ON "stored_object";
+CREATE TRIGGER update_stored_object_timestamp
+  BEFORE UPDATE ON "stored_object"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- Pictures with the same content now share one object.
+ALTER TABLE "recipe_picture" DROP CONSTRAINT IF EXISTS "recipe_picture_path_key";
+
+-- Pictures stored before stored_object existed, under their picture's UUID,
+-- get a row counting the pictures that use them, so the constraint below holds
+-- and collection does not take them for orphans. Their size was never
+-- recorded.
+INSERT INTO "stored_object" ("key", "content_type", "size", "ref_count")
+SELECT "path", min("content_type"), 0, count(*)
+FROM "recipe_picture"
+GROUP BY "path"
+ON CONFLICT ("key") DO UPDATE SET "ref_count" = EXCLUDED."ref_count";
+
+ALTER TABLE "recipe_picture"
+  DROP CONSTRAINT IF EXISTS "fk_recipe_picture_path",
+  ADD CONSTRAINT "fk_recipe_picture_path"
+  FOREIGN KEY ("path") REFERENCES "stored_object" ("key");
+
+CREATE OR REPLACE FUNCTION update_stored_object_ref_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+        UPDATE "stored_object"
+        SET ref_count = ref_count + 1
+        WHERE key = NEW.path;
+    END IF;
+    IF TG_OP = 'DELETE' OR TG_OP = 'UPDATE' THEN
+        UPDATE "stored_object"
+        SET ref_count = GREATEST(ref_count - 1, 0)
+        WHERE key = OLD.path;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_stored_object_ref_count ON "recipe_picture";
+CREATE TRIGGER trigger_update_stored_object_ref_count
+AFTER INSERT OR UPDATE OF path OR DELETE ON "recipe_picture"
+FOR EACH ROW EXECUTE FUNCTION update_stored_object_ref_count();
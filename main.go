@@ -1,17 +1,42 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
+	"app/cli"
+	"app/config"
 	"app/framework"
 	"app/handlers"
 )
 
 func main() {
-	handlers.SetupRoutes(framework.GetRouter())
+	cfg, minioCfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
 
-	addr := ":8080"
-	log.Printf("Server running at http://localhost%s", addr)
-	log.Fatal(http.ListenAndServe(addr, framework.GetRouter().Instance))
+	if len(os.Args) > 1 && os.Args[1] == "dlq" {
+		client := cli.NewHasuraClient(cfg.Hasura.Endpoint, cfg.Hasura.AdminSecret)
+		if err := cli.DeadLetters(context.Background(), client, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	handlers.SetupRoutes(framework.GetRouter(), cfg, minioCfg)
+
+	server := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      framework.GetRouter().Instance,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	log.Printf("Server running at http://localhost%s", cfg.Server.Addr)
+	log.Fatal(server.ListenAndServe())
 }
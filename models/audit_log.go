@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditSignInSucceeded       = "auth.signin_succeeded"
+	AuditSignInFailed          = "auth.signin_failed"
+	AuditPasswordChanged       = "user.password_changed"
+	AuditRoleChanged           = "user.role_changed"
+	AuditRecipeCreated         = "recipe.created"
+	AuditRecipeUpdated         = "recipe.updated"
+	AuditRecipeDeleted         = "recipe.deleted"
+	AuditRecipePictureUploaded = "recipe_picture.uploaded"
+	AuditRecipePictureDeleted  = "recipe_picture.deleted"
+)
+
+// AuditLog is an append-only record of a security-relevant or
+// content-changing operation. The database rejects updates and deletes.
+type AuditLog struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	ActorID    *uuid.UUID      `gorm:"type:uuid"`
+	ActorRole  string          `gorm:"type:varchar(20)"`
+	Action     string          `gorm:"type:varchar(100);not null"`
+	EntityType string          `gorm:"type:varchar(50);not null"`
+	EntityID   string          `gorm:"type:varchar(255)"`
+	IP         string          `gorm:"type:varchar(64)"`
+	UserAgent  string          `gorm:"type:text"`
+	Before     json.RawMessage `gorm:"type:jsonb"`
+	After      json.RawMessage `gorm:"type:jsonb"`
+	Changes    json.RawMessage `gorm:"type:jsonb"`
+	CreatedAt  time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Collection struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null"`
+	Name      string    `gorm:"type:varchar(100);not null"`
+	IsDefault bool      `gorm:"not null;default:false"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (Collection) TableName() string {
+	return "collection"
+}
+
+type CollectionRecipe struct {
+	CollectionID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RecipeID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CreatedAt    time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (CollectionRecipe) TableName() string {
+	return "collection_recipe"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CronRunStatusRunning   = "running"
+	CronRunStatusSucceeded = "succeeded"
+	CronRunStatusFailed    = "failed"
+)
+
+// CronRun records one execution of a scheduled maintenance job.
+type CronRun struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	Name          string          `gorm:"type:varchar(100);not null"`
+	CronEventID   string          `gorm:"type:varchar(64)"`
+	ScheduledTime *time.Time      `gorm:"type:timestamptz"`
+	Status        string          `gorm:"type:varchar(20);not null;default:running"`
+	Result        json.RawMessage `gorm:"type:jsonb"`
+	Error         string          `gorm:"type:text"`
+	DurationMs    int64           `gorm:"type:bigint"`
+	StartedAt     time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	FinishedAt    *time.Time      `gorm:"type:timestamptz"`
+}
+
+func (CronRun) TableName() string {
+	return "cron_run"
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeadLetterSourceEvent   = "event"
+	DeadLetterSourceWebhook = "webhook"
+)
+
+var DeadLetterSources = []string{DeadLetterSourceEvent, DeadLetterSourceWebhook}
+
+const (
+	DeadLetterOpen      = "open"
+	DeadLetterReplaying = "replaying"
+	DeadLetterReplayed  = "replayed"
+	DeadLetterDiscarded = "discarded"
+)
+
+var DeadLetterStatuses = []string{DeadLetterOpen, DeadLetterReplaying, DeadLetterReplayed, DeadLetterDiscarded}
+
+// DeadLetter is a Hasura event or webhook delivery that exhausted its retries.
+// Name is the trigger name or webhook event type and Reference the event or
+// delivery id; a reference that fails again reopens its existing row.
+type DeadLetter struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	Source      string          `gorm:"type:varchar(20);not null"`
+	Name        string          `gorm:"type:varchar(100);not null"`
+	Reference   string          `gorm:"type:varchar(64);not null"`
+	Payload     json.RawMessage `gorm:"type:jsonb;not null"`
+	Error       string          `gorm:"type:text;not null"`
+	Attempts    int             `gorm:"type:integer;not null;default:0"`
+	Status      string          `gorm:"type:varchar(20);not null;default:open"`
+	ReplayCount int             `gorm:"type:integer;not null;default:0"`
+	ResolvedAt  *time.Time      `gorm:"type:timestamptz"`
+	CreatedAt   time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (DeadLetter) TableName() string {
+	return "dead_letter"
+}
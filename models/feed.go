@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FeedItem struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RecipeID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Reason    string    `gorm:"type:varchar(50);not null"`
+	Score     float64   `gorm:"type:double precision;not null;default:0"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (FeedItem) TableName() string {
+	return "feed_item"
+}
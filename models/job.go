@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+type Job struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	Kind        string          `gorm:"type:varchar(100);not null"`
+	Status      string          `gorm:"type:varchar(20);not null;default:pending"`
+	UserID      *uuid.UUID      `gorm:"type:uuid"`
+	Input       json.RawMessage `gorm:"type:jsonb;not null"`
+	Session     json.RawMessage `gorm:"type:jsonb"`
+	Result      json.RawMessage `gorm:"type:jsonb"`
+	Error       string          `gorm:"type:text"`
+	Attempts    int             `gorm:"type:integer;not null;default:0"`
+	MaxAttempts int             `gorm:"type:integer;not null;default:3"`
+	RunAfter    time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	LockedAt    *time.Time      `gorm:"type:timestamptz"`
+	FinishedAt  *time.Time      `gorm:"type:timestamptz"`
+	CreatedAt   time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (Job) TableName() string {
+	return "job"
+}
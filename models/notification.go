@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Follows are not notified: the schema has no follow relationship to
+// trigger on, and notifications are kept per recipe.
+const (
+	NotificationTypeLike    = "like"
+	NotificationTypeComment = "comment"
+	NotificationTypeRating  = "rating"
+)
+
+var NotificationTypes = []string{NotificationTypeLike, NotificationTypeComment, NotificationTypeRating}
+
+// Notification aggregates every unread event of one type on one recipe;
+// ActorCount grows as more people like, comment on or rate it.
+type Notification struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null"`
+	Type        string     `gorm:"type:varchar(20);not null"`
+	RecipeID    uuid.UUID  `gorm:"type:uuid;not null"`
+	LastActorID uuid.UUID  `gorm:"type:uuid;not null"`
+	ActorCount  int        `gorm:"type:integer;not null;default:1"`
+	Preview     string     `gorm:"type:text"`
+	ReadAt      *time.Time `gorm:"type:timestamptz"`
+	CreatedAt   time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
+
+type NotificationSummary struct {
+	Notification
+	RecipeTitle   string
+	LastActorName string
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Type      string    `gorm:"type:varchar(20);primaryKey"`
+	Enabled   bool      `gorm:"not null;default:true"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preference"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OutboxAggregateRecipe = "recipe"
+
+	OutboxEventRecipePictureAdded = "recipe.picture_added"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change
+// it describes. Sequence orders events; per aggregate, the relay publishes
+// them strictly in that order. ClaimedUntil is set while a relay publishes
+// the event.
+type OutboxEvent struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	Sequence      int64           `gorm:"type:bigserial;autoIncrement;not null;<-:false"`
+	AggregateType string          `gorm:"type:varchar(50);not null"`
+	AggregateID   uuid.UUID       `gorm:"type:uuid;not null"`
+	EventType     string          `gorm:"type:varchar(100);not null"`
+	Payload       json.RawMessage `gorm:"type:jsonb;not null"`
+	Attempts      int             `gorm:"type:integer;not null;default:0"`
+	LastError     string          `gorm:"type:text"`
+	NextAttemptAt time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	PublishedAt   *time.Time      `gorm:"type:timestamptz"`
+	ClaimedUntil  *time.Time      `gorm:"type:timestamptz"`
+	CreatedAt     time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_event"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingUpload is a picture a client was handed a presigned URL for but has
+// not confirmed yet. Key is where the client puts the file; it is removed
+// along with the row once the upload is confirmed or expires.
+type PendingUpload struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null"`
+	RecipeID    uuid.UUID `gorm:"type:uuid;not null"`
+	Key         string    `gorm:"type:varchar(255);not null;unique"`
+	ContentType string    `gorm:"type:varchar(100);not null"`
+	Size        int64     `gorm:"type:bigint;not null"`
+	ExpiresAt   time.Time `gorm:"type:timestamptz;not null"`
+	CreatedAt   time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (PendingUpload) TableName() string {
+	return "pending_upload"
+}
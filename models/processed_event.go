@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ProcessedEvent struct {
+	EventID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TriggerName string    `gorm:"type:varchar(255);not null"`
+	ProcessedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (ProcessedEvent) TableName() string {
+	return "processed_event"
+}
@@ -4,16 +4,117 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type RecipePicture struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
-	RecipeId  uuid.UUID `gorm:"type:uuid;not null"`
-	Path      string    `gorm:"type:varchar(225);not null;unique"`
-	CreatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
-	UpdatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RecipeId    uuid.UUID `gorm:"type:uuid;not null"`
+	Path        string    `gorm:"type:varchar(225);not null"`
+	ContentType string    `gorm:"type:varchar(50);not null"`
+	Width       int       `gorm:"type:integer"`
+	Height      int       `gorm:"type:integer"`
+	Position    int       `gorm:"type:integer;not null;default:0"`
+	CreatedAt   time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
 }
 
 func (RecipePicture) TableName() string {
 	return "recipe_picture"
 }
+
+type RecipeStep struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	RecipeID    uuid.UUID  `gorm:"type:uuid;not null"`
+	Index       int        `gorm:"type:integer;not null"`
+	Description string     `gorm:"type:text;not null"`
+	PictureID   *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt   time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (RecipeStep) TableName() string {
+	return "recipe_step"
+}
+
+type Recipe struct {
+	ID              uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	Title           string         `gorm:"type:varchar(255);not null"`
+	CategoryID      uuid.UUID      `gorm:"type:uuid;not null"`
+	CreatorID       uuid.UUID      `gorm:"type:uuid;not null"`
+	PreparationTime int64          `gorm:"type:bigint;not null"`
+	ThumbnailID     *uuid.UUID     `gorm:"type:uuid"`
+	LikeCount       int64          `gorm:"type:bigint;default:0"`
+	RatingCount     int64          `gorm:"type:bigint;default:0"`
+	AverageRating   float64        `gorm:"type:decimal(3,2);default:0.00"`
+	CreatedAt       time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt       time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	DeletedAt       gorm.DeletedAt `gorm:"type:timestamptz;index"`
+}
+
+func (Recipe) TableName() string {
+	return "recipe"
+}
+
+type Ingredient struct {
+	ID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name string    `gorm:"type:varchar(100);not null;unique"`
+}
+
+func (Ingredient) TableName() string {
+	return "ingredient"
+}
+
+type RecipeIngredient struct {
+	RecipeID     uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	IngredientID uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	Quantity     float64    `gorm:"type:decimal;not null"`
+	Unit         string     `gorm:"type:varchar(50);not null"`
+	CreatedAt    time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	Ingredient   Ingredient `gorm:"foreignKey:IngredientID"`
+}
+
+func (RecipeIngredient) TableName() string {
+	return "recipe_ingredient"
+}
+
+type RecipeRecommendation struct {
+	RecipeID uuid.UUID
+	Score    float64
+	Reason   string
+}
+
+type LikedRecipe struct {
+	RecipeID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (LikedRecipe) TableName() string {
+	return "liked_recipe"
+}
+
+type Rating struct {
+	RecipeID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Value     int       `gorm:"type:integer;not null"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (Rating) TableName() string {
+	return "rating"
+}
+
+type Comment struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	RecipeID  uuid.UUID      `gorm:"type:uuid;not null"`
+	UserID    uuid.UUID      `gorm:"type:uuid;not null"`
+	Content   string         `gorm:"type:text;not null"`
+	CreatedAt time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz;index"`
+}
+
+func (Comment) TableName() string {
+	return "comment"
+}
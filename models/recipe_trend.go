@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecipeTrend holds a recipe's trending score, recomputed on a schedule from
+// recent likes, ratings and comments. It lives outside the recipe row so
+// that recomputing does not touch recipe.updated_at or fire recipe triggers.
+type RecipeTrend struct {
+	RecipeID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Score      float64   `gorm:"type:double precision;not null;default:0"`
+	ComputedAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (RecipeTrend) TableName() string {
+	return "recipe_trend"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// StoredObject is a picture file stored under the SHA-256 of its content,
+// shared by every recipe_picture with that path. RefCount is kept by a
+// trigger on recipe_picture; the file, its variants and the row are deleted
+// once it drops to zero.
+type StoredObject struct {
+	Key         string    `gorm:"type:varchar(255);primaryKey"`
+	ContentType string    `gorm:"type:varchar(100);not null"`
+	Size        int64     `gorm:"type:bigint;not null"`
+	RefCount    int       `gorm:"type:integer;not null;default:0"`
+	CreatedAt   time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (StoredObject) TableName() string {
+	return "stored_object"
+}
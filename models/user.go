@@ -7,15 +7,26 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var Roles = []string{RoleUser, RoleAdmin}
+
+// User is an account. TokenVersion is carried by the tokens issued to the
+// user; bumping it revokes them.
 type User struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primaryKey"`
-	Username  string         `gorm:"type:varchar(255);unique;not null"`
-	Name      string         `gorm:"type:varchar(255);not null"`
-	Bio       string         `gorm:"type:text"`
-	Password  string         `gorm:"type:varchar(255);not null"`
-	CreatedAt time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
-	UpdatedAt time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
-	DeletedAt gorm.DeletedAt `gorm:"type:timestamptz;index"`
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	Username     string         `gorm:"type:varchar(255);unique;not null"`
+	Name         string         `gorm:"type:varchar(255);not null"`
+	Bio          string         `gorm:"type:text"`
+	Password     string         `gorm:"type:varchar(255);not null"`
+	Role         string         `gorm:"type:varchar(20);not null;default:user"`
+	TokenVersion int            `gorm:"type:integer;not null;default:0"`
+	CreatedAt    time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	DeletedAt    gorm.DeletedAt `gorm:"type:timestamptz;index"`
 }
 
 func (User) TableName() string {
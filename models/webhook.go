@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookEventRecipePublished = "recipe.published"
+	WebhookEventRecipeUpdated   = "recipe.updated"
+	WebhookEventRecipeDeleted   = "recipe.deleted"
+)
+
+var WebhookEventTypes = []string{WebhookEventRecipePublished, WebhookEventRecipeUpdated, WebhookEventRecipeDeleted}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookEndpoint is a partner URL subscribed to some recipe event types.
+// Endpoints registered through the admin role have no owner.
+type WebhookEndpoint struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	OwnerID    *uuid.UUID `gorm:"type:uuid"`
+	URL        string     `gorm:"type:text;not null"`
+	Secret     string     `gorm:"type:varchar(100);not null"`
+	EventTypes []string   `gorm:"type:jsonb;serializer:json;not null"`
+	Active     bool       `gorm:"not null;default:true"`
+	CreatedAt  time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time  `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoint"
+}
+
+// WebhookDelivery is one event sent to one endpoint. It doubles as the
+// delivery log: failed attempts are retried until the delivery goes dead.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primaryKey"`
+	EndpointID     uuid.UUID       `gorm:"type:uuid;not null"`
+	EventID        string          `gorm:"type:varchar(64);not null"`
+	EventType      string          `gorm:"type:varchar(50);not null"`
+	Payload        json.RawMessage `gorm:"type:jsonb;not null"`
+	Status         string          `gorm:"type:varchar(20);not null;default:pending"`
+	Attempts       int             `gorm:"type:integer;not null;default:0"`
+	LastStatusCode *int            `gorm:"type:integer"`
+	LastError      string          `gorm:"type:text"`
+	DeliveredAt    *time.Time      `gorm:"type:timestamptz"`
+	CreatedAt      time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt      time.Time       `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AuditLogFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	EntityType string
+	EntityID   string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+type AuditLogRepository interface {
+	Append(ctx context.Context, entry *models.AuditLog) error
+	Query(ctx context.Context, filter AuditLogFilter, limit, offset int) ([]models.AuditLog, int64, error)
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+func (r *auditLogRepository) Append(ctx context.Context, entry *models.AuditLog) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	return translateError(conn(ctx, r.db).Create(entry).Error)
+}
+
+func (r *auditLogRepository) Query(ctx context.Context, filter AuditLogFilter, limit, offset int) ([]models.AuditLog, int64, error) {
+	query := conn(ctx, r.db).Model(&models.AuditLog{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	var entries []models.AuditLog
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"app/framework"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const realtimeChannel = "realtime"
+
+// postgresBroker fans hub messages out across replicas with LISTEN/NOTIFY.
+// Publishing inside a transaction defers the NOTIFY until commit.
+type postgresBroker struct {
+	db  *gorm.DB
+	dsn string
+}
+
+func NewPostgresBroker(db *gorm.DB, dsn string) framework.Broker {
+	return &postgresBroker{db: db, dsn: dsn}
+}
+
+func (b *postgresBroker) Publish(ctx context.Context, message framework.Message) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return translateError(conn(ctx, b.db).Exec("SELECT pg_notify(?, ?)", realtimeChannel, string(payload)).Error)
+}
+
+func (b *postgresBroker) Listen(ctx context.Context, deliver func(framework.Message)) error {
+	for {
+		err := b.listen(ctx, deliver)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Realtime listener disconnected, reconnecting: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (b *postgresBroker) listen(ctx context.Context, deliver func(framework.Message)) error {
+	listener, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer listener.Close(context.Background())
+
+	if _, err := listener.Exec(ctx, "LISTEN "+realtimeChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		notification, err := listener.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var message framework.Message
+		if err := json.Unmarshal([]byte(notification.Payload), &message); err != nil {
+			log.Printf("Ignoring malformed realtime message: %v", err)
+			continue
+		}
+		deliver(message)
+	}
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CollectionRepository interface {
+	CreateDefaultCollections(ctx context.Context, userID uuid.UUID, names []string) error
+}
+
+type collectionRepository struct {
+	db *gorm.DB
+}
+
+func NewCollectionRepository(db *gorm.DB) CollectionRepository {
+	return &collectionRepository{db: db}
+}
+
+func (r *collectionRepository) CreateDefaultCollections(ctx context.Context, userID uuid.UUID, names []string) error {
+	collections := make([]models.Collection, 0, len(names))
+	for _, name := range names {
+		collections = append(collections, models.Collection{
+			ID:        uuid.New(),
+			UserID:    userID,
+			Name:      name,
+			IsDefault: true,
+		})
+	}
+	return translateError(conn(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}, {Name: "name"}}, DoNothing: true}).
+		Create(&collections).Error)
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CronRunRepository interface {
+	Start(ctx context.Context, run *models.CronRun) error
+	Finish(ctx context.Context, run *models.CronRun) error
+}
+
+type cronRunRepository struct {
+	db *gorm.DB
+}
+
+func NewCronRunRepository(db *gorm.DB) CronRunRepository {
+	return &cronRunRepository{db: db}
+}
+
+func (r *cronRunRepository) Start(ctx context.Context, run *models.CronRun) error {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	if run.Status == "" {
+		run.Status = models.CronRunStatusRunning
+	}
+	return translateError(conn(ctx, r.db).Create(run).Error)
+}
+
+func (r *cronRunRepository) Finish(ctx context.Context, run *models.CronRun) error {
+	return translateError(conn(ctx, r.db).Model(run).Select("status", "result", "error", "duration_ms", "finished_at").Updates(run).Error)
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeadLetterFilter struct {
+	Source string
+	Name   string
+	Status string
+}
+
+// DeadLetterDepth is the number of dead letters of one source in one status.
+type DeadLetterDepth struct {
+	Source string
+	Status string
+	Count  int64
+}
+
+type DeadLetterRepository interface {
+	Add(ctx context.Context, letter *models.DeadLetter) error
+	FindByID(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error)
+	List(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]models.DeadLetter, int64, error)
+	Save(ctx context.Context, letter *models.DeadLetter) error
+	ClaimReplay(ctx context.Context, id uuid.UUID) (bool, error)
+	Depth(ctx context.Context) ([]DeadLetterDepth, error)
+}
+
+type deadLetterRepository struct {
+	db *gorm.DB
+}
+
+func NewDeadLetterRepository(db *gorm.DB) DeadLetterRepository {
+	return &deadLetterRepository{db: db}
+}
+
+// Add records a dead letter. A source and reference already recorded, say an
+// event that fails again after a replay, is reopened with the new payload
+// and error instead.
+func (r *deadLetterRepository) Add(ctx context.Context, letter *models.DeadLetter) error {
+	if letter.ID == uuid.Nil {
+		letter.ID = uuid.New()
+	}
+	letter.Status = models.DeadLetterOpen
+	letter.ResolvedAt = nil
+
+	return translateError(conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}, {Name: "reference"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "payload", "error", "attempts", "status", "resolved_at", "updated_at"}),
+	}).Create(letter).Error)
+}
+
+func (r *deadLetterRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	var letter models.DeadLetter
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&letter).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &letter, nil
+}
+
+func (r *deadLetterRepository) List(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]models.DeadLetter, int64, error) {
+	query := conn(ctx, r.db).Model(&models.DeadLetter{})
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Name != "" {
+		query = query.Where("name = ?", filter.Name)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err)
+	}
+
+	var letters []models.DeadLetter
+	err := query.Order("updated_at DESC").Limit(limit).Offset(offset).Find(&letters).Error
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return letters, total, nil
+}
+
+func (r *deadLetterRepository) Save(ctx context.Context, letter *models.DeadLetter) error {
+	return translateError(conn(ctx, r.db).Save(letter).Error)
+}
+
+// ClaimReplay moves an open dead letter to replaying. It reports false when
+// the letter was not open, so of concurrent replays only one goes ahead.
+func (r *deadLetterRepository) ClaimReplay(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.DeadLetter{}).
+		Where("id = ? AND status = ?", id, models.DeadLetterOpen).
+		Updates(map[string]any{
+			"status":       models.DeadLetterReplaying,
+			"replay_count": gorm.Expr("replay_count + 1"),
+		})
+	if result.Error != nil {
+		return false, translateError(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *deadLetterRepository) Depth(ctx context.Context) ([]DeadLetterDepth, error) {
+	var depths []DeadLetterDepth
+	err := conn(ctx, r.db).Model(&models.DeadLetter{}).
+		Select("source, status, count(*) AS count").
+		Group("source, status").
+		Order("source, status").
+		Scan(&depths).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return depths, nil
+}
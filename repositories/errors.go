@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound         = errors.New("record not found")
+	ErrConflict         = errors.New("record already exists")
+	ErrInvalidReference = errors.New("referenced record does not exist")
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// ConstraintError carries the name of the violated constraint so callers can
+// tell apart, for example, a taken username from another unique column.
+type ConstraintError struct {
+	Kind       error
+	Table      string
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s: %s violates %s", e.Kind, e.Table, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return &ConstraintError{Kind: ErrConflict, Table: pgErr.TableName, Constraint: pgErr.ConstraintName, Err: err}
+		case pgForeignKeyViolation:
+			return &ConstraintError{Kind: ErrInvalidReference, Table: pgErr.TableName, Constraint: pgErr.ConstraintName, Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	if translateError(nil) != nil {
+		t.Error("nil was translated into an error")
+	}
+	if err := translateError(gorm.ErrRecordNotFound); !errors.Is(err, ErrNotFound) || !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("record not found = %v, want ErrNotFound wrapping it", err)
+	}
+
+	unique := &pgconn.PgError{Code: pgUniqueViolation, TableName: "user", ConstraintName: "user_username_key"}
+	err := translateError(unique)
+	var constraint *ConstraintError
+	if !errors.As(err, &constraint) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("unique violation = %v, want a conflict", err)
+	}
+	if constraint.Table != "user" || constraint.Constraint != "user_username_key" {
+		t.Errorf("constraint = %+v", constraint)
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		t.Error("the driver error is no longer reachable")
+	}
+
+	foreignKey := &pgconn.PgError{Code: pgForeignKeyViolation, TableName: "recipe", ConstraintName: "fk_recipe_category_id"}
+	if err := translateError(foreignKey); !errors.Is(err, ErrInvalidReference) || errors.Is(err, ErrConflict) {
+		t.Errorf("foreign key violation = %v, want an invalid reference", err)
+	}
+
+	other := errors.New("connection refused")
+	if err := translateError(other); err != other {
+		t.Errorf("unrelated error = %v, want it unchanged", err)
+	}
+}
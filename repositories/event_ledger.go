@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EventLedgerRepository interface {
+	RunOnce(ctx context.Context, eventID, triggerName string, fn func(ctx context.Context) error) (bool, error)
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+type eventLedgerRepository struct {
+	db         *gorm.DB
+	transactor Transactor
+}
+
+func NewEventLedgerRepository(db *gorm.DB) EventLedgerRepository {
+	return &eventLedgerRepository{db: db, transactor: NewTransactor(db)}
+}
+
+// RunOnce records eventID and runs fn in one transaction. When the event is
+// already in the ledger fn is skipped and RunOnce reports a duplicate; when fn
+// fails the ledger row is rolled back with the rest of its work.
+func (r *eventLedgerRepository) RunOnce(ctx context.Context, eventID, triggerName string, fn func(ctx context.Context) error) (bool, error) {
+	id, err := uuid.Parse(eventID)
+	if err != nil {
+		return false, fmt.Errorf("invalid event id %q: %w", eventID, err)
+	}
+
+	duplicate := false
+	err = r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		result := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProcessedEvent{
+			EventID:     id,
+			TriggerName: triggerName,
+			ProcessedAt: time.Now(),
+		})
+		if result.Error != nil {
+			return translateError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+		return fn(ctx)
+	})
+	return duplicate, err
+}
+
+func (r *eventLedgerRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result := conn(ctx, r.db).Where("processed_at < ?", before).Delete(&models.ProcessedEvent{})
+	return result.RowsAffected, translateError(result.Error)
+}
@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunOnceRejectsInvalidEventIDs(t *testing.T) {
+	ledger := &eventLedgerRepository{}
+	ran := false
+	_, err := ledger.RunOnce(context.Background(), "not-a-uuid", "recipe_liked", func(context.Context) error {
+		ran = true
+		return nil
+	})
+	if err == nil || ran {
+		t.Errorf("an event without a valid id ran (err = %v)", err)
+	}
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+
+	"app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FeedRepository interface {
+	AddItems(ctx context.Context, items []models.FeedItem) error
+}
+
+type feedRepository struct {
+	db *gorm.DB
+}
+
+func NewFeedRepository(db *gorm.DB) FeedRepository {
+	return &feedRepository{db: db}
+}
+
+func (r *feedRepository) AddItems(ctx context.Context, items []models.FeedItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return translateError(conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error)
+}
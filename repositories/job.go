@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type JobRepository interface {
+	Enqueue(ctx context.Context, job *models.Job) error
+	ClaimNext(ctx context.Context, kinds []string, lease time.Duration) (*models.Job, error)
+	Extend(ctx context.Context, id uuid.UUID) error
+	Complete(ctx context.Context, id uuid.UUID, result json.RawMessage) error
+	Fail(ctx context.Context, id uuid.UUID, message string, retryAt *time.Time) error
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Job, error)
+}
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func (r *jobRepository) Enqueue(ctx context.Context, job *models.Job) error {
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	if job.Status == "" {
+		job.Status = models.JobStatusPending
+	}
+	if job.RunAfter.IsZero() {
+		job.RunAfter = time.Now()
+	}
+	return translateError(conn(ctx, r.db).Create(job).Error)
+}
+
+// ClaimNext locks the oldest runnable job with SKIP LOCKED so that several
+// workers, possibly in different replicas, never pick up the same row. Jobs
+// left running past the lease are assumed orphaned and are claimed again.
+func (r *jobRepository) ClaimNext(ctx context.Context, kinds []string, lease time.Duration) (*models.Job, error) {
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	var job models.Job
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("kind IN ?", kinds).
+			Where("(status = ? AND run_after <= ?) OR (status = ? AND locked_at < ?)",
+				models.JobStatusPending, now, models.JobStatusRunning, now.Add(-lease)).
+			Order("run_after").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Model(&job).Updates(map[string]any{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": now,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &job, nil
+}
+
+// Extend renews the lease of a running job, so it is not taken for orphaned
+// while its worker is still processing it.
+func (r *jobRepository) Extend(ctx context.Context, id uuid.UUID) error {
+	return translateError(conn(ctx, r.db).Model(&models.Job{}).
+		Where("id = ? AND status = ?", id, models.JobStatusRunning).
+		Update("locked_at", time.Now()).Error)
+}
+
+func (r *jobRepository) Complete(ctx context.Context, id uuid.UUID, result json.RawMessage) error {
+	return translateError(conn(ctx, r.db).Model(&models.Job{}).Where("id = ?", id).Updates(map[string]any{
+		"status":      models.JobStatusSucceeded,
+		"result":      result,
+		"error":       "",
+		"locked_at":   nil,
+		"finished_at": time.Now(),
+	}).Error)
+}
+
+// Fail puts the job back in the queue to run at retryAt, or marks it failed
+// for good when retryAt is nil.
+func (r *jobRepository) Fail(ctx context.Context, id uuid.UUID, message string, retryAt *time.Time) error {
+	updates := map[string]any{
+		"error":     message,
+		"locked_at": nil,
+	}
+	if retryAt != nil {
+		updates["status"] = models.JobStatusPending
+		updates["run_after"] = *retryAt
+	} else {
+		updates["status"] = models.JobStatusFailed
+		updates["finished_at"] = time.Now()
+	}
+	return translateError(conn(ctx, r.db).Model(&models.Job{}).Where("id = ?", id).Updates(updates).Error)
+}
+
+func (r *jobRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	var job models.Job
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MaintenanceRepository interface {
+	PurgeDeletedRecipes(ctx context.Context, before time.Time) (int64, error)
+	PurgeDeletedComments(ctx context.Context, before time.Time) (int64, error)
+	ReconcileRecipeCounts(ctx context.Context) (int64, error)
+	RecomputeTrendingScores(ctx context.Context, since time.Time) (int64, error)
+	FindReferencedPicturePaths(ctx context.Context, paths []string) ([]string, error)
+}
+
+type maintenanceRepository struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceRepository(db *gorm.DB) MaintenanceRepository {
+	return &maintenanceRepository{db: db}
+}
+
+// PurgeDeletedRecipes hard deletes recipes soft deleted before the cutoff;
+// their pictures, steps, likes and so on go with them through the foreign
+// keys.
+func (r *maintenanceRepository) PurgeDeletedRecipes(ctx context.Context, before time.Time) (int64, error) {
+	result := conn(ctx, r.db).Exec(`DELETE FROM recipe WHERE deleted_at < ?`, before)
+	return result.RowsAffected, translateError(result.Error)
+}
+
+func (r *maintenanceRepository) PurgeDeletedComments(ctx context.Context, before time.Time) (int64, error) {
+	result := conn(ctx, r.db).Exec(`DELETE FROM comment WHERE deleted_at < ?`, before)
+	return result.RowsAffected, translateError(result.Error)
+}
+
+// ReconcileRecipeCounts recomputes the counters maintained by the like and
+// rating triggers and fixes the recipes where they drifted.
+func (r *maintenanceRepository) ReconcileRecipeCounts(ctx context.Context) (int64, error) {
+	result := conn(ctx, r.db).Exec(`
+		WITH actual AS (
+			SELECT recipe.id,
+				(SELECT COUNT(*) FROM liked_recipe WHERE liked_recipe.recipe_id = recipe.id) AS like_count,
+				(SELECT COUNT(*) FROM rating WHERE rating.recipe_id = recipe.id) AS rating_count,
+				(SELECT COALESCE(AVG(rating.value), 0)::decimal(3,2) FROM rating WHERE rating.recipe_id = recipe.id) AS average_rating
+			FROM recipe
+		)
+		UPDATE recipe
+		SET like_count = actual.like_count,
+			rating_count = actual.rating_count,
+			average_rating = actual.average_rating
+		FROM actual
+		WHERE recipe.id = actual.id
+			AND (recipe.like_count IS DISTINCT FROM actual.like_count
+				OR recipe.rating_count IS DISTINCT FROM actual.rating_count
+				OR recipe.average_rating IS DISTINCT FROM actual.average_rating)`)
+	return result.RowsAffected, translateError(result.Error)
+}
+
+// RecomputeTrendingScores scores every recipe from its activity since the
+// cutoff. Each like, rating or comment (comments count double) decays with
+// its age in hours, so recent activity dominates.
+func (r *maintenanceRepository) RecomputeTrendingScores(ctx context.Context, since time.Time) (int64, error) {
+	result := conn(ctx, r.db).Exec(`
+		WITH activity AS (
+			SELECT recipe_id, created_at, 1.0 AS weight FROM liked_recipe WHERE created_at > @since
+			UNION ALL
+			SELECT recipe_id, created_at, 1.0 FROM rating WHERE created_at > @since
+			UNION ALL
+			SELECT recipe_id, created_at, 2.0 FROM comment WHERE created_at > @since AND deleted_at IS NULL
+		), scores AS (
+			SELECT recipe.id AS recipe_id,
+				COALESCE(SUM(activity.weight / power(EXTRACT(EPOCH FROM (now() - activity.created_at)) / 3600 + 2, 1.5)), 0) AS score
+			FROM recipe
+			LEFT JOIN activity ON activity.recipe_id = recipe.id
+			WHERE recipe.deleted_at IS NULL
+			GROUP BY recipe.id
+		)
+		INSERT INTO recipe_trend (recipe_id, score, computed_at)
+		SELECT recipe_id, score, now() FROM scores
+		ON CONFLICT (recipe_id) DO UPDATE SET score = EXCLUDED.score, computed_at = EXCLUDED.computed_at`,
+		sql.Named("since", since),
+	)
+	if result.Error != nil {
+		return 0, translateError(result.Error)
+	}
+
+	// Scores of recipes deleted since the last run are dropped.
+	if err := conn(ctx, r.db).Exec(`
+		DELETE FROM recipe_trend USING recipe
+		WHERE recipe.id = recipe_trend.recipe_id AND recipe.deleted_at IS NOT NULL`).Error; err != nil {
+		return 0, translateError(err)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *maintenanceRepository) FindReferencedPicturePaths(ctx context.Context, paths []string) ([]string, error) {
+	var referenced []string
+	err := conn(ctx, r.db).Table("recipe_picture").Where("path IN ?", paths).Pluck("path", &referenced).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return referenced, nil
+}
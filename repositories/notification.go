@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	Upsert(ctx context.Context, notification *models.Notification) error
+	ListForUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]models.NotificationSummary, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkRead(ctx context.Context, userID, id uuid.UUID) (*models.Notification, error)
+	MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	IsEnabled(ctx context.Context, userID uuid.UUID, notificationType string) (bool, error)
+	SavePreference(ctx context.Context, preference *models.NotificationPreference) error
+	FindPreferences(ctx context.Context, userID uuid.UUID) ([]models.NotificationPreference, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// Upsert folds the notification into the recipient's unread one of the same
+// type and recipe, if any, and loads the resulting row back into it. The
+// actor count is of distinct actors, so one user liking and unliking over
+// and over is counted once.
+func (r *notificationRepository) Upsert(ctx context.Context, notification *models.Notification) error {
+	if notification.ID == uuid.Nil {
+		notification.ID = uuid.New()
+	}
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var id uuid.UUID
+		err := tx.Raw(`
+			INSERT INTO notification (id, user_id, type, recipe_id, last_actor_id, actor_count, preview)
+			VALUES (@id, @user, @type, @recipe, @actor, 1, @preview)
+			ON CONFLICT (user_id, type, recipe_id) WHERE read_at IS NULL
+			DO UPDATE SET last_actor_id = EXCLUDED.last_actor_id,
+				preview = EXCLUDED.preview,
+				updated_at = CURRENT_TIMESTAMP
+			RETURNING id`,
+			sql.Named("id", notification.ID),
+			sql.Named("user", notification.UserID),
+			sql.Named("type", notification.Type),
+			sql.Named("recipe", notification.RecipeID),
+			sql.Named("actor", notification.LastActorID),
+			sql.Named("preview", notification.Preview),
+		).Scan(&id).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`
+			INSERT INTO notification_actor (notification_id, actor_id)
+			VALUES (?, ?)
+			ON CONFLICT DO NOTHING`,
+			id, notification.LastActorID,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Raw(`
+			UPDATE notification
+			SET actor_count = (SELECT count(*) FROM notification_actor WHERE notification_id = @id)
+			WHERE id = @id
+			RETURNING *`,
+			sql.Named("id", id),
+		).Scan(notification).Error
+	})
+	return translateError(err)
+}
+
+// ListForUser leaves out notifications about recipes that were soft deleted,
+// which are purged along with them.
+func (r *notificationRepository) ListForUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]models.NotificationSummary, error) {
+	query := conn(ctx, r.db).Table("notification").
+		Select("notification.*, recipe.title AS recipe_title, actor.name AS last_actor_name").
+		Joins("JOIN recipe ON recipe.id = notification.recipe_id AND recipe.deleted_at IS NULL").
+		Joins(`JOIN "user" actor ON actor.id = notification.last_actor_id`).
+		Where("notification.user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("notification.read_at IS NULL")
+	}
+
+	var notifications []models.NotificationSummary
+	err := query.Order("notification.updated_at DESC").Limit(limit).Offset(offset).Scan(&notifications).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return notifications, nil
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&models.Notification{}).
+		Joins("JOIN recipe ON recipe.id = notification.recipe_id AND recipe.deleted_at IS NULL").
+		Where("notification.user_id = ? AND notification.read_at IS NULL", userID).
+		Count(&count).Error
+	return count, translateError(err)
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, userID, id uuid.UUID) (*models.Notification, error) {
+	var notification models.Notification
+	if err := conn(ctx, r.db).Where("id = ? AND user_id = ?", id, userID).First(&notification).Error; err != nil {
+		return nil, translateError(err)
+	}
+	if notification.ReadAt != nil {
+		return &notification, nil
+	}
+
+	now := time.Now()
+	notification.ReadAt = &now
+	if err := conn(ctx, r.db).Model(&notification).Update("read_at", now).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &notification, nil
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result := conn(ctx, r.db).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return result.RowsAffected, translateError(result.Error)
+}
+
+// IsEnabled reports whether the user wants notifications of this type; types
+// without a stored preference are enabled.
+func (r *notificationRepository) IsEnabled(ctx context.Context, userID uuid.UUID, notificationType string) (bool, error) {
+	var preference models.NotificationPreference
+	err := conn(ctx, r.db).Where("user_id = ? AND type = ?", userID, notificationType).First(&preference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, translateError(err)
+	}
+	return preference.Enabled, nil
+}
+
+func (r *notificationRepository) SavePreference(ctx context.Context, preference *models.NotificationPreference) error {
+	preference.UpdatedAt = time.Now()
+	return translateError(conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(preference).Error)
+}
+
+func (r *notificationRepository) FindPreferences(ctx context.Context, userID uuid.UUID) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Find(&preferences).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return preferences, nil
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrPresignUnsupported is returned by stores clients cannot reach directly.
+var ErrPresignUnsupported = errors.New("presigned requests are not supported by this object store")
+
+// ErrInvalidRange is returned by Get for a range that ends before it starts
+// or starts past the end of the object.
+var ErrInvalidRange = errors.New("invalid byte range")
+
+// ObjectStore keeps picture files and whatever is derived from them, keyed
+// by slash separated paths.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// Get returns ErrNotFound when there is no object under key. A range
+	// limits the body to those bytes; Info still describes the whole object.
+	Get(ctx context.Context, key string, byteRange *ByteRange) (*Object, error)
+	// Head returns ErrNotFound when there is no object under key.
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete ignores keys with no object under them.
+	Delete(ctx context.Context, keys ...string) error
+	// List calls fn with the keys of objects last modified before the
+	// cutoff, one page at a time.
+	List(ctx context.Context, before time.Time, fn func(keys []string) error) error
+	// PresignPut signs a PUT of exactly size bytes of contentType, or
+	// returns ErrPresignUnsupported.
+	PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedRequest, error)
+}
+
+// ObjectInfo is what the store reports about an object without reading it.
+// ETag is a quoted checksum of the content, the MD5 S3 reports for objects
+// put in one part.
+type ObjectInfo struct {
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+	ETag         string
+}
+
+type Object struct {
+	Body io.ReadCloser
+	Info ObjectInfo
+}
+
+// ByteRange selects bytes Start to End inclusive. A negative End reads to the
+// end of the object.
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+func (r ByteRange) validate() error {
+	if r.Start < 0 || (r.End >= 0 && r.End < r.Start) {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// bounds returns the inclusive offsets the range covers of an object of size
+// bytes, cutting an End past the object short.
+func (r ByteRange) bounds(size int64) (start, end int64, err error) {
+	if err := r.validate(); err != nil {
+		return 0, 0, err
+	}
+	if r.Start >= size {
+		return 0, 0, ErrInvalidRange
+	}
+	end = r.End
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	return r.Start, end, nil
+}
+
+// PresignedRequest is a request a client may send to the store on its own
+// until it expires. Header holds the headers that were signed, which the
+// client must send unchanged.
+type PresignedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+}
+
+// ContentETag is the ETag an object holding data is reported with.
+func ContentETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func readETag(r io.Reader) (string, error) {
+	hash := md5.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// pageSize is how many keys List hands to its callback at once, as S3 does.
+const pageSize = 1000
+
+func listPages(keys []string, fn func(keys []string) error) error {
+	for start := 0; start < len(keys); start += pageSize {
+		if err := fn(keys[start:min(start+pageSize, len(keys))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
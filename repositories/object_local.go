@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type localObjectStore struct {
+	root string
+}
+
+// checksumPrefix names the file kept beside each object with its ETag, so
+// the object is not hashed every time it is asked about.
+const checksumPrefix = ".etag-"
+
+// NewLocalObjectStore keeps objects as files under root. Content types are
+// derived from the key's extension and ETags are kept in a hidden file next
+// to each object.
+func NewLocalObjectStore(root string) (ObjectStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+	return &localObjectStore{root: root}, nil
+}
+
+// file maps a key to its path under root, refusing keys that would escape it.
+func (s *localObjectStore) file(key string) (string, error) {
+	if key == "" || !fs.ValidPath(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *localObjectStore) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	hash := md5.New()
+	if err := writeFile(name, io.TeeReader(body, hash)); err != nil {
+		return err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	return writeFile(checksumFile(name), strings.NewReader(etag))
+}
+
+// writeFile replaces the file at name with body. Readers never see a partly
+// written file: it is written aside and renamed into place.
+func writeFile(name string, body io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func checksumFile(name string) string {
+	return filepath.Join(filepath.Dir(name), checksumPrefix+filepath.Base(name))
+}
+
+func (s *localObjectStore) Get(ctx context.Context, key string, byteRange *ByteRange) (*Object, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.info(key, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	object := &Object{Body: file, Info: *info}
+	if byteRange != nil {
+		start, end, err := byteRange.bounds(info.Size)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		object.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.NewSectionReader(file, start, end-start+1), file}
+	}
+	return object, nil
+}
+
+func (s *localObjectStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return s.info(key, file)
+}
+
+func (s *localObjectStore) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		name, err := s.file(key)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{name, checksumFile(name)} {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *localObjectStore) List(ctx context.Context, before time.Time, fn func(keys []string) error) error {
+	var keys []string
+	err := filepath.WalkDir(s.root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Uploads in progress and checksums are not objects.
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !stat.ModTime().Before(before) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return listPages(keys, fn)
+}
+
+func (s *localObjectStore) PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	return nil, ErrPresignUnsupported
+}
+
+// info describes the open file stored under key, leaving it read from the
+// start.
+func (s *localObjectStore) info(key string, file *os.File) (*ObjectInfo, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	etag, err := s.checksum(file, stat)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &ObjectInfo{Size: stat.Size(), ContentType: contentType, LastModified: stat.ModTime(), ETag: etag}, nil
+}
+
+// checksum returns the ETag kept for the open file. Files without one, or
+// written since it was kept, are hashed and the ETag kept for next time.
+func (s *localObjectStore) checksum(file *os.File, stat fs.FileInfo) (string, error) {
+	name := checksumFile(file.Name())
+	if kept, err := os.Stat(name); err == nil && !kept.ModTime().Before(stat.ModTime()) {
+		if etag, err := os.ReadFile(name); err == nil && len(etag) == 2+2*md5.Size {
+			return string(etag), nil
+		}
+	}
+
+	etag, err := readETag(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	// Failing to keep the ETag only means hashing again next time.
+	_ = writeFile(name, strings.NewReader(etag))
+	return etag, nil
+}
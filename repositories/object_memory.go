@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+	etag        string
+}
+
+type memoryObjectStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemoryObjectStore keeps objects in this process until it exits, for
+// tests and trying the app out.
+func NewMemoryObjectStore() ObjectStore {
+	return &memoryObjectStore{objects: make(map[string]memoryObject)}
+}
+
+func (s *memoryObjectStore) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryObject{data: data, contentType: contentType, modified: time.Now(), etag: ContentETag(data)}
+	return nil
+}
+
+func (s *memoryObjectStore) Get(ctx context.Context, key string, byteRange *ByteRange) (*Object, error) {
+	s.mu.RLock()
+	object, ok := s.objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Stored slices are never written to again, so readers can share them.
+	data := object.data
+	if byteRange != nil {
+		start, end, err := byteRange.bounds(int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = data[start : end+1]
+	}
+	return &Object{Body: io.NopCloser(bytes.NewReader(data)), Info: object.info()}, nil
+}
+
+func (s *memoryObjectStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	s.mu.RLock()
+	object, ok := s.objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	info := object.info()
+	return &info, nil
+}
+
+func (s *memoryObjectStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.objects, key)
+	}
+	return nil
+}
+
+func (s *memoryObjectStore) List(ctx context.Context, before time.Time, fn func(keys []string) error) error {
+	s.mu.RLock()
+	var keys []string
+	for key, object := range s.objects {
+		if object.modified.Before(before) {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(keys)
+	return listPages(keys, fn)
+}
+
+func (s *memoryObjectStore) PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	return nil, ErrPresignUnsupported
+}
+
+func (o memoryObject) info() ObjectInfo {
+	return ObjectInfo{Size: int64(len(o.data)), ContentType: o.contentType, LastModified: o.modified, ETag: o.etag}
+}
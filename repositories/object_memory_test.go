@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMemoryObjectStoreRanges(t *testing.T) {
+	store := NewMemoryObjectStore()
+	ctx := context.Background()
+	if err := store.Put(ctx, "picture.png", strings.NewReader("0123456789"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	for _, test := range []struct {
+		byteRange ByteRange
+		want      string
+	}{
+		{ByteRange{Start: 0, End: 3}, "0123"},
+		{ByteRange{Start: 7, End: -1}, "789"},
+		{ByteRange{Start: 8, End: 100}, "89"},
+		{ByteRange{Start: 9, End: 9}, "9"},
+	} {
+		object, err := store.Get(ctx, "picture.png", &test.byteRange)
+		if err != nil {
+			t.Errorf("Get(%+v): %v", test.byteRange, err)
+			continue
+		}
+		body, _ := io.ReadAll(object.Body)
+		if string(body) != test.want {
+			t.Errorf("Get(%+v) = %q, want %q", test.byteRange, body, test.want)
+		}
+		if object.Info.Size != 10 {
+			t.Errorf("Get(%+v) reports %d bytes, want 10", test.byteRange, object.Info.Size)
+		}
+	}
+
+	for _, byteRange := range []ByteRange{
+		{Start: 5, End: 2},
+		{Start: 10, End: -1},
+		{Start: 12, End: 20},
+		{Start: -1, End: 3},
+	} {
+		if _, err := store.Get(ctx, "picture.png", &byteRange); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("Get(%+v) = %v, want ErrInvalidRange", byteRange, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type s3ObjectStore struct {
+	client    *s3.Client
+	presigner *s3.PresignClient
+	bucket    string
+}
+
+// NewS3ObjectStore keeps objects in an S3 bucket, such as MinIO's.
+func NewS3ObjectStore(client *s3.Client, presigner *s3.PresignClient, bucket string) ObjectStore {
+	return &s3ObjectStore{client: client, presigner: presigner, bucket: bucket}
+}
+
+func (s *s3ObjectStore) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+func (s *s3ObjectStore) Get(ctx context.Context, key string, byteRange *ByteRange) (*Object, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if byteRange != nil {
+		if err := byteRange.validate(); err != nil {
+			return nil, err
+		}
+		if byteRange.End < 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", byteRange.Start))
+		} else {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", byteRange.Start, byteRange.End))
+		}
+	}
+
+	output, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		// S3 has no modelled error for a range past the end of the object.
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+			return nil, ErrInvalidRange
+		}
+		return nil, err
+	}
+
+	info := ObjectInfo{
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		LastModified: aws.ToTime(output.LastModified),
+		ETag:         aws.ToString(output.ETag),
+	}
+	// A ranged response is only as long as the range; the whole size is at
+	// the end of Content-Range, as in "bytes 0-99/1234".
+	if output.ContentRange != nil {
+		var start, end int64
+		fmt.Sscanf(*output.ContentRange, "bytes %d-%d/%d", &start, &end, &info.Size)
+	}
+	return &Object{Body: output.Body, Info: info}, nil
+}
+
+func (s *s3ObjectStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	output, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		// HEAD responses have no body, so a missing key is only a 404.
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &ObjectInfo{
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		LastModified: aws.ToTime(output.LastModified),
+		ETag:         aws.ToString(output.ETag),
+	}, nil
+}
+
+func (s *s3ObjectStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+	_, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	return err
+}
+
+func (s *s3ObjectStore) List(ctx context.Context, before time.Time, fn func(keys []string) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			if object.LastModified != nil && object.LastModified.Before(before) {
+				keys = append(keys, aws.ToString(object.Key))
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *s3ObjectStore) PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	request, err := s.presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, err
+	}
+	header := request.SignedHeader.Clone()
+	// The client's HTTP library sets Host from the URL itself.
+	header.Del("Host")
+	return &PresignedRequest{Method: request.Method, URL: request.URL, Header: header}, nil
+}
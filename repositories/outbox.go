@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// outboxRelayLock is the advisory lock key held by a replica while it claims
+// a batch, so that two replicas never claim the same events.
+const outboxRelayLock = 7_316_825_401
+
+type OutboxRepository interface {
+	Add(ctx context.Context, event *models.OutboxEvent) error
+	WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+	FindUnpublished(ctx context.Context, createdBefore time.Time, limit int) ([]models.OutboxEvent, error)
+	Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error
+	Release(ctx context.Context, ids []uuid.UUID) error
+	MarkPublished(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, message string, retryAt time.Time) error
+}
+
+type outboxRepository struct {
+	db         *gorm.DB
+	transactor Transactor
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db, transactor: NewTransactor(db)}
+}
+
+// Add writes the event with the transaction carried by ctx, so it is only
+// visible to the relay if the surrounding domain change commits. CreatedAt
+// is when the row was written, not when the transaction began.
+func (r *outboxRepository) Add(ctx context.Context, event *models.OutboxEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	now := time.Now()
+	if event.NextAttemptAt.IsZero() {
+		event.NextAttemptAt = now
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = now
+	}
+	return translateError(conn(ctx, r.db).Omit("sequence").Create(event).Error)
+}
+
+// WithRelayLock runs fn in a transaction holding the relay advisory lock. It
+// reports false without running fn when another replica holds the lock.
+func (r *outboxRepository) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	acquired := false
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := conn(ctx, r.db).Raw("SELECT pg_try_advisory_xact_lock(?)", outboxRelayLock).Scan(&acquired).Error; err != nil {
+			return translateError(err)
+		}
+		if !acquired {
+			return nil
+		}
+		return fn(ctx)
+	})
+	return acquired, err
+}
+
+// FindUnpublished returns unpublished events written before createdBefore,
+// claimed or not, in order.
+func (r *outboxRepository) FindUnpublished(ctx context.Context, createdBefore time.Time, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := conn(ctx, r.db).
+		Where("published_at IS NULL AND created_at < ?", createdBefore).
+		Order("sequence").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return events, nil
+}
+
+// Claim marks events as being published until the given time, after which
+// another relay may take them over.
+func (r *outboxRepository) Claim(ctx context.Context, ids []uuid.UUID, until time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return translateError(conn(ctx, r.db).Model(&models.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("claimed_until", until).Error)
+}
+
+// Release gives up claimed events that were not published.
+func (r *outboxRepository) Release(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return translateError(conn(ctx, r.db).Model(&models.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("claimed_until", nil).Error)
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	return translateError(conn(ctx, r.db).Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"published_at":  time.Now(),
+			"attempts":      gorm.Expr("attempts + 1"),
+			"last_error":    "",
+			"claimed_until": nil,
+		}).Error)
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, message string, retryAt time.Time) error {
+	return translateError(conn(ctx, r.db).Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      message,
+			"next_attempt_at": retryAt,
+			"claimed_until":   nil,
+		}).Error)
+}
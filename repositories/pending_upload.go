@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PendingUploadRepository interface {
+	Create(ctx context.Context, upload *models.PendingUpload) error
+	FindByID(ctx context.Context, id uuid.UUID) (*models.PendingUpload, error)
+	// Delete returns ErrNotFound when the upload was already confirmed or
+	// expired, so only one caller gets to act on it.
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindExpired(ctx context.Context, now time.Time, limit int) ([]models.PendingUpload, error)
+}
+
+type pendingUploadRepository struct {
+	db *gorm.DB
+}
+
+func NewPendingUploadRepository(db *gorm.DB) PendingUploadRepository {
+	return &pendingUploadRepository{db: db}
+}
+
+func (r *pendingUploadRepository) Create(ctx context.Context, upload *models.PendingUpload) error {
+	if upload.ID == uuid.Nil {
+		upload.ID = uuid.New()
+	}
+	return translateError(conn(ctx, r.db).Create(upload).Error)
+}
+
+func (r *pendingUploadRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.PendingUpload, error) {
+	var upload models.PendingUpload
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&upload).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &upload, nil
+}
+
+func (r *pendingUploadRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&models.PendingUpload{})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pendingUploadRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]models.PendingUpload, error) {
+	var uploads []models.PendingUpload
+	err := conn(ctx, r.db).
+		Where("expires_at <= ?", now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, translateError(err)
+}
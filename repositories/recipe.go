@@ -1,13 +1,29 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
+
 	"app/models"
+
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RecipeRepository interface {
-	SaveRecipePicture(picture models.RecipePicture) error
-	FindRecipePictureByID(id string) (*models.RecipePicture, error)
+	SaveRecipePicture(ctx context.Context, picture *models.RecipePicture) error
+	FindRecipePictureByID(ctx context.Context, id string) (*models.RecipePicture, error)
+	FindRecipePictures(ctx context.Context, recipeID string) ([]models.RecipePicture, error)
+	DeleteRecipePicture(ctx context.Context, id string) error
+	SetRecipePicturePositions(ctx context.Context, recipeID string, pictureIDs []uuid.UUID) error
+	SetRecipeThumbnail(ctx context.Context, recipeID string, pictureID *uuid.UUID) error
+	FindRecipeStepByID(ctx context.Context, id string) (*models.RecipeStep, error)
+	SetRecipeStepPicture(ctx context.Context, stepID string, pictureID *uuid.UUID) error
+	FindRecipeByID(ctx context.Context, id string) (*models.Recipe, error)
+	LockRecipe(ctx context.Context, id string) error
+	FindRecipeIngredients(ctx context.Context, recipeID string) ([]models.RecipeIngredient, error)
+	FindRecommendedRecipes(ctx context.Context, userID string, limit int) ([]models.RecipeRecommendation, error)
 }
 
 type recipeRepository struct {
@@ -18,17 +34,186 @@ func NewRecipeRepository(db *gorm.DB) RecipeRepository {
 	return &recipeRepository{db: db}
 }
 
-func (r *recipeRepository) SaveRecipePicture(picture models.RecipePicture) error {
-	return r.db.Create(&picture).Error
+// SaveRecipePicture adds a picture at the end of the recipe's gallery. The
+// recipe row is locked first so pictures added at the same time are given
+// different positions.
+func (r *recipeRepository) SaveRecipePicture(ctx context.Context, picture *models.RecipePicture) error {
+	return translateError(conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := lockRecipe(tx, picture.RecipeId.String()); err != nil {
+			return err
+		}
+		err := tx.Model(&models.RecipePicture{}).
+			Select("COALESCE(MAX(position) + 1, 0)").
+			Where("recipe_id = ?", picture.RecipeId).
+			Scan(&picture.Position).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(picture).Error
+	}))
+}
+
+// LockRecipe locks the recipe row until the transaction carried by ctx ends,
+// serializing changes to its gallery.
+func (r *recipeRepository) LockRecipe(ctx context.Context, id string) error {
+	return translateError(lockRecipe(conn(ctx, r.db), id))
+}
+
+func lockRecipe(tx *gorm.DB, id string) error {
+	var recipe models.Recipe
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", id).
+		Take(&recipe).Error
 }
 
-func (r *recipeRepository) FindRecipePictureByID(id string) (*models.RecipePicture, error) {
+func (r *recipeRepository) FindRecipePictureByID(ctx context.Context, id string) (*models.RecipePicture, error) {
 	var picture models.RecipePicture
-	err := r.db.Joins("JOIN recipe ON recipe.id = recipe_picture.recipe_id").
+	err := conn(ctx, r.db).Joins("JOIN recipe ON recipe.id = recipe_picture.recipe_id").
 		Where("recipe_picture.id = ?", id).
 		First(&picture).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &picture, nil
 }
+
+// FindRecipePictures returns the recipe's gallery in order.
+func (r *recipeRepository) FindRecipePictures(ctx context.Context, recipeID string) ([]models.RecipePicture, error) {
+	var pictures []models.RecipePicture
+	err := conn(ctx, r.db).
+		Where("recipe_id = ?", recipeID).
+		Order("position, created_at").
+		Find(&pictures).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return pictures, nil
+}
+
+// DeleteRecipePicture removes a picture along with the recipe thumbnail and
+// step references to it.
+func (r *recipeRepository) DeleteRecipePicture(ctx context.Context, id string) error {
+	db := conn(ctx, r.db)
+	err := db.Model(&models.Recipe{}).Unscoped().
+		Where("thumbnail_id = ?", id).
+		Update("thumbnail_id", nil).Error
+	if err != nil {
+		return translateError(err)
+	}
+	err = db.Model(&models.RecipeStep{}).
+		Where("picture_id = ?", id).
+		Update("picture_id", nil).Error
+	if err != nil {
+		return translateError(err)
+	}
+
+	result := db.Where("id = ?", id).Delete(&models.RecipePicture{})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetRecipePicturePositions numbers the given pictures of a recipe in order.
+func (r *recipeRepository) SetRecipePicturePositions(ctx context.Context, recipeID string, pictureIDs []uuid.UUID) error {
+	db := conn(ctx, r.db)
+	for position, pictureID := range pictureIDs {
+		err := db.Model(&models.RecipePicture{}).
+			Where("id = ? AND recipe_id = ?", pictureID, recipeID).
+			Update("position", position).Error
+		if err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+func (r *recipeRepository) SetRecipeThumbnail(ctx context.Context, recipeID string, pictureID *uuid.UUID) error {
+	result := conn(ctx, r.db).Model(&models.Recipe{}).
+		Where("id = ?", recipeID).
+		Update("thumbnail_id", pictureID)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *recipeRepository) FindRecipeStepByID(ctx context.Context, id string) (*models.RecipeStep, error) {
+	var step models.RecipeStep
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&step).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &step, nil
+}
+
+func (r *recipeRepository) SetRecipeStepPicture(ctx context.Context, stepID string, pictureID *uuid.UUID) error {
+	result := conn(ctx, r.db).Model(&models.RecipeStep{}).
+		Where("id = ?", stepID).
+		Update("picture_id", pictureID)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *recipeRepository) FindRecipeByID(ctx context.Context, id string) (*models.Recipe, error) {
+	var recipe models.Recipe
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&recipe).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &recipe, nil
+}
+
+func (r *recipeRepository) FindRecipeIngredients(ctx context.Context, recipeID string) ([]models.RecipeIngredient, error) {
+	var ingredients []models.RecipeIngredient
+	err := conn(ctx, r.db).Preload("Ingredient").
+		Where("recipe_id = ?", recipeID).
+		Order("created_at").
+		Find(&ingredients).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return ingredients, nil
+}
+
+// FindRecommendedRecipes ranks recipes by popularity, boosting the categories
+// the user has liked before and leaving out their own and already liked ones.
+func (r *recipeRepository) FindRecommendedRecipes(ctx context.Context, userID string, limit int) ([]models.RecipeRecommendation, error) {
+	var recommendations []models.RecipeRecommendation
+	err := conn(ctx, r.db).Raw(`
+		WITH preferred AS (
+			SELECT DISTINCT liked.category_id
+			FROM liked_recipe
+			JOIN recipe liked ON liked.id = liked_recipe.recipe_id
+			WHERE liked_recipe.user_id = @user
+		)
+		SELECT recipe.id AS recipe_id,
+			(recipe.like_count + recipe.rating_count * recipe.average_rating)
+				* CASE WHEN preferred.category_id IS NULL THEN 1 ELSE 2 END AS score,
+			CASE WHEN preferred.category_id IS NULL THEN 'popular' ELSE 'similar_to_liked' END AS reason
+		FROM recipe
+		LEFT JOIN preferred ON preferred.category_id = recipe.category_id
+		WHERE recipe.deleted_at IS NULL
+			AND recipe.creator_id <> @user
+			AND NOT EXISTS (
+				SELECT 1 FROM liked_recipe WHERE liked_recipe.recipe_id = recipe.id AND liked_recipe.user_id = @user
+			)
+		ORDER BY score DESC, recipe.created_at DESC
+		LIMIT @limit`,
+		sql.Named("user", userID), sql.Named("limit", limit),
+	).Scan(&recommendations).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return recommendations, nil
+}
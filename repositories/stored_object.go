@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StoredObjectRepository interface {
+	// Claim records an object about to be referenced, or locks the existing
+	// record until the transaction ends. It returns how many pictures
+	// referred to the object before.
+	Claim(ctx context.Context, object *models.StoredObject) (int, error)
+	// LockUnreferenced returns ErrNotFound unless key is recorded with no
+	// references, and locks the record until the transaction ends.
+	LockUnreferenced(ctx context.Context, key string) (*models.StoredObject, error)
+	Delete(ctx context.Context, key string) error
+	FindUnreferenced(ctx context.Context, before time.Time, limit int) ([]models.StoredObject, error)
+	FindExistingKeys(ctx context.Context, keys []string) ([]string, error)
+}
+
+type storedObjectRepository struct {
+	db *gorm.DB
+}
+
+func NewStoredObjectRepository(db *gorm.DB) StoredObjectRepository {
+	return &storedObjectRepository{db: db}
+}
+
+func (r *storedObjectRepository) Claim(ctx context.Context, object *models.StoredObject) (int, error) {
+	var refs int
+	// Updating a conflicting row locks it, which a plain DO NOTHING would not.
+	err := conn(ctx, r.db).Raw(`
+		INSERT INTO stored_object (key, content_type, size)
+		VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+		RETURNING ref_count`,
+		object.Key, object.ContentType, object.Size,
+	).Scan(&refs).Error
+	return refs, translateError(err)
+}
+
+func (r *storedObjectRepository) LockUnreferenced(ctx context.Context, key string) (*models.StoredObject, error) {
+	var object models.StoredObject
+	err := conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("key = ? AND ref_count = 0", key).
+		First(&object).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &object, nil
+}
+
+func (r *storedObjectRepository) Delete(ctx context.Context, key string) error {
+	return translateError(conn(ctx, r.db).Where("key = ?", key).Delete(&models.StoredObject{}).Error)
+}
+
+// FindUnreferenced returns objects nothing has referred to since the cutoff.
+func (r *storedObjectRepository) FindUnreferenced(ctx context.Context, before time.Time, limit int) ([]models.StoredObject, error) {
+	var objects []models.StoredObject
+	err := conn(ctx, r.db).
+		Where("ref_count = 0 AND updated_at < ?", before).
+		Order("updated_at").
+		Limit(limit).
+		Find(&objects).Error
+	return objects, translateError(err)
+}
+
+func (r *storedObjectRepository) FindExistingKeys(ctx context.Context, keys []string) ([]string, error) {
+	var existing []string
+	err := conn(ctx, r.db).Model(&models.StoredObject{}).Where("key IN ?", keys).Pluck("key", &existing).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return existing, nil
+}
@@ -1,14 +1,19 @@
 package repositories
 
 import (
+	"context"
+
 	"app/models"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
-	Create(user *models.User) error
-	FindByUsername(username string) (*models.User, error)
-	FindByID(id string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword string) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role string) error
 }
 
 type userRepository struct {
@@ -19,22 +24,46 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return translateError(conn(ctx, r.db).Create(user).Error)
 }
 
-func (r *userRepository) FindByUsername(username string) (*models.User, error) {
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	if err := r.db.Where("username = ? AND deleted_at IS NULL", username).First(&user).Error; err != nil {
-		return nil, err
+	if err := conn(ctx, r.db).Where("username = ? AND deleted_at IS NULL", username).First(&user).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return &user, nil
 }
 
-func (r *userRepository) FindByID(id string) (*models.User, error) {
+func (r *userRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
 	var user models.User
-	if err := r.db.Where("id = ? AND deleted_at IS NULL", id).First(&user).Error; err != nil {
-		return nil, err
+	if err := conn(ctx, r.db).Where("id = ? AND deleted_at IS NULL", id).First(&user).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return &user, nil
 }
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	return r.update(ctx, id, map[string]any{"password": hashedPassword})
+}
+
+// UpdateRole also bumps the token version, revoking the tokens that carry the
+// previous role.
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role string) error {
+	return r.update(ctx, id, map[string]any{
+		"role":          role,
+		"token_version": gorm.Expr("token_version + 1"),
+	})
+}
+
+func (r *userRepository) update(ctx context.Context, id uuid.UUID, values map[string]any) error {
+	result := conn(ctx, r.db).Model(&models.User{}).Where("id = ? AND deleted_at IS NULL", id).Updates(values)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
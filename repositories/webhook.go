@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"app/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	FindEndpointByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, ownerID *uuid.UUID) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+	FindSubscribedEndpoints(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error)
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	FindDeliveryByID(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ResetDeadDelivery(ctx context.Context, id uuid.UUID) (bool, error)
+	ListDeliveries(ctx context.Context, endpointID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	if endpoint.ID == uuid.Nil {
+		endpoint.ID = uuid.New()
+	}
+	return translateError(conn(ctx, r.db).Create(endpoint).Error)
+}
+
+func (r *webhookRepository) FindEndpointByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&endpoint).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &endpoint, nil
+}
+
+// ListEndpoints returns the endpoints of one owner, or every endpoint when
+// ownerID is nil.
+func (r *webhookRepository) ListEndpoints(ctx context.Context, ownerID *uuid.UUID) ([]models.WebhookEndpoint, error) {
+	query := conn(ctx, r.db).Order("created_at")
+	if ownerID != nil {
+		query = query.Where("owner_id = ?", *ownerID)
+	}
+
+	var endpoints []models.WebhookEndpoint
+	if err := query.Find(&endpoints).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return endpoints, nil
+}
+
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&models.WebhookEndpoint{})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *webhookRepository) FindSubscribedEndpoints(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error) {
+	filter, err := json.Marshal([]string{eventType})
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []models.WebhookEndpoint
+	err = conn(ctx, r.db).
+		Where("active AND event_types @> ?::jsonb", string(filter)).
+		Find(&endpoints).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return endpoints, nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	if delivery.Status == "" {
+		delivery.Status = models.WebhookDeliveryPending
+	}
+	return translateError(conn(ctx, r.db).Create(delivery).Error)
+}
+
+func (r *webhookRepository) FindDeliveryByID(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&delivery).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return translateError(conn(ctx, r.db).Save(delivery).Error)
+}
+
+// ResetDeadDelivery puts a dead delivery back to pending with no attempts. It
+// reports false when the delivery was not dead, so only one caller wins.
+func (r *webhookRepository) ResetDeadDelivery(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ?", id, models.WebhookDeliveryDead).
+		Updates(map[string]any{"status": models.WebhookDeliveryPending, "attempts": 0})
+	if result.Error != nil {
+		return false, translateError(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, endpointID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	query := conn(ctx, r.db).Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"app/models"
+	"app/repositories"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type AuditLogFilter = repositories.AuditLogFilter
+
+// AuditEntry describes one audited operation. Before and After are the
+// entity's state around it, as structs, maps or raw JSON objects; either may
+// be nil.
+type AuditEntry struct {
+	ActorID    *uuid.UUID
+	ActorRole  string
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     any
+	After      any
+}
+
+type AuditFieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// redactedFields never reach the audit log in clear.
+var redactedFields = []string{"password"}
+
+// unauditedFields change on every write and would only add noise to diffs.
+var unauditedFields = []string{"updated_at", "search_vector"}
+
+type Auditor interface {
+	Record(ctx context.Context, entry AuditEntry) error
+	Query(ctx context.Context, caller Caller, filter AuditLogFilter, limit, offset int) ([]models.AuditLog, int64, error)
+}
+
+type auditor struct {
+	repository repositories.AuditLogRepository
+}
+
+func NewAuditor(repository repositories.AuditLogRepository) Auditor {
+	return &auditor{repository: repository}
+}
+
+// Record appends the entry, with the client IP and user agent carried by ctx
+// and a field-level diff of Before and After.
+func (a *auditor) Record(ctx context.Context, entry AuditEntry) error {
+	before, err := auditState(entry.Before)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	after, err := auditState(entry.After)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit state: %w", err)
+	}
+
+	info := utils.RequestInfoFromContext(ctx)
+	log := &models.AuditLog{
+		ActorID:    entry.ActorID,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+	}
+	if log.Before, err = encodeAuditJSON(before); err != nil {
+		return fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	if log.After, err = encodeAuditJSON(after); err != nil {
+		return fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	if log.Changes, err = encodeAuditJSON(auditDiff(before, after)); err != nil {
+		return fmt.Errorf("failed to encode audit diff: %w", err)
+	}
+
+	if err := a.repository.Append(ctx, log); err != nil {
+		return fmt.Errorf("failed to append audit log: %w", err)
+	}
+	return nil
+}
+
+func (a *auditor) Query(ctx context.Context, caller Caller, filter AuditLogFilter, limit, offset int) ([]models.AuditLog, int64, error) {
+	if !caller.Admin {
+		return nil, 0, ErrForbidden
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	entries, total, err := a.repository.Query(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	return entries, total, nil
+}
+
+// auditState turns a state into a JSON object with sensitive fields redacted.
+func auditState(state any) (map[string]any, error) {
+	if state == nil {
+		return nil, nil
+	}
+	raw, ok := state.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(state); err != nil {
+			return nil, err
+		}
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range redactedFields {
+		if _, ok := fields[field]; ok {
+			fields[field] = "[redacted]"
+		}
+	}
+	return fields, nil
+}
+
+func auditDiff(before, after map[string]any) map[string]AuditFieldChange {
+	changes := make(map[string]AuditFieldChange)
+	for field, value := range after {
+		if previous, ok := before[field]; !ok || !reflect.DeepEqual(previous, value) {
+			changes[field] = AuditFieldChange{Before: before[field], After: value}
+		}
+	}
+	for field, previous := range before {
+		if _, ok := after[field]; !ok {
+			changes[field] = AuditFieldChange{Before: previous}
+		}
+	}
+	for _, field := range unauditedFields {
+		delete(changes, field)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func encodeAuditJSON(value any) (json.RawMessage, error) {
+	if reflect.ValueOf(value).IsNil() {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAuditStateRedactsPasswords(t *testing.T) {
+	state, err := auditState(json.RawMessage(`{"username":"ada","password":"$2a$10$hash"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state["password"] != "[redacted]" || state["username"] != "ada" {
+		t.Errorf("state = %v", state)
+	}
+
+	if state, err := auditState(json.RawMessage("null")); err != nil || state != nil {
+		t.Errorf("null state = %v, %v; want nil", state, err)
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := map[string]any{"title": "Soup", "servings": 2.0, "tags": []any{"a"}, "updated_at": "yesterday", "draft": true}
+	after := map[string]any{"title": "Soup", "servings": 4.0, "tags": []any{"a"}, "updated_at": "today", "notes": "salt"}
+
+	changes := auditDiff(before, after)
+	want := map[string]AuditFieldChange{
+		"servings": {Before: 2.0, After: 4.0},
+		"notes":    {After: "salt"},
+		"draft":    {Before: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for field, change := range want {
+		if changes[field] != change {
+			t.Errorf("%s changed %v, want %v", field, changes[field], change)
+		}
+	}
+
+	if changes := auditDiff(before, before); changes != nil {
+		t.Errorf("an unchanged state has changes %v", changes)
+	}
+	if changes := auditDiff(nil, map[string]any{"title": "Soup"}); changes["title"].After != "Soup" {
+		t.Errorf("a created entity has changes %v", changes)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+var ErrReplayFailed = errors.New("dead letter replay failed")
+
+type DeadLetterFilter = repositories.DeadLetterFilter
+
+type DeadLetterDepth = repositories.DeadLetterDepth
+
+// DeadLetterReplayer redelivers the dead letters of one source. Replayers
+// live with the code that consumed the payload in the first place.
+type DeadLetterReplayer func(ctx context.Context, letter *models.DeadLetter) error
+
+type DeadLetterService interface {
+	RegisterReplayer(source string, replayer DeadLetterReplayer)
+	List(ctx context.Context, caller Caller, filter DeadLetterFilter, limit, offset int) ([]models.DeadLetter, int64, error)
+	Get(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error)
+	Replay(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error)
+	Discard(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error)
+	Depth(ctx context.Context) ([]DeadLetterDepth, error)
+}
+
+type deadLetterService struct {
+	repository repositories.DeadLetterRepository
+	replayers  map[string]DeadLetterReplayer
+}
+
+func NewDeadLetterService(repository repositories.DeadLetterRepository) DeadLetterService {
+	return &deadLetterService{
+		repository: repository,
+		replayers:  make(map[string]DeadLetterReplayer),
+	}
+}
+
+func (s *deadLetterService) RegisterReplayer(source string, replayer DeadLetterReplayer) {
+	s.replayers[source] = replayer
+}
+
+func (s *deadLetterService) List(ctx context.Context, caller Caller, filter DeadLetterFilter, limit, offset int) ([]models.DeadLetter, int64, error) {
+	if !caller.Admin {
+		return nil, 0, ErrForbidden
+	}
+	if filter.Source != "" && !slices.Contains(models.DeadLetterSources, filter.Source) {
+		return nil, 0, &ValidationError{Field: "source", Message: "must be event or webhook"}
+	}
+	if filter.Status != "" && !slices.Contains(models.DeadLetterStatuses, filter.Status) {
+		return nil, 0, &ValidationError{Field: "status", Message: "must be open, replaying, replayed or discarded"}
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	letters, total, err := s.repository.List(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return letters, total, nil
+}
+
+func (s *deadLetterService) Get(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error) {
+	if !caller.Admin {
+		return nil, ErrForbidden
+	}
+
+	letter, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dead letter: %w", err)
+	}
+	return letter, nil
+}
+
+// Replay hands an open dead letter back to its source's replayer. The letter
+// is claimed first, so a letter replayed twice at once is only replayed by
+// one of them. When the replay fails the letter reopens with the new error.
+func (s *deadLetterService) Replay(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error) {
+	letter, err := s.openLetter(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+	replayer, exists := s.replayers[letter.Source]
+	if !exists {
+		return nil, fmt.Errorf("no replayer for dead letter source %s", letter.Source)
+	}
+
+	claimed, err := s.repository.ClaimReplay(ctx, letter.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim dead letter: %w", err)
+	}
+	if !claimed {
+		return nil, fmt.Errorf("dead letter is already being replayed or resolved: %w", ErrConflict)
+	}
+	letter.Status = models.DeadLetterReplaying
+	letter.ReplayCount++
+
+	if replayErr := replayer(ctx, letter); replayErr != nil {
+		letter.Status = models.DeadLetterOpen
+		letter.Error = replayErr.Error()
+		if err := s.repository.Save(ctx, letter); err != nil {
+			return nil, fmt.Errorf("failed to save dead letter: %w", err)
+		}
+		return letter, fmt.Errorf("%w: %v", ErrReplayFailed, replayErr)
+	}
+
+	return letter, s.resolve(ctx, letter, models.DeadLetterReplayed)
+}
+
+func (s *deadLetterService) Discard(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error) {
+	letter, err := s.openLetter(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+	return letter, s.resolve(ctx, letter, models.DeadLetterDiscarded)
+}
+
+func (s *deadLetterService) Depth(ctx context.Context) ([]DeadLetterDepth, error) {
+	depths, err := s.repository.Depth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	return depths, nil
+}
+
+func (s *deadLetterService) openLetter(ctx context.Context, caller Caller, id uuid.UUID) (*models.DeadLetter, error) {
+	letter, err := s.Get(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+	if letter.Status != models.DeadLetterOpen {
+		return nil, fmt.Errorf("dead letter is already %s: %w", letter.Status, ErrConflict)
+	}
+	return letter, nil
+}
+
+func (s *deadLetterService) resolve(ctx context.Context, letter *models.DeadLetter, status string) error {
+	now := time.Now()
+	letter.Status = status
+	letter.ResolvedAt = &now
+	if err := s.repository.Save(ctx, letter); err != nil {
+		return fmt.Errorf("failed to save dead letter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+type fakeDeadLetterRepository struct {
+	repositories.DeadLetterRepository
+	letters []*models.DeadLetter
+}
+
+func (r *fakeDeadLetterRepository) Add(_ context.Context, letter *models.DeadLetter) error {
+	r.letters = append(r.letters, letter)
+	return nil
+}
+
+func (r *fakeDeadLetterRepository) FindByID(_ context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	for _, letter := range r.letters {
+		if letter.ID == id {
+			copied := *letter
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ClaimReplay moves an open letter to replaying, like the conditional update
+// the database does.
+func (r *fakeDeadLetterRepository) ClaimReplay(_ context.Context, id uuid.UUID) (bool, error) {
+	for _, letter := range r.letters {
+		if letter.ID == id && letter.Status == models.DeadLetterOpen {
+			letter.Status = models.DeadLetterReplaying
+			letter.ReplayCount++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeDeadLetterRepository) Save(_ context.Context, letter *models.DeadLetter) error {
+	for i, stored := range r.letters {
+		if stored.ID == letter.ID {
+			copied := *letter
+			r.letters[i] = &copied
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func newTestDeadLetter(repository *fakeDeadLetterRepository) *models.DeadLetter {
+	letter := &models.DeadLetter{ID: uuid.New(), Source: models.DeadLetterSourceEvent, Status: models.DeadLetterOpen, Error: "handler failed"}
+	repository.letters = append(repository.letters, letter)
+	return letter
+}
+
+func TestReplayClaimsLetterBeforeReplaying(t *testing.T) {
+	repository := &fakeDeadLetterRepository{}
+	service := NewDeadLetterService(repository)
+	letter := newTestDeadLetter(repository)
+	admin := Caller{Admin: true}
+
+	// A second replay arriving while the first runs must not run again.
+	var nested error
+	replays := 0
+	service.RegisterReplayer(models.DeadLetterSourceEvent, func(ctx context.Context, replayed *models.DeadLetter) error {
+		replays++
+		if stored, _ := repository.FindByID(ctx, replayed.ID); stored.Status != models.DeadLetterReplaying {
+			t.Errorf("replaying a letter that is %s, want it claimed", stored.Status)
+		}
+		_, nested = service.Replay(ctx, admin, replayed.ID)
+		return nil
+	})
+
+	replayed, err := service.Replay(context.Background(), admin, letter.ID)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replays != 1 || !errors.Is(nested, ErrConflict) {
+		t.Errorf("%d replays, concurrent replay err = %v; want one replay and a conflict", replays, nested)
+	}
+	if replayed.Status != models.DeadLetterReplayed || replayed.ResolvedAt == nil || replayed.ReplayCount != 1 {
+		t.Errorf("replayed letter = %+v", replayed)
+	}
+}
+
+func TestFailedReplayReopensLetter(t *testing.T) {
+	repository := &fakeDeadLetterRepository{}
+	service := NewDeadLetterService(repository)
+	letter := newTestDeadLetter(repository)
+	service.RegisterReplayer(models.DeadLetterSourceEvent, func(context.Context, *models.DeadLetter) error {
+		return errors.New("still failing")
+	})
+
+	_, err := service.Replay(context.Background(), Caller{Admin: true}, letter.ID)
+	if !errors.Is(err, ErrReplayFailed) {
+		t.Fatalf("err = %v, want ErrReplayFailed", err)
+	}
+	stored, _ := repository.FindByID(context.Background(), letter.ID)
+	if stored.Status != models.DeadLetterOpen || stored.Error != "still failing" {
+		t.Errorf("letter after a failed replay = %+v, want it open with the new error", stored)
+	}
+}
+
+func TestDeadLettersAreAdminOnly(t *testing.T) {
+	repository := &fakeDeadLetterRepository{}
+	service := NewDeadLetterService(repository)
+	letter := newTestDeadLetter(repository)
+
+	if _, err := service.Replay(context.Background(), Caller{UserID: uuid.New()}, letter.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("replay by a user: err = %v, want ErrForbidden", err)
+	}
+	if _, err := service.Discard(context.Background(), Caller{UserID: uuid.New()}, letter.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("discard by a user: err = %v, want ErrForbidden", err)
+	}
+	discarded, err := service.Discard(context.Background(), Caller{Admin: true}, letter.ID)
+	if err != nil || discarded.Status != models.DeadLetterDiscarded {
+		t.Errorf("discard = %+v, %v", discarded, err)
+	}
+	if _, err := service.Replay(context.Background(), Caller{Admin: true}, letter.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("replaying a discarded letter: err = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"errors"
+
+	"app/repositories"
+)
+
+var (
+	ErrNotFound           = repositories.ErrNotFound
+	ErrConflict           = repositories.ErrConflict
+	ErrInvalidReference   = repositories.ErrInvalidReference
+	ErrForbidden          = errors.New("forbidden")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrPresignUnsupported = repositories.ErrPresignUnsupported
+)
+
+type ConstraintError = repositories.ConstraintError
+
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	Recipient uuid.UUID
+	Subject   string
+	Body      string
+}
+
+// Mailer delivers messages to users. The log implementation stands in until
+// an email or push provider is configured.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+type logMailer struct{}
+
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(ctx context.Context, message Message) error {
+	log.Printf("Message to %s: %s", message.Recipient, message.Subject)
+	return nil
+}
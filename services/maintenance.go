@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"app/repositories"
+)
+
+// MaintenanceSettings are the retention windows used by scheduled jobs.
+type MaintenanceSettings struct {
+	SoftDeleteRetention  time.Duration
+	OrphanObjectGrace    time.Duration
+	TrendingWindow       time.Duration
+	EventLedgerRetention time.Duration
+}
+
+type PurgeResult struct {
+	Recipes  int64 `json:"recipes"`
+	Comments int64 `json:"comments"`
+}
+
+type ObjectCollectionResult struct {
+	Released int `json:"released"`
+	Scanned  int `json:"scanned"`
+	Deleted  int `json:"deleted"`
+}
+
+type MaintenanceService interface {
+	PurgeSoftDeleted(ctx context.Context) (PurgeResult, error)
+	ReconcileCounts(ctx context.Context) (int64, error)
+	CollectOrphanedObjects(ctx context.Context) (ObjectCollectionResult, error)
+	RecomputeTrendingScores(ctx context.Context) (int64, error)
+	PruneProcessedEvents(ctx context.Context) (int64, error)
+}
+
+type maintenanceService struct {
+	repository    repositories.MaintenanceRepository
+	storedObjects repositories.StoredObjectRepository
+	objects       repositories.ObjectStore
+	transactor    repositories.Transactor
+	eventLedger   repositories.EventLedgerRepository
+	settings      MaintenanceSettings
+}
+
+func NewMaintenanceService(
+	repository repositories.MaintenanceRepository,
+	storedObjects repositories.StoredObjectRepository,
+	objects repositories.ObjectStore,
+	transactor repositories.Transactor,
+	eventLedger repositories.EventLedgerRepository,
+	settings MaintenanceSettings,
+) MaintenanceService {
+	return &maintenanceService{
+		repository:    repository,
+		storedObjects: storedObjects,
+		objects:       objects,
+		transactor:    transactor,
+		eventLedger:   eventLedger,
+		settings:      settings,
+	}
+}
+
+func (s *maintenanceService) PurgeSoftDeleted(ctx context.Context) (PurgeResult, error) {
+	var result PurgeResult
+	before := time.Now().Add(-s.settings.SoftDeleteRetention)
+
+	var err error
+	if result.Recipes, err = s.repository.PurgeDeletedRecipes(ctx, before); err != nil {
+		return result, fmt.Errorf("failed to purge deleted recipes: %w", err)
+	}
+	if result.Comments, err = s.repository.PurgeDeletedComments(ctx, before); err != nil {
+		return result, fmt.Errorf("failed to purge deleted comments: %w", err)
+	}
+	return result, nil
+}
+
+func (s *maintenanceService) ReconcileCounts(ctx context.Context) (int64, error) {
+	fixed, err := s.repository.ReconcileRecipeCounts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reconcile recipe counts: %w", err)
+	}
+	return fixed, nil
+}
+
+// CollectOrphanedObjects first releases stored objects no picture has
+// referred to for the grace period, such as those of recipes purged with
+// their pictures. It then deletes objects that have no record and no picture
+// using them, along with the variants made from them. Objects younger than the grace period are
+// left alone, since an upload stores the object before it commits its
+// record.
+func (s *maintenanceService) CollectOrphanedObjects(ctx context.Context) (ObjectCollectionResult, error) {
+	var result ObjectCollectionResult
+	before := time.Now().Add(-s.settings.OrphanObjectGrace)
+
+	for {
+		unreferenced, err := s.storedObjects.FindUnreferenced(ctx, before, 100)
+		if err != nil {
+			return result, fmt.Errorf("failed to find unreferenced objects: %w", err)
+		}
+		if len(unreferenced) == 0 {
+			break
+		}
+		for _, object := range unreferenced {
+			removed, err := removeUnreferenced(ctx, s.transactor, s.storedObjects, s.objects, object.Key)
+			if err != nil {
+				return result, fmt.Errorf("failed to release unreferenced object: %w", err)
+			}
+			if removed {
+				result.Released++
+			}
+		}
+	}
+
+	err := s.objects.List(ctx, before, func(keys []string) error {
+		result.Scanned += len(keys)
+
+		sources := make([]string, 0, len(keys))
+		for _, key := range keys {
+			sources = append(sources, SourceKey(key))
+		}
+		recorded, err := s.storedObjects.FindExistingKeys(ctx, sources)
+		if err != nil {
+			return fmt.Errorf("failed to find stored objects: %w", err)
+		}
+		// Pictures stored before stored_object was backfilled have no row,
+		// so the pictures themselves are checked too.
+		referenced, err := s.repository.FindReferencedPicturePaths(ctx, sources)
+		if err != nil {
+			return fmt.Errorf("failed to find referenced pictures: %w", err)
+		}
+		inUse := make(map[string]bool, len(recorded)+len(referenced))
+		for _, key := range slices.Concat(recorded, referenced) {
+			inUse[key] = true
+		}
+
+		var orphaned []string
+		for _, key := range keys {
+			if !inUse[SourceKey(key)] {
+				orphaned = append(orphaned, key)
+			}
+		}
+		if err := s.objects.Delete(ctx, orphaned...); err != nil {
+			return fmt.Errorf("failed to delete orphaned objects: %w", err)
+		}
+		result.Deleted += len(orphaned)
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to collect orphaned objects: %w", err)
+	}
+	return result, nil
+}
+
+func (s *maintenanceService) RecomputeTrendingScores(ctx context.Context) (int64, error) {
+	scored, err := s.repository.RecomputeTrendingScores(ctx, time.Now().Add(-s.settings.TrendingWindow))
+	if err != nil {
+		return 0, fmt.Errorf("failed to recompute trending scores: %w", err)
+	}
+	return scored, nil
+}
+
+func (s *maintenanceService) PruneProcessedEvents(ctx context.Context) (int64, error) {
+	pruned, err := s.eventLedger.Prune(ctx, time.Now().Add(-s.settings.EventLedgerRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune processed events: %w", err)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+const commentPreviewLength = 140
+
+const MessageTypeNotification = "notification"
+
+// Publisher pushes realtime messages to whoever is subscribed to a topic.
+type Publisher interface {
+	Publish(ctx context.Context, topic, messageType string, payload any) error
+}
+
+func UserTopic(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func RecipeTopic(recipeID uuid.UUID) string {
+	return "recipe:" + recipeID.String()
+}
+
+// NotificationPush is the payload sent to a recipe creator's user topic when
+// one of their notifications is created or bumped.
+type NotificationPush struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	RecipeID    string    `json:"recipe_id"`
+	RecipeTitle string    `json:"recipe_title"`
+	ActorID     string    `json:"actor_id"`
+	ActorCount  int       `json:"actor_count"`
+	Preview     string    `json:"preview"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type NotificationService interface {
+	NotifyLike(ctx context.Context, recipeID, actorID uuid.UUID) error
+	NotifyComment(ctx context.Context, recipeID, actorID uuid.UUID, content string) error
+	NotifyRating(ctx context.Context, recipeID, actorID uuid.UUID, value int) error
+	List(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]models.NotificationSummary, int64, error)
+	MarkRead(ctx context.Context, userID, id uuid.UUID) (*models.Notification, error)
+	MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	SetPreference(ctx context.Context, userID uuid.UUID, notificationType string, enabled bool) error
+	Preferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error)
+}
+
+type notificationService struct {
+	repository repositories.NotificationRepository
+	recipeRepo repositories.RecipeRepository
+	publisher  Publisher
+}
+
+func NewNotificationService(repository repositories.NotificationRepository, recipeRepo repositories.RecipeRepository, publisher Publisher) NotificationService {
+	return &notificationService{repository: repository, recipeRepo: recipeRepo, publisher: publisher}
+}
+
+func (s *notificationService) NotifyLike(ctx context.Context, recipeID, actorID uuid.UUID) error {
+	return s.notify(ctx, models.NotificationTypeLike, recipeID, actorID, "")
+}
+
+func (s *notificationService) NotifyComment(ctx context.Context, recipeID, actorID uuid.UUID, content string) error {
+	preview := content
+	if utf8.RuneCountInString(preview) > commentPreviewLength {
+		preview = string([]rune(preview)[:commentPreviewLength]) + "…"
+	}
+	return s.notify(ctx, models.NotificationTypeComment, recipeID, actorID, preview)
+}
+
+func (s *notificationService) NotifyRating(ctx context.Context, recipeID, actorID uuid.UUID, value int) error {
+	return s.notify(ctx, models.NotificationTypeRating, recipeID, actorID, strconv.Itoa(value))
+}
+
+func (s *notificationService) notify(ctx context.Context, notificationType string, recipeID, actorID uuid.UUID, preview string) error {
+	recipe, err := s.recipeRepo.FindRecipeByID(ctx, recipeID.String())
+	if err != nil {
+		return fmt.Errorf("failed to find recipe: %w", err)
+	}
+	// Creators are not told about their own activity.
+	if recipe.CreatorID == actorID {
+		return nil
+	}
+
+	enabled, err := s.repository.IsEnabled(ctx, recipe.CreatorID, notificationType)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preference: %w", err)
+	}
+	if !enabled {
+		return nil
+	}
+
+	notification := &models.Notification{
+		UserID:      recipe.CreatorID,
+		Type:        notificationType,
+		RecipeID:    recipe.ID,
+		LastActorID: actorID,
+		Preview:     preview,
+	}
+	if err := s.repository.Upsert(ctx, notification); err != nil {
+		return fmt.Errorf("failed to save notification: %w", err)
+	}
+
+	push := NotificationPush{
+		ID:          notification.ID.String(),
+		Type:        notification.Type,
+		RecipeID:    recipe.ID.String(),
+		RecipeTitle: recipe.Title,
+		ActorID:     actorID.String(),
+		ActorCount:  notification.ActorCount,
+		Preview:     notification.Preview,
+		UpdatedAt:   notification.UpdatedAt,
+	}
+	if err := s.publisher.Publish(ctx, UserTopic(recipe.CreatorID), MessageTypeNotification, push); err != nil {
+		return fmt.Errorf("failed to publish notification: %w", err)
+	}
+	return nil
+}
+
+func (s *notificationService) List(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]models.NotificationSummary, int64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	notifications, err := s.repository.ListForUser(ctx, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	unread, err := s.repository.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return notifications, unread, nil
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID, id uuid.UUID) (*models.Notification, error) {
+	notification, err := s.repository.MarkRead(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	return notification, nil
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	updated, err := s.repository.MarkAllRead(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return updated, nil
+}
+
+func (s *notificationService) SetPreference(ctx context.Context, userID uuid.UUID, notificationType string, enabled bool) error {
+	if !slices.Contains(models.NotificationTypes, notificationType) {
+		return &ValidationError{Field: "type", Message: "must be one of like, comment, rating"}
+	}
+
+	err := s.repository.SavePreference(ctx, &models.NotificationPreference{
+		UserID:  userID,
+		Type:    notificationType,
+		Enabled: enabled,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save notification preference: %w", err)
+	}
+	return nil
+}
+
+func (s *notificationService) Preferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	stored, err := s.repository.FindPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification preferences: %w", err)
+	}
+
+	preferences := make(map[string]bool, len(models.NotificationTypes))
+	for _, notificationType := range models.NotificationTypes {
+		preferences[notificationType] = true
+	}
+	for _, preference := range stored {
+		preferences[preference.Type] = preference.Enabled
+	}
+	return preferences, nil
+}
+
+// NotificationMessage renders an aggregated notification, e.g.
+// "12 people liked your recipe "Shakshuka"".
+func NotificationMessage(notification models.NotificationSummary) string {
+	var verb string
+	switch notification.Type {
+	case models.NotificationTypeLike:
+		verb = "liked"
+	case models.NotificationTypeComment:
+		verb = "commented on"
+	case models.NotificationTypeRating:
+		verb = "rated"
+	default:
+		verb = "interacted with"
+	}
+
+	switch notification.ActorCount {
+	case 1:
+		return fmt.Sprintf("%s %s your recipe %q", notification.LastActorName, verb, notification.RecipeTitle)
+	case 2:
+		return fmt.Sprintf("%s and 1 other person %s your recipe %q", notification.LastActorName, verb, notification.RecipeTitle)
+	default:
+		return fmt.Sprintf("%d people %s your recipe %q", notification.ActorCount, verb, notification.RecipeTitle)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+type aggregateKey struct {
+	userID, recipeID uuid.UUID
+	notificationType string
+}
+
+// fakeNotificationRepository folds notifications like the database does: one
+// unread notification per user, type and recipe, counting distinct actors.
+type fakeNotificationRepository struct {
+	repositories.NotificationRepository
+	unread   map[aggregateKey]*models.Notification
+	actors   map[uuid.UUID]map[uuid.UUID]bool
+	disabled map[string]bool
+}
+
+func newFakeNotificationRepository() *fakeNotificationRepository {
+	return &fakeNotificationRepository{
+		unread:   make(map[aggregateKey]*models.Notification),
+		actors:   make(map[uuid.UUID]map[uuid.UUID]bool),
+		disabled: make(map[string]bool),
+	}
+}
+
+func (r *fakeNotificationRepository) IsEnabled(_ context.Context, _ uuid.UUID, notificationType string) (bool, error) {
+	return !r.disabled[notificationType], nil
+}
+
+func (r *fakeNotificationRepository) Upsert(_ context.Context, notification *models.Notification) error {
+	key := aggregateKey{notification.UserID, notification.RecipeID, notification.Type}
+	existing, exists := r.unread[key]
+	if !exists {
+		existing = &models.Notification{ID: uuid.New(), UserID: notification.UserID, Type: notification.Type, RecipeID: notification.RecipeID}
+		r.unread[key] = existing
+		r.actors[existing.ID] = make(map[uuid.UUID]bool)
+	}
+	r.actors[existing.ID][notification.LastActorID] = true
+	existing.LastActorID = notification.LastActorID
+	existing.ActorCount = len(r.actors[existing.ID])
+	existing.Preview = notification.Preview
+	*notification = *existing
+	return nil
+}
+
+type recipeFinder struct {
+	repositories.RecipeRepository
+	recipe *models.Recipe
+}
+
+func (r recipeFinder) FindRecipeByID(context.Context, string) (*models.Recipe, error) {
+	return r.recipe, nil
+}
+
+type publishedMessage struct {
+	topic, messageType string
+	payload            any
+}
+
+type fakePublisher struct {
+	messages []publishedMessage
+}
+
+func (p *fakePublisher) Publish(_ context.Context, topic, messageType string, payload any) error {
+	p.messages = append(p.messages, publishedMessage{topic, messageType, payload})
+	return nil
+}
+
+func newTestNotificationService() (NotificationService, *fakeNotificationRepository, *models.Recipe, *fakePublisher) {
+	recipe := &models.Recipe{ID: uuid.New(), CreatorID: uuid.New(), Title: "Shakshuka"}
+	repository := newFakeNotificationRepository()
+	publisher := &fakePublisher{}
+	return NewNotificationService(repository, recipeFinder{recipe: recipe}, publisher), repository, recipe, publisher
+}
+
+func TestNotificationsAggregatePerRecipe(t *testing.T) {
+	service, repository, recipe, _ := newTestNotificationService()
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	for _, actor := range []uuid.UUID{alice, bob, alice} {
+		if err := service.NotifyLike(ctx, recipe.ID, actor); err != nil {
+			t.Fatalf("NotifyLike: %v", err)
+		}
+	}
+	service.NotifyRating(ctx, recipe.ID, bob, 5)
+	service.NotifyLike(ctx, recipe.ID, recipe.CreatorID)
+
+	if len(repository.unread) != 2 {
+		t.Fatalf("%d notifications, want one for likes and one for ratings", len(repository.unread))
+	}
+	likes := repository.unread[aggregateKey{recipe.CreatorID, recipe.ID, models.NotificationTypeLike}]
+	if likes.ActorCount != 2 || likes.LastActorID != alice {
+		t.Errorf("likes = %d actors, last %s; want 2, last alice", likes.ActorCount, likes.LastActorID)
+	}
+	rating := repository.unread[aggregateKey{recipe.CreatorID, recipe.ID, models.NotificationTypeRating}]
+	if rating.Preview != "5" {
+		t.Errorf("rating preview = %q, want 5", rating.Preview)
+	}
+}
+
+func TestNotificationsRespectPreferences(t *testing.T) {
+	service, repository, recipe, _ := newTestNotificationService()
+	repository.disabled[models.NotificationTypeComment] = true
+
+	if err := service.NotifyComment(context.Background(), recipe.ID, uuid.New(), "Lovely"); err != nil {
+		t.Fatalf("NotifyComment: %v", err)
+	}
+	if len(repository.unread) != 0 {
+		t.Error("a disabled notification type was stored")
+	}
+}
+
+func TestCommentPreviewIsTruncated(t *testing.T) {
+	service, repository, recipe, _ := newTestNotificationService()
+
+	service.NotifyComment(context.Background(), recipe.ID, uuid.New(), strings.Repeat("é", commentPreviewLength+10))
+	comment := repository.unread[aggregateKey{recipe.CreatorID, recipe.ID, models.NotificationTypeComment}]
+	if utf8.RuneCountInString(comment.Preview) != commentPreviewLength+1 || !strings.HasSuffix(comment.Preview, "…") {
+		t.Errorf("preview = %q", comment.Preview)
+	}
+}
+
+func TestNotificationMessage(t *testing.T) {
+	summary := models.NotificationSummary{RecipeTitle: "Shakshuka", LastActorName: "Ada"}
+	summary.Type = models.NotificationTypeLike
+	tests := map[int]string{
+		1:  `Ada liked your recipe "Shakshuka"`,
+		2:  `Ada and 1 other person liked your recipe "Shakshuka"`,
+		12: `12 people liked your recipe "Shakshuka"`,
+	}
+	for count, want := range tests {
+		summary.ActorCount = count
+		if got := NotificationMessage(summary); got != want {
+			t.Errorf("%d actors: %q, want %q", count, got, want)
+		}
+	}
+}
+
+func TestNotificationsArePushedToTheCreator(t *testing.T) {
+	service, _, recipe, publisher := newTestNotificationService()
+	actor := uuid.New()
+
+	service.NotifyLike(context.Background(), recipe.ID, actor)
+	service.NotifyLike(context.Background(), recipe.ID, recipe.CreatorID)
+	if len(publisher.messages) != 1 {
+		t.Fatalf("published %d messages, want 1", len(publisher.messages))
+	}
+	message := publisher.messages[0]
+	push, ok := message.payload.(NotificationPush)
+	if message.topic != UserTopic(recipe.CreatorID) || message.messageType != MessageTypeNotification || !ok {
+		t.Fatalf("published %+v", message)
+	}
+	if push.RecipeTitle != "Shakshuka" || push.ActorID != actor.String() || push.ActorCount != 1 {
+		t.Errorf("push = %+v", push)
+	}
+}
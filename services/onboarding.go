@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobWelcomeMessage     = "onboarding.welcome_message"
+	JobDefaultCollections = "onboarding.default_collections"
+	JobSeedFeed           = "onboarding.seed_feed"
+)
+
+var (
+	defaultCollections = []string{"Favorites", "To Try"}
+	onboardingSteps    = []string{JobWelcomeMessage, JobDefaultCollections, JobSeedFeed}
+)
+
+const seededFeedSize = 20
+
+type OnboardingInput struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type OnboardingService interface {
+	QueueOnboarding(ctx context.Context, userID uuid.UUID) error
+	SendWelcomeMessage(ctx context.Context, userID uuid.UUID) error
+	CreateDefaultCollections(ctx context.Context, userID uuid.UUID) error
+	SeedFeed(ctx context.Context, userID uuid.UUID) error
+}
+
+type onboardingService struct {
+	userRepo       repositories.UserRepository
+	recipeRepo     repositories.RecipeRepository
+	collectionRepo repositories.CollectionRepository
+	feedRepo       repositories.FeedRepository
+	jobRepo        repositories.JobRepository
+	mailer         Mailer
+}
+
+func NewOnboardingService(
+	userRepo repositories.UserRepository,
+	recipeRepo repositories.RecipeRepository,
+	collectionRepo repositories.CollectionRepository,
+	feedRepo repositories.FeedRepository,
+	jobRepo repositories.JobRepository,
+	mailer Mailer,
+) OnboardingService {
+	return &onboardingService{
+		userRepo:       userRepo,
+		recipeRepo:     recipeRepo,
+		collectionRepo: collectionRepo,
+		feedRepo:       feedRepo,
+		jobRepo:        jobRepo,
+		mailer:         mailer,
+	}
+}
+
+// QueueOnboarding enqueues every onboarding step as its own job so that each
+// one is retried independently of the others.
+func (s *onboardingService) QueueOnboarding(ctx context.Context, userID uuid.UUID) error {
+	input, err := json.Marshal(OnboardingInput{UserID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to encode onboarding input: %w", err)
+	}
+
+	for _, step := range onboardingSteps {
+		job := &models.Job{
+			Kind:        step,
+			UserID:      &userID,
+			Input:       input,
+			MaxAttempts: 5,
+		}
+		if err := s.jobRepo.Enqueue(ctx, job); err != nil {
+			return fmt.Errorf("failed to queue %s: %w", step, err)
+		}
+	}
+	return nil
+}
+
+func (s *onboardingService) SendWelcomeMessage(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	err = s.mailer.Send(ctx, Message{
+		Recipient: user.ID,
+		Subject:   "Welcome to the recipe app, " + user.Name + "!",
+		Body:      "Hi " + user.Name + ", your Favorites and To Try collections are ready. Start by saving a recipe you like.",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send welcome message: %w", err)
+	}
+	return nil
+}
+
+func (s *onboardingService) CreateDefaultCollections(ctx context.Context, userID uuid.UUID) error {
+	if err := s.collectionRepo.CreateDefaultCollections(ctx, userID, defaultCollections); err != nil {
+		return fmt.Errorf("failed to create default collections: %w", err)
+	}
+	return nil
+}
+
+func (s *onboardingService) SeedFeed(ctx context.Context, userID uuid.UUID) error {
+	recommendations, err := s.recipeRepo.FindRecommendedRecipes(ctx, userID.String(), seededFeedSize)
+	if err != nil {
+		return fmt.Errorf("failed to find popular recipes: %w", err)
+	}
+
+	items := make([]models.FeedItem, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		items = append(items, models.FeedItem{
+			UserID:   userID,
+			RecipeID: recommendation.RecipeID,
+			Reason:   recommendation.Reason,
+			Score:    recommendation.Score,
+		})
+	}
+	if err := s.feedRepo.AddItems(ctx, items); err != nil {
+		return fmt.Errorf("failed to seed feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+type fakeJobRepository struct {
+	repositories.JobRepository
+	enqueued []*models.Job
+}
+
+func (r *fakeJobRepository) Enqueue(_ context.Context, job *models.Job) error {
+	r.enqueued = append(r.enqueued, job)
+	return nil
+}
+
+type popularRecipes struct {
+	repositories.RecipeRepository
+	recommendations []models.RecipeRecommendation
+}
+
+func (r *popularRecipes) FindRecommendedRecipes(context.Context, string, int) ([]models.RecipeRecommendation, error) {
+	return r.recommendations, nil
+}
+
+type feedItems struct {
+	repositories.FeedRepository
+	items []models.FeedItem
+}
+
+func (r *feedItems) AddItems(_ context.Context, items []models.FeedItem) error {
+	r.items = append(r.items, items...)
+	return nil
+}
+
+func TestQueueOnboardingQueuesEachStep(t *testing.T) {
+	jobs := &fakeJobRepository{}
+	service := NewOnboardingService(nil, nil, nil, nil, jobs, nil)
+	userID := uuid.New()
+
+	if err := service.QueueOnboarding(context.Background(), userID); err != nil {
+		t.Fatalf("QueueOnboarding: %v", err)
+	}
+	if len(jobs.enqueued) != len(onboardingSteps) {
+		t.Fatalf("queued %d jobs, want %d", len(jobs.enqueued), len(onboardingSteps))
+	}
+	for i, job := range jobs.enqueued {
+		if job.Kind != onboardingSteps[i] || job.UserID == nil || *job.UserID != userID {
+			t.Errorf("job %d = %s for %v", i, job.Kind, job.UserID)
+		}
+		var input OnboardingInput
+		if err := json.Unmarshal(job.Input, &input); err != nil || input.UserID != userID {
+			t.Errorf("job %d input = %s", i, job.Input)
+		}
+	}
+}
+
+func TestSeedFeedCopiesRecommendations(t *testing.T) {
+	recipeID := uuid.New()
+	recipes := &popularRecipes{recommendations: []models.RecipeRecommendation{{RecipeID: recipeID, Score: 4.5, Reason: "popular"}}}
+	feed := &feedItems{}
+	service := NewOnboardingService(nil, recipes, nil, feed, nil, nil)
+	userID := uuid.New()
+
+	if err := service.SeedFeed(context.Background(), userID); err != nil {
+		t.Fatalf("SeedFeed: %v", err)
+	}
+	want := models.FeedItem{UserID: userID, RecipeID: recipeID, Reason: "popular", Score: 4.5}
+	if len(feed.items) != 1 || feed.items[0] != want {
+		t.Errorf("feed = %+v, want %+v", feed.items, want)
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"app/models"
+	"app/repositories"
+	"app/utils"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+const JobPictureVariants = "picture.variants"
+
+// PictureVariant is a resized copy of every picture. Variants with a height
+// are cropped to fill it; the others keep the picture's aspect ratio.
+type PictureVariant struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// PictureVariants are ordered by width.
+var PictureVariants = []PictureVariant{
+	{Name: "thumbnail", Width: 160, Height: 160},
+	{Name: "medium", Width: 480},
+	{Name: "large", Width: 1080},
+}
+
+// FullPicture is the picture at its own size, for converting it to another
+// format the way variants are.
+var FullPicture = PictureVariant{Name: "full"}
+
+const (
+	variantPrefix  = "variants/"
+	originalPrefix = "originals/"
+)
+
+func FindPictureVariant(name string) (PictureVariant, bool) {
+	for _, variant := range PictureVariants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return PictureVariant{}, false
+}
+
+// PictureVariantForWidth picks the narrowest variant at least width pixels
+// wide, or the widest one.
+func PictureVariantForWidth(width int) PictureVariant {
+	for _, variant := range PictureVariants {
+		if variant.Width >= width {
+			return variant
+		}
+	}
+	return PictureVariants[len(PictureVariants)-1]
+}
+
+// VariantKey is where a variant of the picture stored at source is kept as
+// contentType. The source key is part of it, see SourceKey.
+func VariantKey(source string, variant PictureVariant, contentType string) string {
+	extension, ok := utils.ImageFormats[contentType]
+	if !ok {
+		extension = path.Ext(source)
+	}
+	return variantPrefix + source + "/" + variant.Name + extension
+}
+
+// OriginalKey is where the file uploaded for the picture stored at source is
+// kept, metadata included, when originals are kept at all.
+func OriginalKey(source string) string {
+	return originalPrefix + source
+}
+
+// DerivedKeys are the keys of every object that may have been made from the
+// picture stored at source, in any of the ImageFormats: conversions and
+// fallbacks depend on the encoders built in when they were made.
+func DerivedKeys(source string) []string {
+	keys := []string{OriginalKey(source)}
+	for _, variant := range PictureVariants {
+		keys = append(keys, VariantKey(source, variant, ""))
+	}
+	for _, contentType := range slices.Sorted(maps.Keys(utils.ImageFormats)) {
+		keys = append(keys, VariantKey(source, FullPicture, contentType))
+		for _, variant := range PictureVariants {
+			keys = append(keys, VariantKey(source, variant, contentType))
+		}
+	}
+	return keys
+}
+
+// SourceKey returns the key of the picture a derived object such as a
+// variant or kept original belongs to, or key itself for pictures.
+func SourceKey(key string) string {
+	switch {
+	case strings.HasPrefix(key, variantPrefix):
+		return path.Dir(strings.TrimPrefix(key, variantPrefix))
+	case strings.HasPrefix(key, originalPrefix):
+		return strings.TrimPrefix(key, originalPrefix)
+	default:
+		return key
+	}
+}
+
+// ContentAddressed reports whether the picture stored at source is named by
+// the SHA-256 of its content, so nothing stored under it or a key derived
+// from it is ever replaced.
+func ContentAddressed(source string) bool {
+	name := strings.TrimSuffix(path.Base(source), path.Ext(source))
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+type PictureInput struct {
+	PictureID uuid.UUID `json:"picture_id"`
+}
+
+type PictureService interface {
+	GenerateVariants(ctx context.Context, pictureID uuid.UUID) error
+	// RegenerateVariants generates the variants of every picture of a recipe
+	// the user owns again and returns how many pictures there were.
+	RegenerateVariants(ctx context.Context, userID, recipeID uuid.UUID) (int, error)
+	// VariantInfo describes a variant as contentType and OpenVariant streams
+	// it, optionally only a range of it. Either renders it first if it is
+	// missing.
+	VariantInfo(ctx context.Context, picture *models.RecipePicture, variant PictureVariant, contentType string) (*repositories.ObjectInfo, error)
+	OpenVariant(ctx context.Context, picture *models.RecipePicture, variant PictureVariant, contentType string, byteRange *repositories.ByteRange) (*repositories.Object, error)
+	// RemoveObjects deletes a deleted picture's objects unless another
+	// picture has the same content. Failures are only logged, since
+	// leftovers are collected later.
+	RemoveObjects(ctx context.Context, picture *models.RecipePicture)
+}
+
+type pictureService struct {
+	repository    repositories.RecipeRepository
+	storedObjects repositories.StoredObjectRepository
+	objects       repositories.ObjectStore
+	transactor    repositories.Transactor
+	renders       singleflight.Group
+}
+
+func NewPictureService(
+	repository repositories.RecipeRepository,
+	storedObjects repositories.StoredObjectRepository,
+	objects repositories.ObjectStore,
+	transactor repositories.Transactor,
+) PictureService {
+	return &pictureService{
+		repository:    repository,
+		storedObjects: storedObjects,
+		objects:       objects,
+		transactor:    transactor,
+	}
+}
+
+// GenerateVariants stores every variant of a newly uploaded picture in its
+// own format, or its fallback when that cannot be encoded. Conversions to
+// other formats are made when first asked for.
+func (s *pictureService) GenerateVariants(ctx context.Context, pictureID uuid.UUID) error {
+	picture, err := s.repository.FindRecipePictureByID(ctx, pictureID.String())
+	// The picture was deleted before its variants were made.
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find recipe picture: %w", err)
+	}
+
+	img, err := s.decodeOriginal(ctx, picture)
+	if err != nil {
+		return err
+	}
+	contentType := utils.EncodedImageFormat(picture.ContentType)
+	for _, variant := range PictureVariants {
+		if err := s.storeVariant(ctx, picture, img, variant, contentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *pictureService) RegenerateVariants(ctx context.Context, userID, recipeID uuid.UUID) (int, error) {
+	recipe, err := s.repository.FindRecipeByID(ctx, recipeID.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find recipe: %w", err)
+	}
+	if recipe.CreatorID != userID {
+		return 0, ErrForbidden
+	}
+
+	pictures, err := s.repository.FindRecipePictures(ctx, recipeID.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find recipe pictures: %w", err)
+	}
+	for _, picture := range pictures {
+		if err := s.GenerateVariants(ctx, picture.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(pictures), nil
+}
+
+func (s *pictureService) VariantInfo(ctx context.Context, picture *models.RecipePicture, variant PictureVariant, contentType string) (*repositories.ObjectInfo, error) {
+	key := VariantKey(picture.Path, variant, contentType)
+	info, err := s.objects.Head(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		if err := s.render(ctx, picture, variant, contentType); err != nil {
+			return nil, err
+		}
+		info, err = s.objects.Head(ctx, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect picture variant: %w", err)
+	}
+	return info, nil
+}
+
+func (s *pictureService) OpenVariant(ctx context.Context, picture *models.RecipePicture, variant PictureVariant, contentType string, byteRange *repositories.ByteRange) (*repositories.Object, error) {
+	key := VariantKey(picture.Path, variant, contentType)
+	object, err := s.objects.Get(ctx, key, byteRange)
+	if errors.Is(err, ErrNotFound) {
+		if err := s.render(ctx, picture, variant, contentType); err != nil {
+			return nil, err
+		}
+		object, err = s.objects.Get(ctx, key, byteRange)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch picture variant: %w", err)
+	}
+	return object, nil
+}
+
+// render stores a missing variant. Concurrent requests for the same variant
+// share one rendering.
+func (s *pictureService) render(ctx context.Context, picture *models.RecipePicture, variant PictureVariant, contentType string) error {
+	_, err, _ := s.renders.Do(VariantKey(picture.Path, variant, contentType), func() (any, error) {
+		// Other requests may be waiting on this one, so it must not be cut
+		// short by the first caller going away.
+		ctx := context.WithoutCancel(ctx)
+		img, err := s.decodeOriginal(ctx, picture)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.storeVariant(ctx, picture, img, variant, contentType)
+	})
+	return err
+}
+
+func (s *pictureService) RemoveObjects(ctx context.Context, picture *models.RecipePicture) {
+	_, err := removeUnreferenced(context.WithoutCancel(ctx), s.transactor, s.storedObjects, s.objects, picture.Path)
+	if err != nil {
+		log.Printf("Failed to delete objects of picture %s: %v", picture.ID, err)
+	}
+}
+
+// removeUnreferenced deletes the picture stored at source, what was derived
+// from it and its record, if no picture refers to it. The record stays
+// locked meanwhile, so an upload of the same content waits and then stores
+// it again rather than having it deleted from under it.
+func removeUnreferenced(
+	ctx context.Context,
+	transactor repositories.Transactor,
+	storedObjects repositories.StoredObjectRepository,
+	objects repositories.ObjectStore,
+	source string,
+) (bool, error) {
+	removed := false
+	err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := storedObjects.LockUnreferenced(ctx, source)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock stored object: %w", err)
+		}
+		if err := objects.Delete(ctx, append([]string{source}, DerivedKeys(source)...)...); err != nil {
+			return fmt.Errorf("failed to delete stored objects: %w", err)
+		}
+		if err := storedObjects.Delete(ctx, source); err != nil {
+			return fmt.Errorf("failed to delete stored object: %w", err)
+		}
+		removed = true
+		return nil
+	})
+	return removed, err
+}
+
+func (s *pictureService) decodeOriginal(ctx context.Context, picture *models.RecipePicture) (image.Image, error) {
+	object, err := s.objects.Get(ctx, picture.Path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch picture: %w", err)
+	}
+	defer object.Body.Close()
+
+	img, _, err := image.Decode(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode picture: %w", err)
+	}
+	return img, nil
+}
+
+func (s *pictureService) storeVariant(ctx context.Context, picture *models.RecipePicture, img image.Image, variant PictureVariant, contentType string) error {
+	var encoded bytes.Buffer
+	if variant.Width > 0 {
+		img = utils.ResizeImage(img, variant.Width, variant.Height)
+	}
+	if err := utils.EncodeImage(&encoded, img, contentType); err != nil {
+		return fmt.Errorf("failed to encode %s variant: %w", variant.Name, err)
+	}
+
+	key := VariantKey(picture.Path, variant, contentType)
+	if err := s.objects.Put(ctx, key, bytes.NewReader(encoded.Bytes()), contentType); err != nil {
+		return fmt.Errorf("failed to store %s variant: %w", variant.Name, err)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"app/models"
@@ -9,36 +12,310 @@ import (
 )
 
 type RecipeService interface {
-	SaveRecipePicture(recipeID uuid.UUID, path string) (*models.RecipePicture, error)
-	FindRecipePictureByID(id uuid.UUID) (*models.RecipePicture, error)
+	SaveRecipePicture(ctx context.Context, userID, recipeID uuid.UUID, object PictureObject) (*models.RecipePicture, error)
+	FindRecipePictureByID(ctx context.Context, id uuid.UUID) (*models.RecipePicture, error)
+	DeleteRecipePicture(ctx context.Context, userID, pictureID uuid.UUID) (*models.RecipePicture, error)
+	ReorderRecipePictures(ctx context.Context, userID, recipeID uuid.UUID, pictureIDs []uuid.UUID) ([]models.RecipePicture, error)
+	SetRecipeThumbnail(ctx context.Context, userID, recipeID uuid.UUID, pictureID *uuid.UUID) (*models.Recipe, error)
+	SetRecipeStepPicture(ctx context.Context, userID, stepID uuid.UUID, pictureID *uuid.UUID) (*models.RecipeStep, error)
+	ScaleRecipeIngredients(ctx context.Context, recipeID uuid.UUID, factor float64) ([]models.RecipeIngredient, error)
+	RecommendRecipes(ctx context.Context, userID uuid.UUID, limit int) ([]models.RecipeRecommendation, error)
+}
+
+// PictureObject is an uploaded picture as stored in the bucket.
+type PictureObject struct {
+	Path        string
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// RecipePictureAdded is the payload of models.OutboxEventRecipePictureAdded.
+type RecipePictureAdded struct {
+	PictureID uuid.UUID `json:"picture_id"`
+	RecipeID  uuid.UUID `json:"recipe_id"`
+	Path      string    `json:"path"`
 }
 
 type recipeService struct {
 	repository repositories.RecipeRepository
+	transactor repositories.Transactor
+	outbox     repositories.OutboxRepository
+	jobRepo    repositories.JobRepository
+	auditor    Auditor
+}
+
+func NewRecipeService(repository repositories.RecipeRepository, transactor repositories.Transactor, outbox repositories.OutboxRepository, jobRepo repositories.JobRepository, auditor Auditor) RecipeService {
+	return &recipeService{repository: repository, transactor: transactor, outbox: outbox, jobRepo: jobRepo, auditor: auditor}
+}
+
+// ownedRecipe finds a recipe the user may change pictures of. Soft deleted
+// recipes are reported as missing.
+func (r *recipeService) ownedRecipe(ctx context.Context, userID, recipeID uuid.UUID) (*models.Recipe, error) {
+	recipe, err := r.repository.FindRecipeByID(ctx, recipeID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipe: %w", err)
+	}
+	if recipe.CreatorID != userID {
+		return nil, ErrForbidden
+	}
+	return recipe, nil
 }
 
-func NewRecipeService(repository repositories.RecipeRepository) RecipeService {
-	return &recipeService{repository: repository}
+// checkRecipePicture reports a picture that is missing or belongs to another
+// recipe as a validation error on field.
+func (r *recipeService) checkRecipePicture(ctx context.Context, recipeID, pictureID uuid.UUID, field string) error {
+	picture, err := r.repository.FindRecipePictureByID(ctx, pictureID.String())
+	if errors.Is(err, ErrNotFound) || (err == nil && picture.RecipeId != recipeID) {
+		return &ValidationError{Field: field, Message: "must be a picture of the recipe"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find recipe picture: %w", err)
+	}
+	return nil
 }
 
-func (r *recipeService) SaveRecipePicture(recipeID uuid.UUID, path string) (*models.RecipePicture, error) {
+func (r *recipeService) SaveRecipePicture(ctx context.Context, userID, recipeID uuid.UUID, object PictureObject) (*models.RecipePicture, error) {
 	picture := &models.RecipePicture{
-		ID:       uuid.New(),
-		RecipeId: recipeID,
-		Path:     path,
+		ID:          uuid.New(),
+		RecipeId:    recipeID,
+		Path:        object.Path,
+		ContentType: object.ContentType,
+		Width:       object.Width,
+		Height:      object.Height,
 	}
 
-	if err := r.repository.SaveRecipePicture(*picture); err != nil {
-		return nil, fmt.Errorf("failed to save recipe picture: %w", err)
+	payload, err := json.Marshal(RecipePictureAdded{PictureID: picture.ID, RecipeID: recipeID, Path: object.Path})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recipe picture event: %w", err)
+	}
+	variantsInput, err := json.Marshal(PictureInput{PictureID: picture.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode picture variants input: %w", err)
+	}
+
+	err = r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := r.ownedRecipe(ctx, userID, recipeID); err != nil {
+			return err
+		}
+		if err := r.repository.SaveRecipePicture(ctx, picture); err != nil {
+			return fmt.Errorf("failed to save recipe picture: %w", err)
+		}
+		err := r.outbox.Add(ctx, &models.OutboxEvent{
+			AggregateType: models.OutboxAggregateRecipe,
+			AggregateID:   recipeID,
+			EventType:     models.OutboxEventRecipePictureAdded,
+			Payload:       payload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record recipe picture event: %w", err)
+		}
+		err = r.jobRepo.Enqueue(ctx, &models.Job{
+			Kind:        JobPictureVariants,
+			UserID:      &userID,
+			Input:       variantsInput,
+			MaxAttempts: 3,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to queue picture variants: %w", err)
+		}
+		err = r.auditor.Record(ctx, AuditEntry{
+			ActorID:    &userID,
+			ActorRole:  models.RoleUser,
+			Action:     models.AuditRecipePictureUploaded,
+			EntityType: "recipe_picture",
+			EntityID:   picture.ID.String(),
+			After:      picture,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to audit recipe picture upload: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return picture, nil
 }
 
-func (r *recipeService) FindRecipePictureByID(id uuid.UUID) (*models.RecipePicture, error) {
-	picture, err := r.repository.FindRecipePictureByID(id.String())
+func (r *recipeService) FindRecipePictureByID(ctx context.Context, id uuid.UUID) (*models.RecipePicture, error) {
+	picture, err := r.repository.FindRecipePictureByID(ctx, id.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to find recipe picture: %w", err)
 	}
 	return picture, nil
 }
+
+// DeleteRecipePicture removes the picture row and any thumbnail or step
+// references to it, with the recipe locked like for a reorder. Removing the
+// object is left to the caller.
+func (r *recipeService) DeleteRecipePicture(ctx context.Context, userID, pictureID uuid.UUID) (*models.RecipePicture, error) {
+	var picture *models.RecipePicture
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if picture, err = r.FindRecipePictureByID(ctx, pictureID); err != nil {
+			return err
+		}
+		if err := r.repository.LockRecipe(ctx, picture.RecipeId.String()); err != nil {
+			return fmt.Errorf("failed to lock recipe: %w", err)
+		}
+		if _, err := r.ownedRecipe(ctx, userID, picture.RecipeId); err != nil {
+			return err
+		}
+		if err := r.repository.DeleteRecipePicture(ctx, pictureID.String()); err != nil {
+			return fmt.Errorf("failed to delete recipe picture: %w", err)
+		}
+		err = r.auditor.Record(ctx, AuditEntry{
+			ActorID:    &userID,
+			ActorRole:  models.RoleUser,
+			Action:     models.AuditRecipePictureDeleted,
+			EntityType: "recipe_picture",
+			EntityID:   picture.ID.String(),
+			Before:     picture,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to audit recipe picture deletion: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return picture, nil
+}
+
+// SetRecipeThumbnail makes one of the recipe's own pictures its thumbnail, or
+// clears the thumbnail when pictureID is nil.
+func (r *recipeService) SetRecipeThumbnail(ctx context.Context, userID, recipeID uuid.UUID, pictureID *uuid.UUID) (*models.Recipe, error) {
+	var recipe *models.Recipe
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if recipe, err = r.ownedRecipe(ctx, userID, recipeID); err != nil {
+			return err
+		}
+		if pictureID != nil {
+			if err := r.checkRecipePicture(ctx, recipeID, *pictureID, "picture_id"); err != nil {
+				return err
+			}
+		}
+		if err := r.repository.SetRecipeThumbnail(ctx, recipeID.String(), pictureID); err != nil {
+			return fmt.Errorf("failed to set recipe thumbnail: %w", err)
+		}
+		recipe.ThumbnailID = pictureID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+// ReorderRecipePictures puts the recipe's gallery in the given order, which
+// must list each of its pictures exactly once. The recipe stays locked
+// meanwhile, so no picture is added or deleted between reading the gallery
+// and reordering it.
+func (r *recipeService) ReorderRecipePictures(ctx context.Context, userID, recipeID uuid.UUID, pictureIDs []uuid.UUID) ([]models.RecipePicture, error) {
+	var pictures []models.RecipePicture
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := r.repository.LockRecipe(ctx, recipeID.String()); err != nil {
+			return fmt.Errorf("failed to lock recipe: %w", err)
+		}
+		if _, err := r.ownedRecipe(ctx, userID, recipeID); err != nil {
+			return err
+		}
+		current, err := r.repository.FindRecipePictures(ctx, recipeID.String())
+		if err != nil {
+			return fmt.Errorf("failed to find recipe pictures: %w", err)
+		}
+
+		byID := make(map[uuid.UUID]models.RecipePicture, len(current))
+		for _, picture := range current {
+			byID[picture.ID] = picture
+		}
+		invalid := &ValidationError{Field: "picture_ids", Message: "must list every picture of the recipe once"}
+		if len(pictureIDs) != len(current) {
+			return invalid
+		}
+		for position, pictureID := range pictureIDs {
+			picture, ok := byID[pictureID]
+			if !ok {
+				return invalid
+			}
+			delete(byID, pictureID)
+			picture.Position = position
+			pictures = append(pictures, picture)
+		}
+
+		if err := r.repository.SetRecipePicturePositions(ctx, recipeID.String(), pictureIDs); err != nil {
+			return fmt.Errorf("failed to reorder recipe pictures: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pictures, nil
+}
+
+// SetRecipeStepPicture illustrates a step with one of its recipe's pictures,
+// or removes its picture when pictureID is nil.
+func (r *recipeService) SetRecipeStepPicture(ctx context.Context, userID, stepID uuid.UUID, pictureID *uuid.UUID) (*models.RecipeStep, error) {
+	var step *models.RecipeStep
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if step, err = r.repository.FindRecipeStepByID(ctx, stepID.String()); err != nil {
+			return fmt.Errorf("failed to find recipe step: %w", err)
+		}
+		if _, err := r.ownedRecipe(ctx, userID, step.RecipeID); err != nil {
+			return err
+		}
+		if pictureID != nil {
+			if err := r.checkRecipePicture(ctx, step.RecipeID, *pictureID, "picture_id"); err != nil {
+				return err
+			}
+		}
+		if err := r.repository.SetRecipeStepPicture(ctx, stepID.String(), pictureID); err != nil {
+			return fmt.Errorf("failed to set recipe step picture: %w", err)
+		}
+		step.PictureID = pictureID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+func (r *recipeService) ScaleRecipeIngredients(ctx context.Context, recipeID uuid.UUID, factor float64) ([]models.RecipeIngredient, error) {
+	if factor <= 0 {
+		return nil, &ValidationError{Field: "factor", Message: "must be greater than zero"}
+	}
+
+	recipe, err := r.repository.FindRecipeByID(ctx, recipeID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipe: %w", err)
+	}
+
+	ingredients, err := r.repository.FindRecipeIngredients(ctx, recipe.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipe ingredients: %w", err)
+	}
+	for i := range ingredients {
+		ingredients[i].Quantity *= factor
+	}
+	return ingredients, nil
+}
+
+func (r *recipeService) RecommendRecipes(ctx context.Context, userID uuid.UUID, limit int) ([]models.RecipeRecommendation, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	limit = min(limit, 50)
+
+	recommendations, err := r.repository.FindRecommendedRecipes(ctx, userID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recommend recipes: %w", err)
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+// fakeRecipeRepository records the order of the calls it gets, so tests can
+// check the recipe is locked before its gallery is read and that a refused
+// caller changed nothing.
+type fakeRecipeRepository struct {
+	repositories.RecipeRepository
+	recipe   *models.Recipe
+	pictures []models.RecipePicture
+	calls    []string
+}
+
+func (r *fakeRecipeRepository) LockRecipe(_ context.Context, id string) error {
+	r.calls = append(r.calls, "lock")
+	if id != r.recipe.ID.String() {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *fakeRecipeRepository) FindRecipeByID(_ context.Context, id string) (*models.Recipe, error) {
+	r.calls = append(r.calls, "find recipe")
+	if id != r.recipe.ID.String() {
+		return nil, ErrNotFound
+	}
+	copied := *r.recipe
+	return &copied, nil
+}
+
+func (r *fakeRecipeRepository) FindRecipePictures(context.Context, string) ([]models.RecipePicture, error) {
+	r.calls = append(r.calls, "find pictures")
+	return r.pictures, nil
+}
+
+func (r *fakeRecipeRepository) SetRecipePicturePositions(context.Context, string, []uuid.UUID) error {
+	r.calls = append(r.calls, "reorder")
+	return nil
+}
+
+func (r *fakeRecipeRepository) FindRecipePictureByID(_ context.Context, id string) (*models.RecipePicture, error) {
+	for _, picture := range r.pictures {
+		if picture.ID.String() == id {
+			return &picture, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *fakeRecipeRepository) SetRecipeThumbnail(_ context.Context, _ string, pictureID *uuid.UUID) error {
+	r.calls = append(r.calls, "set thumbnail")
+	r.recipe.ThumbnailID = pictureID
+	return nil
+}
+
+func (r *fakeRecipeRepository) DeleteRecipePicture(context.Context, string) error {
+	r.calls = append(r.calls, "delete picture")
+	return nil
+}
+
+type fakeAuditor struct {
+	Auditor
+}
+
+func (fakeAuditor) Record(context.Context, AuditEntry) error { return nil }
+
+func newFakeGallery(size int) *fakeRecipeRepository {
+	recipe := &models.Recipe{ID: uuid.New(), CreatorID: uuid.New()}
+	repository := &fakeRecipeRepository{recipe: recipe}
+	for i := 0; i < size; i++ {
+		repository.pictures = append(repository.pictures, models.RecipePicture{ID: uuid.New(), RecipeId: recipe.ID, Position: i})
+	}
+	return repository
+}
+
+func TestReorderRecipePicturesLocksRecipe(t *testing.T) {
+	repository := newFakeGallery(2)
+	service := NewRecipeService(repository, fakeTransactor{}, nil, nil, nil)
+
+	order := []uuid.UUID{repository.pictures[1].ID, repository.pictures[0].ID}
+	pictures, err := service.ReorderRecipePictures(context.Background(), repository.recipe.CreatorID, repository.recipe.ID, order)
+	if err != nil {
+		t.Fatalf("ReorderRecipePictures: %v", err)
+	}
+	if pictures[0].ID != order[0] || pictures[0].Position != 0 || pictures[1].Position != 1 {
+		t.Errorf("pictures came back out of the requested order: %+v", pictures)
+	}
+	want := []string{"lock", "find recipe", "find pictures", "reorder"}
+	if len(repository.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", repository.calls, want)
+	}
+	for i := range want {
+		if repository.calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", repository.calls, want)
+		}
+	}
+}
+
+func TestReorderRecipePicturesRejectsIncompleteOrder(t *testing.T) {
+	repository := newFakeGallery(2)
+	service := NewRecipeService(repository, fakeTransactor{}, nil, nil, nil)
+
+	_, err := service.ReorderRecipePictures(context.Background(), repository.recipe.CreatorID, repository.recipe.ID, []uuid.UUID{repository.pictures[0].ID})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Errorf("err = %v, want a validation error", err)
+	}
+
+	_, err = service.ReorderRecipePictures(context.Background(), uuid.New(), repository.recipe.ID, nil)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("reordering someone else's recipe: err = %v, want ErrForbidden", err)
+	}
+}
+
+func TestRecipePicturesNeedTheCreator(t *testing.T) {
+	repository := newFakeGallery(1)
+	service := NewRecipeService(repository, fakeTransactor{}, nil, nil, fakeAuditor{})
+	picture := repository.pictures[0]
+	stranger := uuid.New()
+
+	if _, err := service.SetRecipeThumbnail(context.Background(), stranger, repository.recipe.ID, &picture.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("thumbnail set by a stranger: err = %v, want ErrForbidden", err)
+	}
+	if _, err := service.DeleteRecipePicture(context.Background(), stranger, picture.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("picture deleted by a stranger: err = %v, want ErrForbidden", err)
+	}
+	for _, call := range repository.calls {
+		if call == "set thumbnail" || call == "delete picture" {
+			t.Fatalf("a stranger got to %s", call)
+		}
+	}
+
+	recipe, err := service.SetRecipeThumbnail(context.Background(), repository.recipe.CreatorID, repository.recipe.ID, &picture.ID)
+	if err != nil || recipe.ThumbnailID == nil || *recipe.ThumbnailID != picture.ID {
+		t.Errorf("thumbnail set by the creator = %+v, %v", recipe, err)
+	}
+	if _, err := service.DeleteRecipePicture(context.Background(), repository.recipe.CreatorID, picture.ID); err != nil {
+		t.Errorf("picture deleted by the creator: %v", err)
+	}
+}
+
+func TestRecipeThumbnailMustBelongToTheRecipe(t *testing.T) {
+	repository := newFakeGallery(1)
+	service := NewRecipeService(repository, fakeTransactor{}, nil, nil, fakeAuditor{})
+	other := uuid.New()
+
+	_, err := service.SetRecipeThumbnail(context.Background(), repository.recipe.CreatorID, repository.recipe.ID, &other)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "picture_id" {
+		t.Errorf("err = %v, want a validation error on picture_id", err)
+	}
+}
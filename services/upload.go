@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"app/models"
+	"app/repositories"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+const uploadPrefix = "uploads/"
+
+// ErrUploadExpired is returned for pending uploads that are gone, expired or
+// belong to someone else.
+var ErrUploadExpired = errors.New("upload not found or expired")
+
+// PictureUploadSettings bound what picture uploads may contain.
+// KeepOriginal also stores the file as uploaded, see OriginalKey.
+type PictureUploadSettings struct {
+	MaxBytes     int64
+	Limits       utils.ImageLimits
+	KeepOriginal bool
+	Expiry       time.Duration
+}
+
+// PictureUploadRequest is a presigned PUT the client sends the file with,
+// then confirms by ID.
+type PictureUploadRequest struct {
+	ID        uuid.UUID
+	Method    string
+	URL       string
+	Headers   map[string]string
+	ExpiresAt time.Time
+}
+
+type PictureUploadService interface {
+	// Upload stores a picture the client sent through the app.
+	Upload(ctx context.Context, userID, recipeID uuid.UUID, file io.ReadSeeker) (*models.RecipePicture, error)
+	RequestUpload(ctx context.Context, userID, recipeID uuid.UUID, contentType string, size int64) (*PictureUploadRequest, error)
+	ConfirmUpload(ctx context.Context, userID, uploadID uuid.UUID) (*models.RecipePicture, error)
+	ExpireUploads(ctx context.Context) (int64, error)
+}
+
+type pictureUploadService struct {
+	recipeService RecipeService
+	recipes       repositories.RecipeRepository
+	uploads       repositories.PendingUploadRepository
+	storedObjects repositories.StoredObjectRepository
+	objects       repositories.ObjectStore
+	transactor    repositories.Transactor
+	settings      PictureUploadSettings
+}
+
+func NewPictureUploadService(
+	recipeService RecipeService,
+	recipes repositories.RecipeRepository,
+	uploads repositories.PendingUploadRepository,
+	storedObjects repositories.StoredObjectRepository,
+	objects repositories.ObjectStore,
+	transactor repositories.Transactor,
+	settings PictureUploadSettings,
+) PictureUploadService {
+	return &pictureUploadService{
+		recipeService: recipeService,
+		recipes:       recipes,
+		uploads:       uploads,
+		storedObjects: storedObjects,
+		objects:       objects,
+		transactor:    transactor,
+		settings:      settings,
+	}
+}
+
+func (s *pictureUploadService) Upload(ctx context.Context, userID, recipeID uuid.UUID, file io.ReadSeeker) (*models.RecipePicture, error) {
+	encoded, err := s.encode(file)
+	if err != nil {
+		return nil, err
+	}
+	return s.save(ctx, userID, recipeID, encoded, file, nil)
+}
+
+// RequestUpload signs a PUT of exactly size bytes of contentType for a
+// recipe the user owns. The file is only checked once it is confirmed.
+func (s *pictureUploadService) RequestUpload(ctx context.Context, userID, recipeID uuid.UUID, contentType string, size int64) (*PictureUploadRequest, error) {
+	extension, ok := utils.ImageFormats[contentType]
+	if !ok {
+		return nil, &ValidationError{Field: "content_type", Message: "must be image/jpeg, image/png or image/webp"}
+	}
+	if size <= 0 || size > s.settings.MaxBytes {
+		return nil, &ValidationError{Field: "size", Message: fmt.Sprintf("must be between 1 and %d bytes", s.settings.MaxBytes)}
+	}
+
+	recipe, err := s.recipes.FindRecipeByID(ctx, recipeID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipe: %w", err)
+	}
+	if recipe.CreatorID != userID {
+		return nil, ErrForbidden
+	}
+
+	upload := &models.PendingUpload{
+		ID:          uuid.New(),
+		UserID:      userID,
+		RecipeID:    recipeID,
+		ContentType: contentType,
+		Size:        size,
+		ExpiresAt:   time.Now().Add(s.settings.Expiry),
+	}
+	upload.Key = uploadPrefix + upload.ID.String() + extension
+
+	presigned, err := s.objects.PresignPut(ctx, upload.Key, contentType, size, s.settings.Expiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload: %w", err)
+	}
+	if err := s.uploads.Create(ctx, upload); err != nil {
+		return nil, fmt.Errorf("failed to record pending upload: %w", err)
+	}
+
+	headers := make(map[string]string, len(presigned.Header))
+	for name := range presigned.Header {
+		headers[name] = presigned.Header.Get(name)
+	}
+	return &PictureUploadRequest{
+		ID:        upload.ID,
+		Method:    presigned.Method,
+		URL:       presigned.URL,
+		Headers:   headers,
+		ExpiresAt: upload.ExpiresAt,
+	}, nil
+}
+
+// ConfirmUpload checks the file the client put against what was requested,
+// then stores it the way Upload does and records the picture.
+func (s *pictureUploadService) ConfirmUpload(ctx context.Context, userID, uploadID uuid.UUID) (*models.RecipePicture, error) {
+	upload, err := s.uploads.FindByID(ctx, uploadID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrUploadExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending upload: %w", err)
+	}
+	if upload.UserID != userID || !time.Now().Before(upload.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+
+	info, err := s.objects.Head(ctx, upload.Key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, &ValidationError{Field: "id", Message: "has no uploaded file"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect uploaded file: %w", err)
+	}
+	// The signature pins both, but the object is checked rather than trusted.
+	if info.Size != upload.Size || info.ContentType != upload.ContentType {
+		return nil, &ValidationError{Field: "id", Message: "uploaded file does not match the requested size and content type"}
+	}
+
+	uploaded, err := s.objects.Get(ctx, upload.Key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch uploaded file: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(uploaded.Body, s.settings.MaxBytes+1))
+	uploaded.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch uploaded file: %w", err)
+	}
+	if int64(len(data)) != upload.Size {
+		return nil, &ValidationError{Field: "id", Message: "uploaded file does not match the requested size"}
+	}
+
+	file := bytes.NewReader(data)
+	encoded, err := s.encode(file)
+	if err != nil {
+		return nil, err
+	}
+	picture, err := s.save(ctx, userID, upload.RecipeID, encoded, file, func(ctx context.Context) error {
+		// Deleting first makes a concurrent confirmation of the same upload
+		// find nothing to delete and roll back.
+		err := s.uploads.Delete(ctx, upload.ID)
+		if errors.Is(err, ErrNotFound) {
+			return ErrUploadExpired
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete pending upload: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.deleteObjects(ctx, []string{upload.Key})
+	return picture, nil
+}
+
+// ExpireUploads deletes uploads that were never confirmed, along with any
+// file the client put.
+func (s *pictureUploadService) ExpireUploads(ctx context.Context) (int64, error) {
+	var expired int64
+	for {
+		uploads, err := s.uploads.FindExpired(ctx, time.Now(), 100)
+		if err != nil {
+			return expired, fmt.Errorf("failed to find expired uploads: %w", err)
+		}
+		if len(uploads) == 0 {
+			return expired, nil
+		}
+
+		keys := make([]string, 0, len(uploads))
+		for _, upload := range uploads {
+			keys = append(keys, upload.Key)
+		}
+		if err := s.objects.Delete(ctx, keys...); err != nil {
+			return expired, fmt.Errorf("failed to delete expired upload files: %w", err)
+		}
+		for _, upload := range uploads {
+			err := s.uploads.Delete(ctx, upload.ID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return expired, fmt.Errorf("failed to delete expired upload: %w", err)
+			}
+			expired++
+		}
+	}
+}
+
+// encodedPicture is an upload re-encoded for storage under its content's
+// hash.
+type encodedPicture struct {
+	data         []byte
+	object       PictureObject
+	originalType string
+}
+
+// encode checks that file is an image within limits and re-encodes it with
+// its EXIF orientation applied, which drops any metadata, hashing the result
+// as it is written. Formats without an encoder are stored as their fallback.
+func (s *pictureUploadService) encode(file io.ReadSeeker) (*encodedPicture, error) {
+	// The filename and any declared content type are the client's word; the
+	// format is taken from the file's own bytes.
+	info, err := utils.InspectImage(file, s.settings.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	oriented := utils.ApplyOrientation(info.Image, info.Orientation)
+	contentType := utils.EncodedImageFormat(info.ContentType)
+	var encoded bytes.Buffer
+	hash := sha256.New()
+	if err := utils.EncodeImage(io.MultiWriter(&encoded, hash), oriented, contentType); err != nil {
+		return nil, fmt.Errorf("failed to encode picture: %w", err)
+	}
+
+	bounds := oriented.Bounds()
+	return &encodedPicture{
+		data: encoded.Bytes(),
+		object: PictureObject{
+			Path:        hex.EncodeToString(hash.Sum(nil)) + utils.ImageFormats[contentType],
+			ContentType: contentType,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+		},
+		originalType: info.ContentType,
+	}, nil
+}
+
+// save records the picture, after before if given, in one transaction. The
+// objects are written first, outside it, so no row stays locked while they
+// upload; writing them again is harmless since the key is the content's
+// hash, and if the picture is not recorded the orphan collection removes
+// them. Pictures with the same content share the object, and a kept original
+// is the first one uploaded.
+func (s *pictureUploadService) save(
+	ctx context.Context,
+	userID, recipeID uuid.UUID,
+	encoded *encodedPicture,
+	original io.ReadSeeker,
+	before func(ctx context.Context) error,
+) (*models.RecipePicture, error) {
+	if err := s.writeObjects(ctx, encoded, original); err != nil {
+		return nil, err
+	}
+
+	key := encoded.object.Path
+	var picture *models.RecipePicture
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if before != nil {
+			if err := before(ctx); err != nil {
+				return err
+			}
+		}
+		refs, err := s.storedObjects.Claim(ctx, &models.StoredObject{
+			Key:         key,
+			ContentType: encoded.object.ContentType,
+			Size:        int64(len(encoded.data)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to claim stored picture: %w", err)
+		}
+
+		// An unreferenced object may have been collected after it was
+		// written. The claim holds its row now, so once it is back it stays.
+		if refs == 0 {
+			if _, err := s.objects.Head(ctx, key); errors.Is(err, ErrNotFound) {
+				if err := s.writeObjects(ctx, encoded, original); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return fmt.Errorf("failed to inspect stored picture: %w", err)
+			}
+		}
+
+		picture, err = s.recipeService.SaveRecipePicture(ctx, userID, recipeID, encoded.object)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return picture, nil
+}
+
+// writeObjects stores an encoded picture and, when originals are kept and
+// none is stored for it yet, the original it was encoded from.
+func (s *pictureUploadService) writeObjects(ctx context.Context, encoded *encodedPicture, original io.ReadSeeker) error {
+	key := encoded.object.Path
+	if err := s.objects.Put(ctx, key, bytes.NewReader(encoded.data), encoded.object.ContentType); err != nil {
+		return fmt.Errorf("failed to store picture: %w", err)
+	}
+	if !s.settings.KeepOriginal {
+		return nil
+	}
+
+	_, err := s.objects.Head(ctx, OriginalKey(key))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to inspect original picture: %w", err)
+	}
+	if _, err := original.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind original picture: %w", err)
+	}
+	if err := s.objects.Put(ctx, OriginalKey(key), original, encoded.originalType); err != nil {
+		return fmt.Errorf("failed to store original picture: %w", err)
+	}
+	return nil
+}
+
+// deleteObjects removes objects written for a picture that was not recorded.
+// Whatever is left behind is collected as orphans.
+func (s *pictureUploadService) deleteObjects(ctx context.Context, keys []string) {
+	if err := s.objects.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		log.Printf("Failed to delete picture objects %v: %v", keys, err)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/models"
+	"app/repositories"
+
+	"github.com/google/uuid"
+)
+
+type fakePendingUploadRepository struct {
+	repositories.PendingUploadRepository
+	uploads map[uuid.UUID]*models.PendingUpload
+}
+
+func (r *fakePendingUploadRepository) FindByID(_ context.Context, id uuid.UUID) (*models.PendingUpload, error) {
+	upload, ok := r.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return upload, nil
+}
+
+func (r *fakePendingUploadRepository) Delete(_ context.Context, id uuid.UUID) error {
+	if _, ok := r.uploads[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.uploads, id)
+	return nil
+}
+
+func (r *fakePendingUploadRepository) FindExpired(_ context.Context, now time.Time, limit int) ([]models.PendingUpload, error) {
+	var expired []models.PendingUpload
+	for _, upload := range r.uploads {
+		if upload.ExpiresAt.Before(now) && len(expired) < limit {
+			expired = append(expired, *upload)
+		}
+	}
+	return expired, nil
+}
+
+// fakeUploadStore only records deletions; reaching any other method fails
+// the test with a nil interface call.
+type fakeUploadStore struct {
+	repositories.ObjectStore
+	deleted []string
+}
+
+func (s *fakeUploadStore) Delete(_ context.Context, keys ...string) error {
+	s.deleted = append(s.deleted, keys...)
+	return nil
+}
+
+func newTestPendingUpload(uploads *fakePendingUploadRepository, userID uuid.UUID, expiresIn time.Duration) *models.PendingUpload {
+	upload := &models.PendingUpload{ID: uuid.New(), UserID: userID, RecipeID: uuid.New(), ExpiresAt: time.Now().Add(expiresIn)}
+	upload.Key = uploadPrefix + upload.ID.String() + ".png"
+	uploads.uploads[upload.ID] = upload
+	return upload
+}
+
+func TestConfirmUploadRejectsExpiredAndForeignUploads(t *testing.T) {
+	uploads := &fakePendingUploadRepository{uploads: make(map[uuid.UUID]*models.PendingUpload)}
+	service := NewPictureUploadService(nil, nil, uploads, nil, &fakeUploadStore{}, fakeTransactor{}, PictureUploadSettings{})
+	owner := uuid.New()
+	expired := newTestPendingUpload(uploads, owner, -time.Minute)
+	pending := newTestPendingUpload(uploads, owner, time.Hour)
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		uploadID uuid.UUID
+	}{
+		{"expired", owner, expired.ID},
+		{"someone else's", uuid.New(), pending.ID},
+		{"unknown", owner, uuid.New()},
+	}
+	for _, test := range tests {
+		if _, err := service.ConfirmUpload(context.Background(), test.userID, test.uploadID); !errors.Is(err, ErrUploadExpired) {
+			t.Errorf("%s upload: err = %v, want ErrUploadExpired", test.name, err)
+		}
+	}
+}
+
+func TestExpireUploadsDeletesFilesAndRows(t *testing.T) {
+	uploads := &fakePendingUploadRepository{uploads: make(map[uuid.UUID]*models.PendingUpload)}
+	store := &fakeUploadStore{}
+	service := NewPictureUploadService(nil, nil, uploads, nil, store, fakeTransactor{}, PictureUploadSettings{})
+	expired := newTestPendingUpload(uploads, uuid.New(), -time.Minute)
+	pending := newTestPendingUpload(uploads, uuid.New(), time.Hour)
+
+	count, err := service.ExpireUploads(context.Background())
+	if err != nil || count != 1 {
+		t.Fatalf("ExpireUploads = %d, %v; want 1", count, err)
+	}
+	if len(store.deleted) != 1 || store.deleted[0] != expired.Key {
+		t.Errorf("deleted files %v, want only %s", store.deleted, expired.Key)
+	}
+	if _, ok := uploads.uploads[expired.ID]; ok {
+		t.Error("the expired upload is still pending")
+	}
+	if _, ok := uploads.uploads[pending.ID]; !ok {
+		t.Error("an upload that has not expired was removed")
+	}
+}
@@ -1,29 +1,48 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
 
 	"app/models"
 	"app/repositories"
 	"app/utils"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// TokenVerifier checks that a token issued to a user has not been revoked
+// since.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, userID uuid.UUID, tokenVersion int) error
+}
+
 type UserService interface {
-	SignUp(username, password, name, bio string) (*models.User, error)
-	SignIn(username, password string) (string, *models.User, error)
+	TokenVerifier
+	SignUp(ctx context.Context, username, password, name, bio string) (*models.User, error)
+	SignIn(ctx context.Context, username, password string) (string, *models.User, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error
+	SetRole(ctx context.Context, caller Caller, userID uuid.UUID, role string) (*models.User, error)
 }
 
 type userService struct {
-	userRepo repositories.UserRepository
+	userRepo   repositories.UserRepository
+	transactor repositories.Transactor
+	auditor    Auditor
 }
 
-func NewUserService(userRepo repositories.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repositories.UserRepository, transactor repositories.Transactor, auditor Auditor) UserService {
+	return &userService{userRepo: userRepo, transactor: transactor, auditor: auditor}
 }
 
-func (s *userService) SignUp(username, password, name, bio string) (*models.User, error) {
+func (s *userService) SignUp(ctx context.Context, username, password, name, bio string) (*models.User, error) {
 	hashedPassword, err := utils.HashPassword(password)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, &ValidationError{Field: "password", Message: "must be at most 72 bytes long"}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -34,23 +53,150 @@ func (s *userService) SignUp(username, password, name, bio string) (*models.User
 		Name:     name,
 		Bio:      bio,
 	}
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 	return user, nil
 }
 
-func (s *userService) SignIn(username, password string) (string, *models.User, error) {
-	user, err := s.userRepo.FindByUsername(username)
+func (s *userService) SignIn(ctx context.Context, username, password string) (string, *models.User, error) {
+	user, err := s.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		s.auditSignIn(ctx, models.AuditSignInFailed, nil, "unknown username")
+		return "", nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return "", nil, fmt.Errorf("invalid username: %w", err)
+		return "", nil, fmt.Errorf("failed to find user: %w", err)
 	}
 	if err := utils.VerifyPassword(user.Password, password); err != nil {
-		return "", nil, fmt.Errorf("invalid password: %w", err)
+		s.auditSignIn(ctx, models.AuditSignInFailed, user, "wrong password")
+		return "", nil, ErrInvalidCredentials
 	}
-	token, err := utils.GenerateJWT(user.ID)
+	token, err := utils.GenerateJWT(user.ID, user.Role, user.TokenVersion)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to generate token: %w", err)
 	}
+	s.auditSignIn(ctx, models.AuditSignInSucceeded, user, "")
 	return token, user, nil
 }
+
+// VerifyToken reports ErrTokenRevoked once the user's token version has moved
+// on, such as after a role change, or the user is gone.
+func (s *userService) VerifyToken(ctx context.Context, userID uuid.UUID, tokenVersion int) error {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if errors.Is(err, ErrNotFound) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.TokenVersion != tokenVersion {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// auditSignIn records a sign in attempt. The username is only kept when it
+// belongs to a user: a failed attempt's username is often a mistyped
+// password. A failure to record it is logged rather than locking users out.
+func (s *userService) auditSignIn(ctx context.Context, action string, user *models.User, reason string) {
+	after := map[string]string{}
+	entry := AuditEntry{
+		Action:     action,
+		EntityType: "user",
+		After:      after,
+	}
+	subject := "an unknown user"
+	if user != nil {
+		entry.ActorID = &user.ID
+		entry.ActorRole = user.Role
+		entry.EntityID = user.ID.String()
+		after["username"] = user.Username
+		subject = "user " + user.ID.String()
+	}
+	if reason != "" {
+		after["reason"] = reason
+	}
+	if err := s.auditor.Record(ctx, entry); err != nil {
+		log.Printf("Failed to audit sign in of %s: %v", subject, err)
+	}
+}
+
+func (s *userService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if err := utils.VerifyPassword(user.Password, currentPassword); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return &ValidationError{Field: "new_password", Message: "must be at most 72 bytes long"}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		err := s.auditor.Record(ctx, AuditEntry{
+			ActorID:    &user.ID,
+			ActorRole:  user.Role,
+			Action:     models.AuditPasswordChanged,
+			EntityType: "user",
+			EntityID:   user.ID.String(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to audit password change: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *userService) SetRole(ctx context.Context, caller Caller, userID uuid.UUID, role string) (*models.User, error) {
+	if !caller.Admin {
+		return nil, ErrForbidden
+	}
+	if !slices.Contains(models.Roles, role) {
+		return nil, &ValidationError{Field: "role", Message: "must be one of user, admin"}
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	previousRole := user.Role
+	if previousRole == role {
+		return user, nil
+	}
+	entry := AuditEntry{
+		ActorRole:  models.RoleAdmin,
+		Action:     models.AuditRoleChanged,
+		EntityType: "user",
+		EntityID:   user.ID.String(),
+		Before:     map[string]string{"role": previousRole},
+		After:      map[string]string{"role": role},
+	}
+	if caller.UserID != uuid.Nil {
+		entry.ActorID = &caller.UserID
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateRole(ctx, userID, role); err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+		if err := s.auditor.Record(ctx, entry); err != nil {
+			return fmt.Errorf("failed to audit role change: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	user.TokenVersion++
+	return user, nil
+}
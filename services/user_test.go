@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"app/models"
+	"app/repositories"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type fakeUserRepository struct {
+	repositories.UserRepository
+	users map[uuid.UUID]*models.User
+}
+
+func (r *fakeUserRepository) FindByUsername(_ context.Context, username string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *fakeUserRepository) FindByID(_ context.Context, id string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.ID.String() == id {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *fakeUserRepository) UpdateRole(_ context.Context, id uuid.UUID, role string) error {
+	user, exists := r.users[id]
+	if !exists {
+		return ErrNotFound
+	}
+	user.Role = role
+	user.TokenVersion++
+	return nil
+}
+
+type fakeAuditLogRepository struct {
+	repositories.AuditLogRepository
+	entries []*models.AuditLog
+}
+
+func (r *fakeAuditLogRepository) Append(_ context.Context, log *models.AuditLog) error {
+	r.entries = append(r.entries, log)
+	return nil
+}
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newFakeUserService(t *testing.T) (UserService, *models.User, *fakeAuditLogRepository) {
+	t.Helper()
+	hashed, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: uuid.New(), Username: "ada", Password: hashed, Role: models.RoleUser}
+	users := &fakeUserRepository{users: map[uuid.UUID]*models.User{user.ID: user}}
+	audit := &fakeAuditLogRepository{}
+	return NewUserService(users, fakeTransactor{}, NewAuditor(audit)), user, audit
+}
+
+func TestSignInAuditsWithoutUnknownUsernames(t *testing.T) {
+	service, _, audit := newFakeUserService(t)
+
+	if _, _, err := service.SignIn(context.Background(), "correct horse", "ada"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("sign in with swapped fields: err = %v", err)
+	}
+	if _, _, err := service.SignIn(context.Background(), "ada", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("sign in with a wrong password: err = %v", err)
+	}
+	if len(audit.entries) != 2 {
+		t.Fatalf("audited %d attempts, want 2", len(audit.entries))
+	}
+
+	unknown, wrongPassword := audit.entries[0], audit.entries[1]
+	if string(unknown.After) != `{"reason":"unknown username"}` {
+		t.Errorf("unknown username audited as %s", unknown.After)
+	}
+	if unknown.ActorID != nil {
+		t.Errorf("unknown username audited with actor %s", unknown.ActorID)
+	}
+	var after map[string]string
+	json.Unmarshal(wrongPassword.After, &after)
+	if after["username"] != "ada" || after["reason"] != "wrong password" {
+		t.Errorf("wrong password audited as %s", wrongPassword.After)
+	}
+}
+
+func TestSetRoleRevokesTokens(t *testing.T) {
+	service, user, audit := newFakeUserService(t)
+
+	if err := service.VerifyToken(context.Background(), user.ID, 0); err != nil {
+		t.Fatalf("VerifyToken before the role change: %v", err)
+	}
+	if _, err := service.SetRole(context.Background(), Caller{UserID: user.ID}, user.ID, models.RoleAdmin); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("a user made themself admin: err = %v", err)
+	}
+	updated, err := service.SetRole(context.Background(), Caller{Admin: true}, user.ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("SetRole: %v", err)
+	}
+	if updated.TokenVersion != 1 {
+		t.Errorf("token version = %d, want 1", updated.TokenVersion)
+	}
+	if err := service.VerifyToken(context.Background(), user.ID, 0); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("a token issued before the role change: err = %v, want ErrTokenRevoked", err)
+	}
+	if err := service.VerifyToken(context.Background(), user.ID, 1); err != nil {
+		t.Errorf("a token issued after the role change: %v", err)
+	}
+	if err := service.VerifyToken(context.Background(), uuid.New(), 0); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("a token of a missing user: err = %v, want ErrTokenRevoked", err)
+	}
+
+	if len(audit.entries) != 1 || string(audit.entries[0].Changes) != `{"role":{"before":"user","after":"admin"}}` {
+		t.Errorf("role change audited as %+v", audit.entries)
+	}
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"app/models"
+	"app/repositories"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobWebhookDelivery = "webhook.deliver"
+
+	webhookMaxAttempts = 8
+	webhookTimeout     = 10 * time.Second
+)
+
+// Caller is who acts on webhook endpoints. Admins may act on every endpoint,
+// users only on their own.
+type Caller struct {
+	UserID uuid.UUID
+	Admin  bool
+}
+
+func (c Caller) owns(endpoint *models.WebhookEndpoint) bool {
+	return c.Admin || (endpoint.OwnerID != nil && *endpoint.OwnerID == c.UserID)
+}
+
+// WebhookEvent is the JSON body posted to endpoints.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type WebhookDeliveryInput struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+type WebhookService interface {
+	CreateEndpoint(ctx context.Context, caller Caller, endpointURL string, eventTypes []string) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, caller Caller) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, caller Caller, id uuid.UUID) error
+	ListDeliveries(ctx context.Context, caller Caller, endpointID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error)
+	Replay(ctx context.Context, caller Caller, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	Publish(ctx context.Context, eventID, eventType string, data any) error
+	Deliver(ctx context.Context, deliveryID uuid.UUID, final bool) error
+}
+
+type webhookService struct {
+	repository  repositories.WebhookRepository
+	jobRepo     repositories.JobRepository
+	deadLetters repositories.DeadLetterRepository
+	client      *http.Client
+}
+
+func NewWebhookService(repository repositories.WebhookRepository, jobRepo repositories.JobRepository, deadLetters repositories.DeadLetterRepository) WebhookService {
+	return &webhookService{
+		repository:  repository,
+		jobRepo:     jobRepo,
+		deadLetters: deadLetters,
+		client:      newWebhookClient(),
+	}
+}
+
+func (s *webhookService) CreateEndpoint(ctx context.Context, caller Caller, endpointURL string, eventTypes []string) (*models.WebhookEndpoint, error) {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return nil, &ValidationError{Field: "url", Message: "must be an absolute https URL"}
+	}
+	if err := checkWebhookHost(ctx, parsed.Hostname()); err != nil {
+		return nil, &ValidationError{Field: "url", Message: err.Error()}
+	}
+
+	if len(eventTypes) == 0 {
+		eventTypes = models.WebhookEventTypes
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(models.WebhookEventTypes, eventType) {
+			return nil, &ValidationError{Field: "event_types", Message: "must only contain recipe.published, recipe.updated, recipe.deleted"}
+		}
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:        parsed.String(),
+		Secret:     secret,
+		EventTypes: slices.Compact(slices.Sorted(slices.Values(eventTypes))),
+		Active:     true,
+	}
+	if caller.UserID != uuid.Nil {
+		endpoint.OwnerID = &caller.UserID
+	}
+	if err := s.repository.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) ListEndpoints(ctx context.Context, caller Caller) ([]models.WebhookEndpoint, error) {
+	var ownerID *uuid.UUID
+	if !caller.Admin {
+		ownerID = &caller.UserID
+	}
+
+	endpoints, err := s.repository.ListEndpoints(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, caller Caller, id uuid.UUID) error {
+	if _, err := s.ownedEndpoint(ctx, caller, id); err != nil {
+		return err
+	}
+	if err := s.repository.DeleteEndpoint(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, caller Caller, endpointID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	if _, err := s.ownedEndpoint(ctx, caller, endpointID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := s.repository.ListDeliveries(ctx, endpointID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Replay sends a delivery that ran out of attempts again from scratch.
+// Deliveries that are pending or failed still have a job queued for them and
+// are refused, as are concurrent replays of the same delivery.
+func (s *webhookService) Replay(ctx context.Context, caller Caller, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery, err := s.repository.FindDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook delivery: %w", err)
+	}
+	if _, err := s.ownedEndpoint(ctx, caller, delivery.EndpointID); err != nil {
+		return nil, err
+	}
+	if delivery.Status != models.WebhookDeliveryDead {
+		return nil, fmt.Errorf("webhook delivery is %s: %w", delivery.Status, ErrConflict)
+	}
+
+	reset, err := s.repository.ResetDeadDelivery(ctx, delivery.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset webhook delivery: %w", err)
+	}
+	if !reset {
+		return nil, fmt.Errorf("webhook delivery is already being replayed: %w", ErrConflict)
+	}
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	if err := s.enqueue(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) ownedEndpoint(ctx context.Context, caller Caller, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.repository.FindEndpointByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook endpoint: %w", err)
+	}
+	// Other users' endpoints are reported as missing rather than forbidden.
+	if !caller.owns(endpoint) {
+		return nil, fmt.Errorf("failed to find webhook endpoint: %w", ErrNotFound)
+	}
+	return endpoint, nil
+}
+
+// Publish records a delivery for every endpoint subscribed to eventType and
+// queues it. Called within the event ledger transaction, so a redelivered
+// Hasura event does not fan out twice.
+func (s *webhookService) Publish(ctx context.Context, eventID, eventType string, data any) error {
+	endpoints, err := s.repository.FindSubscribedEndpoints(ctx, eventType)
+	if err != nil {
+		return fmt.Errorf("failed to find webhook endpoints: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	for _, endpoint := range endpoints {
+		delivery := &models.WebhookDelivery{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    payload,
+		}
+		if err := s.repository.CreateDelivery(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to record webhook delivery: %w", err)
+		}
+		if err := s.enqueue(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) enqueue(ctx context.Context, delivery *models.WebhookDelivery) error {
+	input, err := json.Marshal(WebhookDeliveryInput{DeliveryID: delivery.ID})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook delivery input: %w", err)
+	}
+
+	job := &models.Job{
+		Kind:        JobWebhookDelivery,
+		Input:       input,
+		MaxAttempts: webhookMaxAttempts,
+	}
+	if err := s.jobRepo.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Deliver makes one attempt at a delivery. The returned error makes the job
+// queue retry with backoff; on the final attempt the delivery goes dead and
+// is kept as a dead letter.
+func (s *webhookService) Deliver(ctx context.Context, deliveryID uuid.UUID, final bool) error {
+	delivery, err := s.repository.FindDeliveryByID(ctx, deliveryID)
+	// Deliveries go away with their endpoint.
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find webhook delivery: %w", err)
+	}
+	if delivery.Status == models.WebhookDeliverySucceeded || delivery.Status == models.WebhookDeliveryDead {
+		return nil
+	}
+
+	endpoint, err := s.repository.FindEndpointByID(ctx, delivery.EndpointID)
+	if err != nil {
+		return fmt.Errorf("failed to find webhook endpoint: %w", err)
+	}
+	if !endpoint.Active {
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.LastError = "endpoint is disabled"
+		return s.saveAttempt(ctx, delivery)
+	}
+
+	delivery.Attempts++
+	statusCode, sendErr := s.send(ctx, endpoint, delivery)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	if sendErr == nil {
+		now := time.Now()
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return s.saveAttempt(ctx, delivery)
+	}
+
+	delivery.Status = models.WebhookDeliveryFailed
+	if final {
+		delivery.Status = models.WebhookDeliveryDead
+	}
+	delivery.LastError = sendErr.Error()
+	if err := s.saveAttempt(ctx, delivery); err != nil {
+		return err
+	}
+	if final {
+		err := s.deadLetters.Add(ctx, &models.DeadLetter{
+			Source:    models.DeadLetterSourceWebhook,
+			Name:      delivery.EventType,
+			Reference: delivery.ID.String(),
+			Payload:   delivery.Payload,
+			Error:     delivery.LastError,
+			Attempts:  delivery.Attempts,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record dead letter: %w", err)
+		}
+	}
+	return sendErr
+}
+
+func (s *webhookService) saveAttempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := s.repository.SaveDelivery(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// send posts a delivery to its endpoint. Only the status code of a rejection
+// is reported: the response body is the endpoint's and is not kept.
+func (s *webhookService) send(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	// Endpoints registered before https was required are not sent to.
+	if parsed, err := url.Parse(endpoint.URL); err != nil || parsed.Scheme != "https" {
+		return 0, errors.New("endpoint URL must use https")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "recipe-app-webhooks")
+	request.Header.Set("X-Webhook-Id", delivery.ID.String())
+	request.Header.Set("X-Webhook-Event", delivery.EventType)
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", "v1="+utils.SignPayload(endpoint.Secret, timestamp, delivery.Payload))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint responded %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var errWebhookAddress = errors.New("must not resolve to a loopback, private, link-local or unspecified address")
+
+// publicAddress reports whether webhooks may be sent to addr.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// checkWebhookHost resolves an endpoint host when it is registered and
+// rejects it if any of its addresses is not public.
+func checkWebhookHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return errors.New("host could not be resolved")
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return errWebhookAddress
+		}
+	}
+	return nil
+}
+
+// checkWebhookDial runs as the dialer's Control, after resolution, so a host
+// that resolves differently at send time than at registration is still
+// refused.
+func checkWebhookDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %q: %w", address, err)
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s %w", addrPort.Addr(), errWebhookAddress)
+	}
+	return nil
+}
+
+// newWebhookClient sends webhooks to public addresses only. Proxies are not
+// used, since the proxy would be the address checked, and redirects are not
+// followed but reported as the endpoint's response.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: checkWebhookDial,
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"app/models"
+	"app/repositories"
+	"app/utils"
+
+	"github.com/google/uuid"
+)
+
+type fakeWebhookRepository struct {
+	repositories.WebhookRepository
+	endpoints  map[uuid.UUID]*models.WebhookEndpoint
+	deliveries map[uuid.UUID]*models.WebhookDelivery
+}
+
+func newFakeWebhookRepository() *fakeWebhookRepository {
+	return &fakeWebhookRepository{
+		endpoints:  map[uuid.UUID]*models.WebhookEndpoint{},
+		deliveries: map[uuid.UUID]*models.WebhookDelivery{},
+	}
+}
+
+func (r *fakeWebhookRepository) FindEndpointByID(_ context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoint, exists := r.endpoints[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *endpoint
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) FindDeliveryByID(_ context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery, exists := r.deliveries[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) SaveDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	copied := *delivery
+	r.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookRepository) ResetDeadDelivery(_ context.Context, id uuid.UUID) (bool, error) {
+	delivery, exists := r.deliveries[id]
+	if !exists || delivery.Status != models.WebhookDeliveryDead {
+		return false, nil
+	}
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	return true, nil
+}
+
+// newTestDelivery records an endpoint at endpointURL with one pending
+// delivery to it.
+func newTestDelivery(repository *fakeWebhookRepository, endpointURL string) *models.WebhookDelivery {
+	endpoint := &models.WebhookEndpoint{
+		ID:         uuid.New(),
+		URL:        endpointURL,
+		Secret:     "whsec_test",
+		EventTypes: models.WebhookEventTypes,
+		Active:     true,
+	}
+	repository.endpoints[endpoint.ID] = endpoint
+
+	delivery := &models.WebhookDelivery{
+		ID:         uuid.New(),
+		EndpointID: endpoint.ID,
+		EventID:    "event-1",
+		EventType:  models.WebhookEventRecipePublished,
+		Payload:    json.RawMessage(`{"id":"event-1"}`),
+		Status:     models.WebhookDeliveryPending,
+	}
+	repository.deliveries[delivery.ID] = delivery
+	return delivery
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if err != nil || r.Header.Get("X-Webhook-Signature") != "v1="+utils.SignPayload("whsec_test", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repository := newFakeWebhookRepository()
+	delivery := newTestDelivery(repository, server.URL)
+	service := &webhookService{repository: repository, client: server.Client()}
+
+	if err := service.Deliver(context.Background(), delivery.ID, false); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	request := <-received
+	if request.Header.Get("X-Webhook-Id") != delivery.ID.String() {
+		t.Errorf("X-Webhook-Id = %q, want %q", request.Header.Get("X-Webhook-Id"), delivery.ID)
+	}
+
+	saved := repository.deliveries[delivery.ID]
+	if saved.Status != models.WebhookDeliverySucceeded || saved.Attempts != 1 || saved.DeliveredAt == nil {
+		t.Errorf("delivery = %s after %d attempts, want succeeded after 1", saved.Status, saved.Attempts)
+	}
+	if saved.LastStatusCode == nil || *saved.LastStatusCode != http.StatusNoContent {
+		t.Errorf("LastStatusCode = %v, want %d", saved.LastStatusCode, http.StatusNoContent)
+	}
+}
+
+func TestDeliverKeepsOnlyStatusCode(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "internal detail")
+	}))
+	defer server.Close()
+
+	repository := newFakeWebhookRepository()
+	deadLetters := &fakeDeadLetterRepository{}
+	delivery := newTestDelivery(repository, server.URL)
+	service := &webhookService{repository: repository, deadLetters: deadLetters, client: server.Client()}
+
+	if err := service.Deliver(context.Background(), delivery.ID, true); err == nil {
+		t.Fatal("Deliver succeeded against a failing endpoint")
+	}
+
+	saved := repository.deliveries[delivery.ID]
+	if saved.Status != models.WebhookDeliveryDead {
+		t.Errorf("Status = %s, want dead", saved.Status)
+	}
+	if saved.LastStatusCode == nil || *saved.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("LastStatusCode = %v, want %d", saved.LastStatusCode, http.StatusInternalServerError)
+	}
+	if strings.Contains(saved.LastError, "internal detail") {
+		t.Errorf("LastError kept the response body: %q", saved.LastError)
+	}
+	if len(deadLetters.letters) != 1 || strings.Contains(deadLetters.letters[0].Error, "internal detail") {
+		t.Errorf("dead letters = %+v, want one without the response body", deadLetters.letters)
+	}
+}
+
+func TestDeliverRefusesLoopback(t *testing.T) {
+	reached := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	repository := newFakeWebhookRepository()
+	delivery := newTestDelivery(repository, server.URL)
+	service := NewWebhookService(repository, &fakeJobRepository{}, &fakeDeadLetterRepository{})
+
+	err := service.Deliver(context.Background(), delivery.ID, false)
+	if err == nil || !errors.Is(err, errWebhookAddress) {
+		t.Fatalf("Deliver = %v, want %v", err, errWebhookAddress)
+	}
+	if reached {
+		t.Error("the loopback endpoint was sent the delivery")
+	}
+}
+
+func TestCreateEndpointValidatesURL(t *testing.T) {
+	service := NewWebhookService(newFakeWebhookRepository(), &fakeJobRepository{}, &fakeDeadLetterRepository{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, endpointURL := range []string{
+		"http://93.184.215.14/hook",
+		"https://127.0.0.1/hook",
+		"https://10.0.0.8/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/hook",
+		"https://[::ffff:192.168.1.1]/hook",
+		"https://0.0.0.0/hook",
+	} {
+		var validationErr *ValidationError
+		_, err := service.CreateEndpoint(ctx, Caller{Admin: true}, endpointURL, nil)
+		if !errors.As(err, &validationErr) || validationErr.Field != "url" {
+			t.Errorf("CreateEndpoint(%q) = %v, want a url validation error", endpointURL, err)
+		}
+	}
+}
+
+func TestReplayRequiresDeadDelivery(t *testing.T) {
+	repository := newFakeWebhookRepository()
+	jobs := &fakeJobRepository{}
+	delivery := newTestDelivery(repository, "https://hooks.example.com/")
+	service := NewWebhookService(repository, jobs, &fakeDeadLetterRepository{})
+	ctx := context.Background()
+
+	for _, status := range []string{models.WebhookDeliveryPending, models.WebhookDeliveryFailed, models.WebhookDeliverySucceeded} {
+		repository.deliveries[delivery.ID].Status = status
+		if _, err := service.Replay(ctx, Caller{Admin: true}, delivery.ID); !errors.Is(err, ErrConflict) {
+			t.Errorf("Replay of a %s delivery = %v, want ErrConflict", status, err)
+		}
+	}
+
+	repository.deliveries[delivery.ID].Status = models.WebhookDeliveryDead
+	repository.deliveries[delivery.ID].Attempts = webhookMaxAttempts
+	replayed, err := service.Replay(ctx, Caller{Admin: true}, delivery.ID)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replayed.Status != models.WebhookDeliveryPending || replayed.Attempts != 0 {
+		t.Errorf("replayed delivery = %s after %d attempts, want pending after 0", replayed.Status, replayed.Attempts)
+	}
+	if len(jobs.enqueued) != 1 {
+		t.Errorf("enqueued %d jobs, want 1", len(jobs.enqueued))
+	}
+
+	// The delivery is pending again, so a second replay is refused.
+	if _, err := service.Replay(ctx, Caller{Admin: true}, delivery.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("second Replay = %v, want ErrConflict", err)
+	}
+	if len(jobs.enqueued) != 1 {
+		t.Errorf("enqueued %d jobs, want 1", len(jobs.enqueued))
+	}
+}
@@ -1,3 +1,6 @@
+-- Schema of a fresh install. Existing databases are upgraded by the
+-- migrations in hasura/migrations/default instead.
+
 -- Trigger function to update updated_at timestamps
 CREATE OR REPLACE FUNCTION update_timestamp()
 RETURNS TRIGGER AS $$
@@ -14,10 +17,13 @@ CREATE TABLE "user" (
   "name" varchar(255) NOT NULL,
   "bio" text,
   "password" varchar(255) NOT NULL,
+  "role" varchar(20) NOT NULL DEFAULT 'user',
+  "token_version" integer NOT NULL DEFAULT 0,
   "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   "deleted_at" timestamp with time zone,
-  PRIMARY KEY ("id")
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_user_role" CHECK ("role" IN ('user', 'admin'))
 );
 CREATE INDEX "user_index_username" ON "user" ("username");
 CREATE INDEX "user_index_created_at" ON "user" ("created_at");
@@ -134,14 +140,18 @@ CREATE TRIGGER update_recipe_step_timestamp
 CREATE TABLE "recipe_picture" (
   "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
   "recipe_id" uuid NOT NULL,
-  "path" varchar(255) NOT NULL UNIQUE,
+  "path" varchar(255) NOT NULL,
+  "content_type" varchar(50) NOT NULL,
+  "width" integer,
+  "height" integer,
+  "position" integer NOT NULL DEFAULT 0,
   "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY ("id")
 );
 CREATE INDEX "recipe_picture_index_path" ON "recipe_picture" ("path");
 CREATE INDEX "recipe_picture_index_created_at" ON "recipe_picture" ("created_at");
-CREATE INDEX "recipe_picture_index_recipe_id" ON "recipe_picture" ("recipe_id");
+CREATE INDEX "recipe_picture_index_recipe_id_position" ON "recipe_picture" ("recipe_id", "position");
 CREATE TRIGGER update_recipe_picture_timestamp
   BEFORE UPDATE ON "recipe_picture"
   FOR EACH ROW
@@ -210,6 +220,308 @@ CREATE TRIGGER update_comment_timestamp
   FOR EACH ROW
   EXECUTE FUNCTION update_timestamp();
 
+-- job
+CREATE TABLE "job" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "kind" varchar(100) NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'pending',
+  "user_id" uuid,
+  "input" jsonb NOT NULL DEFAULT '{}',
+  "session" jsonb,
+  "result" jsonb,
+  "error" text,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "max_attempts" integer NOT NULL DEFAULT 3,
+  "run_after" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "locked_at" timestamp with time zone,
+  "finished_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_job_status" CHECK ("status" IN ('pending', 'running', 'succeeded', 'failed'))
+);
+CREATE INDEX "job_index_status_run_after" ON "job" ("status", "run_after");
+CREATE INDEX "job_index_user_id" ON "job" ("user_id");
+CREATE TRIGGER update_job_timestamp
+  BEFORE UPDATE ON "job"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- processed_event
+CREATE TABLE "processed_event" (
+  "event_id" uuid NOT NULL,
+  "trigger_name" varchar(255) NOT NULL,
+  "processed_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("event_id")
+);
+CREATE INDEX "processed_event_index_processed_at" ON "processed_event" ("processed_at");
+
+-- collection
+CREATE TABLE "collection" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "user_id" uuid NOT NULL,
+  "name" varchar(100) NOT NULL,
+  "is_default" boolean NOT NULL DEFAULT false,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "collection_index_user_id_name" ON "collection" ("user_id", "name");
+CREATE TRIGGER update_collection_timestamp
+  BEFORE UPDATE ON "collection"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- collection_recipe
+CREATE TABLE "collection_recipe" (
+  "collection_id" uuid NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("collection_id", "recipe_id")
+);
+CREATE INDEX "collection_recipe_index_recipe_id" ON "collection_recipe" ("recipe_id");
+
+-- feed_item
+CREATE TABLE "feed_item" (
+  "user_id" uuid NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "reason" varchar(50) NOT NULL,
+  "score" double precision NOT NULL DEFAULT 0,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("user_id", "recipe_id")
+);
+CREATE INDEX "feed_item_index_user_id_score" ON "feed_item" ("user_id", "score" DESC);
+
+-- notification
+CREATE TABLE "notification" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "user_id" uuid NOT NULL,
+  "type" varchar(20) NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "last_actor_id" uuid NOT NULL,
+  "actor_count" integer NOT NULL DEFAULT 1,
+  "preview" text,
+  "read_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "notification_index_unread_aggregate" ON "notification" ("user_id", "type", "recipe_id") WHERE "read_at" IS NULL;
+CREATE INDEX "notification_index_user_id_updated_at" ON "notification" ("user_id", "updated_at" DESC);
+CREATE TRIGGER update_notification_timestamp
+  BEFORE UPDATE ON "notification"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- notification_actor
+-- The distinct users folded into a notification, which actor_count counts.
+CREATE TABLE "notification_actor" (
+  "notification_id" uuid NOT NULL,
+  "actor_id" uuid NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("notification_id", "actor_id")
+);
+
+-- notification_preference
+CREATE TABLE "notification_preference" (
+  "user_id" uuid NOT NULL,
+  "type" varchar(20) NOT NULL,
+  "enabled" boolean NOT NULL DEFAULT true,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("user_id", "type")
+);
+
+-- webhook_endpoint
+CREATE TABLE "webhook_endpoint" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "owner_id" uuid,
+  "url" text NOT NULL,
+  "secret" varchar(100) NOT NULL,
+  "event_types" jsonb NOT NULL DEFAULT '[]',
+  "active" boolean NOT NULL DEFAULT true,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "webhook_endpoint_index_owner_id" ON "webhook_endpoint" ("owner_id");
+CREATE INDEX "webhook_endpoint_index_event_types" ON "webhook_endpoint" USING GIN ("event_types");
+CREATE TRIGGER update_webhook_endpoint_timestamp
+  BEFORE UPDATE ON "webhook_endpoint"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- webhook_delivery
+CREATE TABLE "webhook_delivery" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "endpoint_id" uuid NOT NULL,
+  "event_id" varchar(64) NOT NULL,
+  "event_type" varchar(50) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_status_code" integer,
+  "last_error" text,
+  "delivered_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_webhook_delivery_status" CHECK ("status" IN ('pending', 'succeeded', 'failed', 'dead'))
+);
+CREATE INDEX "webhook_delivery_index_endpoint_id_created_at" ON "webhook_delivery" ("endpoint_id", "created_at" DESC);
+CREATE TRIGGER update_webhook_delivery_timestamp
+  BEFORE UPDATE ON "webhook_delivery"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- outbox_event
+CREATE TABLE "outbox_event" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "sequence" bigserial NOT NULL,
+  "aggregate_type" varchar(50) NOT NULL,
+  "aggregate_id" uuid NOT NULL,
+  "event_type" varchar(100) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "last_error" text,
+  "next_attempt_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "published_at" timestamp with time zone,
+  "claimed_until" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE ("sequence")
+);
+CREATE INDEX "outbox_event_index_unpublished" ON "outbox_event" ("sequence") WHERE "published_at" IS NULL;
+
+-- cron_run
+CREATE TABLE "cron_run" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "name" varchar(100) NOT NULL,
+  "cron_event_id" varchar(64),
+  "scheduled_time" timestamp with time zone,
+  "status" varchar(20) NOT NULL DEFAULT 'running',
+  "result" jsonb,
+  "error" text,
+  "duration_ms" bigint,
+  "started_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "finished_at" timestamp with time zone,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "check_cron_run_status" CHECK ("status" IN ('running', 'succeeded', 'failed'))
+);
+CREATE INDEX "cron_run_index_name_started_at" ON "cron_run" ("name", "started_at" DESC);
+
+-- recipe_trend
+CREATE TABLE "recipe_trend" (
+  "recipe_id" uuid NOT NULL,
+  "score" double precision NOT NULL DEFAULT 0,
+  "computed_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("recipe_id")
+);
+CREATE INDEX "recipe_trend_index_score" ON "recipe_trend" ("score" DESC);
+
+-- audit_log
+CREATE TABLE "audit_log" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "actor_id" uuid,
+  "actor_role" varchar(20),
+  "action" varchar(100) NOT NULL,
+  "entity_type" varchar(50) NOT NULL,
+  "entity_id" varchar(255),
+  "ip" varchar(64),
+  "user_agent" text,
+  "before" jsonb,
+  "after" jsonb,
+  "changes" jsonb,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "audit_log_index_created_at" ON "audit_log" ("created_at" DESC);
+CREATE INDEX "audit_log_index_actor_id_created_at" ON "audit_log" ("actor_id", "created_at" DESC);
+CREATE INDEX "audit_log_index_entity" ON "audit_log" ("entity_type", "entity_id");
+CREATE INDEX "audit_log_index_action" ON "audit_log" ("action");
+
+-- The audit log is append-only; entries outlive the users and recipes they
+-- mention, so it has no foreign keys either.
+CREATE OR REPLACE FUNCTION prevent_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+  BEFORE UPDATE OR DELETE ON "audit_log"
+  FOR EACH ROW
+  EXECUTE FUNCTION prevent_audit_log_change();
+
+CREATE TRIGGER audit_log_no_truncate
+  BEFORE TRUNCATE ON "audit_log"
+  FOR EACH STATEMENT
+  EXECUTE FUNCTION prevent_audit_log_change();
+
+-- dead_letter
+-- Dead letters keep the raw payload of what failed, so they have no foreign
+-- keys: the delivery or rows they mention may be gone by the time they are
+-- replayed.
+CREATE TABLE "dead_letter" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "source" varchar(20) NOT NULL,
+  "name" varchar(100) NOT NULL,
+  "reference" varchar(64) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "error" text NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "status" varchar(20) NOT NULL DEFAULT 'open',
+  "replay_count" integer NOT NULL DEFAULT 0,
+  "resolved_at" timestamp with time zone,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE ("source", "reference"),
+  CONSTRAINT "check_dead_letter_source" CHECK ("source" IN ('event', 'webhook')),
+  CONSTRAINT "check_dead_letter_status" CHECK ("status" IN ('open', 'replaying', 'replayed', 'discarded'))
+);
+CREATE INDEX "dead_letter_index_status_updated_at" ON "dead_letter" ("status", "updated_at" DESC);
+CREATE TRIGGER update_dead_letter_timestamp
+  BEFORE UPDATE ON "dead_letter"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
+-- pending_upload
+CREATE TABLE "pending_upload" (
+  "id" uuid NOT NULL DEFAULT uuid_generate_v4(),
+  "user_id" uuid NOT NULL,
+  "recipe_id" uuid NOT NULL,
+  "key" varchar(255) NOT NULL,
+  "content_type" varchar(100) NOT NULL,
+  "size" bigint NOT NULL,
+  "expires_at" timestamp with time zone NOT NULL,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE ("key"),
+  CONSTRAINT "check_pending_upload_size" CHECK ("size" > 0)
+);
+CREATE INDEX "pending_upload_index_expires_at" ON "pending_upload" ("expires_at");
+
+-- stored_object
+-- Pictures are stored under the SHA-256 of their content, so pictures with
+-- the same content share a row. ref_count is kept by a trigger on
+-- recipe_picture.
+CREATE TABLE "stored_object" (
+  "key" varchar(255) NOT NULL,
+  "content_type" varchar(100) NOT NULL,
+  "size" bigint NOT NULL,
+  "ref_count" integer NOT NULL DEFAULT 0,
+  "created_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("key"),
+  CONSTRAINT "check_stored_object_ref_count" CHECK ("ref_count" >= 0)
+);
+CREATE INDEX "stored_object_index_unreferenced" ON "stored_object" ("updated_at") WHERE "ref_count" = 0;
+CREATE TRIGGER update_stored_object_timestamp
+  BEFORE UPDATE ON "stored_object"
+  FOR EACH ROW
+  EXECUTE FUNCTION update_timestamp();
+
 -- Foreign Keys
 ALTER TABLE "recipe"
   ADD CONSTRAINT "fk_recipe_category_id"
@@ -297,6 +609,95 @@ ALTER TABLE "comment"
   FOREIGN KEY ("user_id") REFERENCES "user" ("id")
     ON DELETE CASCADE;
 
+ALTER TABLE "job"
+  ADD CONSTRAINT "fk_job_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "collection"
+  ADD CONSTRAINT "fk_collection_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "collection_recipe"
+  ADD CONSTRAINT "fk_collection_recipe_collection_id"
+  FOREIGN KEY ("collection_id") REFERENCES "collection" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "collection_recipe"
+  ADD CONSTRAINT "fk_collection_recipe_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "feed_item"
+  ADD CONSTRAINT "fk_feed_item_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "feed_item"
+  ADD CONSTRAINT "fk_feed_item_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification"
+  ADD CONSTRAINT "fk_notification_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification"
+  ADD CONSTRAINT "fk_notification_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification"
+  ADD CONSTRAINT "fk_notification_last_actor_id"
+  FOREIGN KEY ("last_actor_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification_preference"
+  ADD CONSTRAINT "fk_notification_preference_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification_actor"
+  ADD CONSTRAINT "fk_notification_actor_notification_id"
+  FOREIGN KEY ("notification_id") REFERENCES "notification" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "notification_actor"
+  ADD CONSTRAINT "fk_notification_actor_actor_id"
+  FOREIGN KEY ("actor_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "webhook_endpoint"
+  ADD CONSTRAINT "fk_webhook_endpoint_owner_id"
+  FOREIGN KEY ("owner_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "webhook_delivery"
+  ADD CONSTRAINT "fk_webhook_delivery_endpoint_id"
+  FOREIGN KEY ("endpoint_id") REFERENCES "webhook_endpoint" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "recipe_trend"
+  ADD CONSTRAINT "fk_recipe_trend_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "pending_upload"
+  ADD CONSTRAINT "fk_pending_upload_user_id"
+  FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "pending_upload"
+  ADD CONSTRAINT "fk_pending_upload_recipe_id"
+  FOREIGN KEY ("recipe_id") REFERENCES "recipe" ("id")
+    ON DELETE CASCADE;
+
+ALTER TABLE "recipe_picture"
+  ADD CONSTRAINT "fk_recipe_picture_path"
+  FOREIGN KEY ("path") REFERENCES "stored_object" ("key");
+
 -- Trigger for like_count
 CREATE OR REPLACE FUNCTION update_like_count()
 RETURNS TRIGGER AS $$
@@ -318,6 +719,29 @@ CREATE TRIGGER trigger_update_like_count
 AFTER INSERT OR DELETE ON "liked_recipe"
 FOR EACH ROW EXECUTE FUNCTION update_like_count();
 
+-- Trigger for stored_object ref_count, which also counts pictures deleted
+-- along with their recipe
+CREATE OR REPLACE FUNCTION update_stored_object_ref_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+        UPDATE "stored_object"
+        SET ref_count = ref_count + 1
+        WHERE key = NEW.path;
+    END IF;
+    IF TG_OP = 'DELETE' OR TG_OP = 'UPDATE' THEN
+        UPDATE "stored_object"
+        SET ref_count = GREATEST(ref_count - 1, 0)
+        WHERE key = OLD.path;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_stored_object_ref_count
+AFTER INSERT OR UPDATE OF path OR DELETE ON "recipe_picture"
+FOR EACH ROW EXECUTE FUNCTION update_stored_object_ref_count();
+
 -- Trigger function to increment/decrement rating_count and update average_rating
 CREATE OR REPLACE FUNCTION update_rating_stats()
 RETURNS TRIGGER AS $$
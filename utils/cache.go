@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRangeNotSatisfiable is returned for ranges that start past the end of
+// what is served, to be answered with 416.
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// NotModified reports whether a GET or HEAD may be answered with 304, given
+// the ETag and modification time of what would be served. If-None-Match is
+// compared weakly and, when sent, If-Modified-Since is ignored.
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || (etag != "" && strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/")) {
+				return true
+			}
+		}
+		return false
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// HTTP dates have no fractions of a second.
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// RequestedRange returns the inclusive byte range a request asks for out of
+// size bytes. ranged is false when the whole thing should be sent: there is
+// no Range, If-Range no longer matches, or it asks for something only served
+// whole, such as several ranges at once.
+func RequestedRange(r *http.Request, etag string, lastModified time.Time, size int64) (start, end int64, ranged bool, err error) {
+	header := r.Header.Get("Range")
+	if header == "" || !ifRangeMatches(r.Header.Get("If-Range"), etag, lastModified) {
+		return 0, 0, false, nil
+	}
+	unit, spec, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, nil
+	}
+
+	if first == "" {
+		// A suffix range asks for the last bytes, as in "bytes=-500".
+		length, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || length < 0 {
+			return 0, 0, false, nil
+		}
+		if length == 0 || size == 0 {
+			return 0, 0, false, ErrRangeNotSatisfiable
+		}
+		return max(size-length, 0), size - 1, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, false, ErrRangeNotSatisfiable
+	}
+	return start, end, true, nil
+}
+
+// ifRangeMatches reports whether a Range may be honoured given If-Range,
+// which holds either a strong ETag or the modification time it was made for.
+func ifRangeMatches(header, etag string, lastModified time.Time) bool {
+	if header == "" {
+		return true
+	}
+	if strings.HasPrefix(header, `"`) || strings.HasPrefix(header, "W/") {
+		return etag != "" && !strings.HasPrefix(etag, "W/") && header == etag
+	}
+	date, err := http.ParseTime(header)
+	return err == nil && lastModified.Truncate(time.Second).Equal(date)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// exifJPEG returns the start of a JPEG whose Exif segment holds a single
+// orientation entry, in the given TIFF byte order.
+func exifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xD8})
+	// An unrelated segment first, as cameras write a JFIF header.
+	buf.Write([]byte{0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00})
+	buf.Write([]byte{0xFF, 0xE1})
+	binary.Write(&buf, binary.BigEndian, uint16(len(segment)+2))
+	buf.Write(segment)
+	buf.Write([]byte{0xFF, 0xDA})
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"little endian", exifJPEG(binary.LittleEndian, 6), 6},
+		{"big endian", exifJPEG(binary.BigEndian, 8), 8},
+		{"out of range", exifJPEG(binary.BigEndian, 9), 1},
+		{"no exif", []byte{0xFF, 0xD8, 0xFF, 0xDA}, 1},
+		{"truncated", exifJPEG(binary.LittleEndian, 6)[:20], 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := JPEGOrientation(bytes.NewReader(test.data)); got != test.want {
+				t.Errorf("orientation = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 2x1 image: red on the left, blue on the right.
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation   int
+		width, height int
+		first         color.RGBA
+	}{
+		{1, 2, 1, red},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+	for _, test := range tests {
+		rotated := ApplyOrientation(img, test.orientation)
+		bounds := rotated.Bounds()
+		if bounds.Dx() != test.width || bounds.Dy() != test.height {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", test.orientation, bounds.Dx(), bounds.Dy(), test.width, test.height)
+			continue
+		}
+		if got := color.RGBAModel.Convert(rotated.At(0, 0)); got != test.first {
+			t.Errorf("orientation %d: top left is %v, want %v", test.orientation, got, test.first)
+		}
+	}
+}
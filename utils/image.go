@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	ErrInvalidImage     = errors.New("invalid image")
+	ErrImageTooLarge    = errors.New("image too large")
+)
+
+// ImageFormats maps the content types accepted for pictures to the file
+// extension objects are stored with.
+var ImageFormats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// ImageFormatForExtension returns the content type of the ImageFormats entry
+// for the extension of name, or "" for others.
+func ImageFormatForExtension(name string) string {
+	extension := strings.ToLower(path.Ext(name))
+	if extension == ".jpeg" {
+		return "image/jpeg"
+	}
+	for contentType, known := range ImageFormats {
+		if known == extension {
+			return contentType
+		}
+	}
+	return ""
+}
+
+// ImageFallbacks maps the formats not every client decodes to the format
+// pictures stored in them are sent as to clients that do not ask for them.
+var ImageFallbacks = map[string]string{
+	"image/webp": "image/png",
+}
+
+// ImageLimits bound what is decoded, so a small file cannot expand into an
+// image that exhausts memory.
+type ImageLimits struct {
+	MaxDimension int
+	MaxPixels    int
+}
+
+// ImageInfo describes an inspected image. Width and Height are as stored,
+// before Orientation is applied.
+type ImageInfo struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Orientation int
+	Image       image.Image
+}
+
+// InspectImage identifies an image from its magic bytes, checks its
+// dimensions against limits from the header alone, then decodes it fully to
+// make sure it is a well-formed image. The reader is rewound when done.
+func InspectImage(file io.ReadSeeker, limits ImageLimits) (*ImageInfo, error) {
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	contentType := http.DetectContentType(header[:n])
+	extension, ok := ImageFormats[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImage, contentType)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("%w: empty image", ErrInvalidImage)
+	}
+	if config.Width > limits.MaxDimension || config.Height > limits.MaxDimension || config.Width*config.Height > limits.MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d exceeds %d pixels per side or %d pixels in total",
+			ErrImageTooLarge, config.Width, config.Height, limits.MaxDimension, limits.MaxPixels)
+	}
+
+	orientation := 1
+	if contentType == "image/jpeg" {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		orientation = JPEGOrientation(file)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return &ImageInfo{
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       config.Width,
+		Height:      config.Height,
+		Orientation: orientation,
+		Image:       img,
+	}, nil
+}
+
+// ResizeImage scales img down to width pixels wide. With a height as well,
+// img is first cropped around its centre to that aspect ratio. Images are
+// never enlarged.
+func ResizeImage(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	source := bounds
+	if height > 0 {
+		if bounds.Dx()*height > bounds.Dy()*width {
+			cropWidth := bounds.Dy() * width / height
+			source.Min.X += (bounds.Dx() - cropWidth) / 2
+			source.Max.X = source.Min.X + cropWidth
+		} else {
+			cropHeight := bounds.Dx() * height / width
+			source.Min.Y += (bounds.Dy() - cropHeight) / 2
+			source.Max.Y = source.Min.Y + cropHeight
+		}
+	}
+
+	if source.Dx() <= width {
+		width = source.Dx()
+	}
+	height = max(1, source.Dy()*width/source.Dx())
+	if source == bounds && width == bounds.Dx() {
+		return img
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, source, draw.Src, nil)
+	return resized
+}
+
+// CanEncodeImage reports whether EncodeImage writes contentType.
+func CanEncodeImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png":
+		return true
+	case "image/webp":
+		return webpEncoding
+	default:
+		return false
+	}
+}
+
+// EncodedImageFormat is the format an image of contentType is encoded in:
+// its own when EncodeImage writes it, its fallback otherwise.
+func EncodedImageFormat(contentType string) string {
+	if fallback, ok := ImageFallbacks[contentType]; ok && !CanEncodeImage(contentType) {
+		return fallback
+	}
+	return contentType
+}
+
+// EncodeImage writes img in one of the ImageFormats. WebP needs the
+// webp_encode build tag, see CanEncodeImage.
+func EncodeImage(w io.Writer, img image.Image, contentType string) error {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case "image/png":
+		return png.Encode(w, img)
+	case "image/webp":
+		return encodeWebP(w, img)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedImage, contentType)
+	}
+}
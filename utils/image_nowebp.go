@@ -0,0 +1,20 @@
+//go:build !webp_encode
+
+package utils
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+const webpEncoding = false
+
+// ConvertedImageFormats are the formats pictures are converted to for
+// clients that ask for them, most preferred first. None without an encoder
+// beyond JPEG and PNG; build with the webp_encode tag for WebP.
+var ConvertedImageFormats []string
+
+func encodeWebP(io.Writer, image.Image) error {
+	return fmt.Errorf("%w: image/webp needs the webp_encode build tag", ErrUnsupportedImage)
+}
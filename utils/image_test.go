@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var testLimits = ImageLimits{MaxDimension: 100, MaxPixels: 5000}
+
+func TestInspectImage(t *testing.T) {
+	file := bytes.NewReader(encodePNG(t, 40, 30))
+	info, err := InspectImage(file, testLimits)
+	if err != nil {
+		t.Fatalf("InspectImage: %v", err)
+	}
+	if info.ContentType != "image/png" || info.Extension != ".png" || info.Width != 40 || info.Height != 30 {
+		t.Errorf("info = %+v", info)
+	}
+	if offset, _ := file.Seek(0, io.SeekCurrent); offset != 0 {
+		t.Errorf("reader left at %d, want it rewound", offset)
+	}
+}
+
+func TestInspectImageRejects(t *testing.T) {
+	valid := encodePNG(t, 40, 30)
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text posing as a picture", []byte("<html><body>not a picture</body></html>"), ErrUnsupportedImage},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"), ErrUnsupportedImage},
+		{"truncated png", valid[:len(valid)/2], ErrInvalidImage},
+		{"png header only", valid[:8], ErrInvalidImage},
+		{"too wide", encodePNG(t, 101, 1), ErrImageTooLarge},
+		{"too many pixels", encodePNG(t, 80, 80), ErrImageTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := InspectImage(bytes.NewReader(test.data), testLimits); !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestImageFormatForExtension(t *testing.T) {
+	tests := map[string]string{
+		"pictures/a.jpg":  "image/jpeg",
+		"pictures/a.JPEG": "image/jpeg",
+		"a.png":           "image/png",
+		"a.webp":          "image/webp",
+		"a.gif":           "",
+		"legacy-picture":  "",
+	}
+	for name, want := range tests {
+		if got := ImageFormatForExtension(name); got != want {
+			t.Errorf("ImageFormatForExtension(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	tests := []struct {
+		name          string
+		width, height int
+		wantW, wantH  int
+	}{
+		{"keeps the aspect ratio", 100, 0, 100, 50},
+		{"crops to a square", 100, 100, 100, 100},
+		{"crops to a tall ratio", 50, 100, 50, 100},
+		{"never enlarges", 800, 0, 400, 200},
+		{"never enlarges a crop", 800, 800, 200, 200},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bounds := ResizeImage(img, test.width, test.height).Bounds()
+			if bounds.Dx() != test.wantW || bounds.Dy() != test.wantH {
+				t.Errorf("resized to %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), test.wantW, test.wantH)
+			}
+		})
+	}
+	if ResizeImage(img, 800, 0) != image.Image(img) {
+		t.Error("an image already small enough was copied")
+	}
+}
+
+// tinyWebP is a lossless 1x1 WebP.
+var tinyWebP, _ = base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+
+func TestWebPWithoutEncoder(t *testing.T) {
+	info, err := InspectImage(bytes.NewReader(tinyWebP), testLimits)
+	if err != nil {
+		t.Fatalf("InspectImage: %v", err)
+	}
+	if info.ContentType != "image/webp" {
+		t.Fatalf("ContentType = %q, want image/webp", info.ContentType)
+	}
+
+	// Builds without the webp_encode tag still decode WebP, but store it as PNG.
+	want := "image/png"
+	if CanEncodeImage("image/webp") {
+		want = "image/webp"
+	}
+	contentType := EncodedImageFormat(info.ContentType)
+	if contentType != want {
+		t.Errorf("EncodedImageFormat(image/webp) = %q, want %q", contentType, want)
+	}
+	var encoded bytes.Buffer
+	if err := EncodeImage(&encoded, info.Image, contentType); err != nil {
+		t.Fatalf("EncodeImage(%s): %v", contentType, err)
+	}
+	if !CanEncodeImage("image/webp") {
+		if err := EncodeImage(io.Discard, info.Image, "image/webp"); !errors.Is(err, ErrUnsupportedImage) {
+			t.Errorf("EncodeImage(image/webp) = %v, want ErrUnsupportedImage", err)
+		}
+	}
+}
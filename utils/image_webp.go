@@ -0,0 +1,23 @@
+//go:build webp_encode
+
+package utils
+
+import (
+	"image"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+// The WebP encoder wraps libwebp and so needs cgo; builds without the
+// webp_encode tag decode WebP but store and convert to other formats.
+const webpEncoding = true
+
+// ConvertedImageFormats are the formats pictures are converted to for
+// clients that ask for them, most preferred first. AVIF belongs ahead of WebP
+// once an AVIF encoder is available to EncodeImage.
+var ConvertedImageFormats = []string{"image/webp"}
+
+func encodeWebP(w io.Writer, img image.Image) error {
+	return webp.Encode(w, img, &webp.Options{Quality: 80})
+}
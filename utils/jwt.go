@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,27 +11,37 @@ import (
 
 const JWTSecret = "my-secret-key-my-secret-key-my-secret-key-my-secret-key"
 
+type hasuraClaims struct {
+	XHasuraUserId       string   `json:"x-hasura-user-id"`
+	XHasuraDefaultRole  string   `json:"x-hasura-default-role"`
+	XHasuraAllowedRoles []string `json:"x-hasura-allowed-roles"`
+	XHasuraTokenVersion string   `json:"x-hasura-token-version"`
+}
+
 type Claims struct {
-	UserID       uuid.UUID `json:"user_id"`
-	HasuraClaims struct {
-		XHasuraUserId       string   `json:"x-hasura-user-id"`
-		XHasuraDefaultRole  string   `json:"x-hasura-default-role"`
-		XHasuraAllowedRoles []string `json:"x-hasura-allowed-roles"`
-	} `json:"https://hasura.io/jwt/claims"`
+	UserID       uuid.UUID    `json:"user_id"`
+	TokenVersion int          `json:"token_version"`
+	HasuraClaims hasuraClaims `json:"https://hasura.io/jwt/claims"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID uuid.UUID) (string, error) {
+// GenerateJWT issues a token whose default Hasura role is role. Admins may
+// also act as plain users. The token version reaches actions as the
+// x-hasura-token-version session variable.
+func GenerateJWT(userID uuid.UUID, role string, tokenVersion int) (string, error) {
+	allowedRoles := []string{"user"}
+	if role == "admin" {
+		allowedRoles = append(allowedRoles, "admin")
+	}
+
 	claims := &Claims{
-		UserID: userID,
-		HasuraClaims: struct {
-			XHasuraUserId       string   `json:"x-hasura-user-id"`
-			XHasuraDefaultRole  string   `json:"x-hasura-default-role"`
-			XHasuraAllowedRoles []string `json:"x-hasura-allowed-roles"`
-		}{
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		HasuraClaims: hasuraClaims{
 			XHasuraUserId:       userID.String(),
-			XHasuraDefaultRole:  "user",
-			XHasuraAllowedRoles: []string{"user"},
+			XHasuraDefaultRole:  role,
+			XHasuraAllowedRoles: allowedRoles,
+			XHasuraTokenVersion: strconv.Itoa(tokenVersion),
 		},
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// RequestInfo describes the client behind a request, for audit records.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// ClientIP returns the address of the client behind r. Forwarding headers
+// can be set by anyone, so they are only read when the peer is one of the
+// trusted proxies; X-Forwarded-For is then walked from the right, skipping
+// trusted hops, and the first untrusted one is the client.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		peer = host
+	}
+	if !isTrustedProxy(peer, trusted) {
+		return peer
+	}
+
+	var hops []string
+	for _, forwarded := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(forwarded, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(hops[i], trusted) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return peer
+}
+
+func isTrustedProxy(address string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsExplicitly reports whether the Accept header names contentType with
+// a non-zero quality. Wildcards do not count: "*/*" is sent by clients that
+// cannot decode newer image formats too.
+func AcceptsExplicitly(r *http.Request, contentType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, entry := range strings.Split(accept, ",") {
+			mediaType, params, _ := strings.Cut(entry, ";")
+			if !strings.EqualFold(strings.TrimSpace(mediaType), contentType) {
+				continue
+			}
+			quality := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if name == "q" {
+					if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+						quality = parsed
+					}
+				}
+			}
+			return quality > 0
+		}
+	}
+	return false
+}
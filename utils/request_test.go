@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted peer cannot forward", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed hops left of the client", remoteAddr: "10.0.0.2:5000", forwarded: []string{"1.2.3.4, 198.51.100.1, 10.0.0.3"}, want: "198.51.100.1"},
+		{name: "repeated headers", remoteAddr: "10.0.0.2:5000", forwarded: []string{"1.2.3.4", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "only trusted hops", remoteAddr: "10.0.0.2:5000", forwarded: []string{"10.0.0.4, 10.0.0.3"}, want: "10.0.0.4"},
+		{name: "real ip", remoteAddr: "10.0.0.2:5000", realIP: "198.51.100.9", want: "198.51.100.9"},
+		{name: "ipv6 loopback proxy", remoteAddr: "[::1]:5000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "ipv4 mapped proxy", remoteAddr: "[::ffff:10.0.0.2]:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, forwarded := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			if got := ClientIP(r, trusted); got != test.want {
+				t.Errorf("ClientIP = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SignPayload is the hex HMAC-SHA256 of "<timestamp>.<body>" under secret,
+// sent with outgoing webhooks. Receivers recompute it and reject stale
+// timestamps.
+func SignPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -5,22 +5,72 @@ import (
 	"net/http"
 )
 
-type ErrorMessage struct {
-	Code        string `json:"code"`
-	Description string `json:"description"`
+type ErrorExtensions struct {
+	Code    string `json:"code"`
+	Details any    `json:"details,omitempty"`
+}
+
+// ActionError is the error body Hasura expects from action handlers.
+type ActionError struct {
+	Message    string          `json:"message"`
+	Extensions ErrorExtensions `json:"extensions"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details body, used by the REST endpoints.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
 }
 
 func WriteError(w http.ResponseWriter, status int, errorCode, message string) {
+	WriteErrorDetails(w, status, errorCode, message, nil)
+}
+
+func WriteErrorDetails(w http.ResponseWriter, status int, errorCode, message string, details any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	errorMessage := ErrorMessage{
-		Code:        errorCode,
-		Description: message,
-	}
-	// Serialize ErrorMessage as a JSON string
-	jsonMessage, _ := json.Marshal(errorMessage)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": string(jsonMessage),
+	json.NewEncoder(w).Encode(ActionError{
+		Message: message,
+		Extensions: ErrorExtensions{
+			Code:    errorCode,
+			Details: details,
+		},
+	})
+}
+
+func WriteValidationError(w http.ResponseWriter, errorCode, message string, errs []FieldError) {
+	WriteErrorDetails(w, http.StatusBadRequest, errorCode, message, errs)
+}
+
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, errorCode, detail string) {
+	writeProblem(w, r, status, errorCode, detail, nil)
+}
+
+func WriteValidationProblem(w http.ResponseWriter, r *http.Request, errorCode, detail string, errs []FieldError) {
+	writeProblem(w, r, http.StatusBadRequest, errorCode, detail, errs)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, errorCode, detail string, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     errorCode,
+		Errors:   errs,
 	})
 }
 
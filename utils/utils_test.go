@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWriteErrorDetails(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	WriteValidationError(recorder, "INVALID_INPUT", "Invalid input", []FieldError{{Field: "username", Message: "is required"}})
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	want := `{"message":"Invalid input","extensions":{"code":"INVALID_INPUT","details":[{"field":"username","message":"is required"}]}}`
+	if got := recorder.Body.String(); got != want+"\n" {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+
+	recorder = httptest.NewRecorder()
+	WriteError(recorder, http.StatusNotFound, "NOT_FOUND", "Recipe not found")
+	if got := recorder.Body.String(); got != `{"message":"Recipe not found","extensions":{"code":"NOT_FOUND"}}`+"\n" {
+		t.Errorf("error without details = %s", got)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/recipes/1/pictures", nil)
+	WriteValidationProblem(recorder, r, "INVALID_PICTURE", "The picture is not valid", []FieldError{{Field: "file", Message: "must be an image"}})
+
+	if got := recorder.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var problem Problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	want := Problem{
+		Type:     "about:blank",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "The picture is not valid",
+		Instance: "/recipes/1/pictures",
+		Code:     "INVALID_PICTURE",
+		Errors:   []FieldError{{Field: "file", Message: "must be an image"}},
+	}
+	if !reflect.DeepEqual(problem, want) {
+		t.Errorf("problem = %+v, want %+v", problem, want)
+	}
+}